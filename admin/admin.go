@@ -0,0 +1,358 @@
+// Package admin serves the health, readiness and status endpoints of a
+// validator for orchestrators, the randomness endpoint for clients, and the
+// announcements of committee epochs.
+package admin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/epoch"
+	"random-network-poc/group"
+	"random-network-poc/rng"
+	"random-network-poc/timelock"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+// RequestTimeout bounds a randomness request.
+const RequestTimeout = 30 * time.Second
+
+// maxRequestSize bounds the body of a randomness request.
+const maxRequestSize = 4 << 10
+
+// maxAnnouncementSize bounds the body of an epoch announcement.
+const maxAnnouncementSize = 1 << 20
+
+// Node is the validator reported on.
+type Node interface {
+	Status() dkg.Status
+	Ready() error
+	Generate(ctx context.Context, client string, input []byte) (*dkg.Output, error)
+	GenerateRound(ctx context.Context, client string, round uint64) (*dkg.Output, error)
+}
+
+// Request is the body of a randomness request.
+type Request struct {
+	// Input is the hex input to sign.
+	Input string `json:"input,omitempty"`
+	// Round replaces Input with a beacon round, see
+	// dkg.Node.GenerateRound.
+	Round *uint64 `json:"round,omitempty"`
+}
+
+// RoundRequest is the body of a randomness request for a beacon round.
+type RoundRequest struct {
+	Round uint64 `json:"round"`
+}
+
+// Announcement is the body of an epoch announcement, see
+// epoch.Manager.Announce.
+type Announcement struct {
+	Epoch      uint64 `json:"epoch"`
+	FirstRound uint64 `json:"first_round"`
+	// Committee is the hex public keys of the committee, in index order.
+	Committee []string `json:"committee"`
+	Threshold int      `json:"threshold"`
+	// Commits is the hex public polynomial of the current epoch, see
+	// epoch.Status, only needed by validators joining the group.
+	Commits []string `json:"commits,omitempty"`
+	// Approvals are those of a threshold of the current committee, see
+	// epoch.Approve.
+	Approvals []Approval `json:"approvals"`
+}
+
+// Approval is the hex encoding of an epoch.Approval.
+type Approval struct {
+	Public    string `json:"public"`
+	Signature string `json:"signature"`
+}
+
+// NewApproval returns the hex encoding of a.
+func NewApproval(a epoch.Approval) (Approval, error) {
+	public, err := a.Public.MarshalBinary()
+	if err != nil {
+		return Approval{}, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return Approval{Public: hex.EncodeToString(public), Signature: hex.EncodeToString(a.Signature)}, nil
+}
+
+// Register adds the admin endpoints of node to mux:
+//
+//   - /healthz fails once the DKG failed, the node will never serve rounds,
+//     or once the last refresh failed, its share is likely stale
+//   - /readyz fails until the DKG is done and enough peers are connected
+//   - /status reports the state of the node as JSON
+//   - POST /randomness runs a round over a Request and returns its dkg.Output,
+//     within the client limits of the node for the client address. Beacon
+//     rounds are refused before they are due.
+func Register(mux *http.ServeMux, node Node) {
+	register(mux, "", func(*http.Request) (Node, error) { return node, nil })
+}
+
+// RegisterGroups adds the admin endpoints of every group of groups to mux,
+// under /groups/{group}/, and GET /groups listing the status of each group.
+// Unknown groups are not found.
+func RegisterGroups(mux *http.ServeMux, groups *group.Manager) {
+	mux.HandleFunc("GET /groups", func(w http.ResponseWriter, r *http.Request) {
+		statuses := []dkg.Status{}
+		for _, id := range groups.IDs() {
+			// removed meanwhile
+			if node, err := groups.Node(id); err == nil {
+				statuses = append(statuses, node.Status())
+			}
+		}
+		writeJSON(w, statuses)
+	})
+
+	register(mux, "/groups/{group}", func(r *http.Request) (Node, error) {
+		node, err := groups.Node(r.PathValue("group"))
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+// RegisterEpochs adds the epochs of epochs to mux:
+//
+//   - GET /epochs lists the status of every epoch, see epoch.Status
+//   - POST /epochs announces the next epoch from an Announcement
+//   - POST /epochs/randomness runs a round over a RoundRequest with the
+//     committee of its epoch, and returns its dkg.Output
+//
+// and the admin endpoints of the node of each epoch under /epochs/{epoch}/.
+// Epochs the validator does not sign are not found.
+func RegisterEpochs(mux *http.ServeMux, scheme *crypto.Scheme, epochs *epoch.Manager) {
+	mux.HandleFunc("GET /epochs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, epochs.Statuses())
+	})
+
+	mux.HandleFunc("POST /epochs", func(w http.ResponseWriter, r *http.Request) {
+		var a Announcement
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAnnouncementSize)).Decode(&a); err != nil {
+			http.Error(w, "invalid announcement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		next, commits, approvals, err := a.decode(scheme)
+		if err != nil {
+			http.Error(w, "invalid announcement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := epochs.Announce(next, commits, approvals); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		writeJSON(w, epochs.Statuses())
+	})
+
+	mux.HandleFunc("POST /epochs/randomness", func(w http.ResponseWriter, r *http.Request) {
+		var req RoundRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+		defer cancel()
+
+		out, err := epochs.Generate(ctx, clientOf(r), req.Round)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		writeJSON(w, out)
+	})
+
+	register(mux, "/epochs/{epoch}", func(r *http.Request) (Node, error) {
+		e, err := strconv.ParseUint(r.PathValue("epoch"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", epoch.ErrUnknownEpoch, r.PathValue("epoch"))
+		}
+		node, err := epochs.EpochNode(e)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+// decode returns the committee, commits and approvals of an announcement.
+func (a *Announcement) decode(scheme *crypto.Scheme) (epoch.Committee, []kyber.Point, []epoch.Approval, error) {
+	nodes, err := dkg.ParseNodes(scheme, a.Committee)
+	if err != nil {
+		return epoch.Committee{}, nil, nil, err
+	}
+
+	var commits []kyber.Point
+	for _, commit := range a.Commits {
+		point, err := decodePoint(scheme, commit)
+		if err != nil {
+			return epoch.Committee{}, nil, nil, fmt.Errorf("commitment: %w", err)
+		}
+		commits = append(commits, point)
+	}
+
+	var approvals []epoch.Approval
+	for _, approval := range a.Approvals {
+		public, err := decodePoint(scheme, approval.Public)
+		if err != nil {
+			return epoch.Committee{}, nil, nil, fmt.Errorf("approval: %w", err)
+		}
+		sig, err := hex.DecodeString(approval.Signature)
+		if err != nil {
+			return epoch.Committee{}, nil, nil, fmt.Errorf("failed to decode approval signature: %w", err)
+		}
+		approvals = append(approvals, epoch.Approval{Public: public, Signature: sig})
+	}
+
+	return epoch.Committee{
+		Epoch:      a.Epoch,
+		FirstRound: a.FirstRound,
+		Nodes:      nodes,
+		Threshold:  a.Threshold,
+	}, commits, approvals, nil
+}
+
+// decodePoint returns the point of scheme in hex format s.
+func decodePoint(scheme *crypto.Scheme, s string) (kyber.Point, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode point: %w", err)
+	}
+	point, err := scheme.PointFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal point: %w", err)
+	}
+	return point, nil
+}
+
+// register adds the admin endpoints under prefix, for the node lookup returns
+// for each request.
+func register(mux *http.ServeMux, prefix string, lookup func(*http.Request) (Node, error)) {
+	handle := func(pattern string, handler func(w http.ResponseWriter, r *http.Request, node Node)) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+prefix+path, func(w http.ResponseWriter, r *http.Request) {
+			node, err := lookup(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			handler(w, r, node)
+		})
+	}
+
+	handle("GET /healthz", func(w http.ResponseWriter, r *http.Request, node Node) {
+		if s := node.Status(); s.DKG.State == dkg.DKGFailed {
+			http.Error(w, "DKG failed: "+s.DKG.Error, http.StatusServiceUnavailable)
+			return
+		} else if s.Refresh != nil && s.Refresh.State == dkg.RefreshFailed {
+			http.Error(w, s.Refresh.Error, http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	handle("GET /readyz", func(w http.ResponseWriter, r *http.Request, node Node) {
+		if err := node.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	handle("GET /status", func(w http.ResponseWriter, r *http.Request, node Node) {
+		writeJSON(w, node.Status())
+	})
+
+	handle("POST /randomness", func(w http.ResponseWriter, r *http.Request, node Node) {
+		if err := node.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		input, err := hex.DecodeString(req.Input)
+		if err != nil || len(input) == 0 && req.Round == nil || len(input) != 0 && req.Round != nil {
+			http.Error(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), RequestTimeout)
+		defer cancel()
+
+		var out *dkg.Output
+		if req.Round != nil {
+			out, err = node.GenerateRound(ctx, clientOf(r), *req.Round)
+		} else {
+			out, err = node.Generate(ctx, clientOf(r), input)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		writeJSON(w, out)
+	})
+}
+
+// clientOf returns the client address of a request, whose limits apply.
+func clientOf(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return client
+}
+
+// statusOf maps the error of a randomness request or an epoch announcement
+// to an HTTP status.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, epoch.ErrInvalidCommittee):
+		return http.StatusBadRequest
+	case errors.Is(err, epoch.ErrNotApproved):
+		return http.StatusForbidden
+	case errors.Is(err, epoch.ErrUnknownEpoch):
+		return http.StatusNotFound
+	case errors.Is(err, epoch.ErrHandoverPending), errors.Is(err, epoch.ErrHandoverFailed):
+		return http.StatusConflict
+	case errors.Is(err, epoch.ErrNotMember), errors.Is(err, epoch.ErrRetired), errors.Is(err, epoch.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, dkg.ErrRoundInput):
+		return http.StatusBadRequest
+	case errors.Is(err, timelock.ErrTooEarly):
+		return http.StatusTooEarly
+	case errors.Is(err, dkg.ErrNoSchedule):
+		return http.StatusNotImplemented
+	case errors.Is(err, rng.ErrRateLimited), errors.Is(err, rng.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, dkg.ErrRequestStarted), errors.Is(err, rng.ErrRequestFinished):
+		return http.StatusConflict
+	case errors.Is(err, dkg.ErrNodeClosed), errors.Is(err, dkg.ErrRequestRejected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,282 @@
+package admin
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/epoch"
+	"random-network-poc/group"
+	"random-network-poc/rng"
+	"random-network-poc/sim"
+	"random-network-poc/timelock"
+
+	"github.com/stretchr/testify/require"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+type testNode struct {
+	status dkg.Status
+	ready  error
+	// generate fails with err, or returns the input signed by nobody
+	err     error
+	clients []string
+}
+
+func (n *testNode) Status() dkg.Status { return n.status }
+func (n *testNode) Ready() error       { return n.ready }
+
+func (n *testNode) Generate(ctx context.Context, client string, input []byte) (*dkg.Output, error) {
+	n.clients = append(n.clients, client)
+	if n.err != nil {
+		return nil, n.err
+	}
+	return &dkg.Output{RequestID: hex.EncodeToString(input), Input: hex.EncodeToString(input)}, nil
+}
+
+func (n *testNode) GenerateRound(ctx context.Context, client string, round uint64) (*dkg.Output, error) {
+	return n.Generate(ctx, client, timelock.RoundMessage(round))
+}
+
+func get(t *testing.T, mux *http.ServeMux, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func post(t *testing.T, mux *http.ServeMux, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestEndpoints(t *testing.T) {
+	node := &testNode{
+		status: dkg.Status{
+			Index: 1,
+			DKG:   dkg.DKGStatus{State: dkg.DKGRunning, Phase: "response"},
+			Peers: map[string]int{dkg.Topic: 2},
+		},
+		ready: fmt.Errorf("%w: DKG running", dkg.ErrNotReady),
+	}
+
+	mux := http.NewServeMux()
+	Register(mux, node)
+
+	require.Equal(t, http.StatusOK, get(t, mux, "/healthz").Code)
+
+	rec := get(t, mux, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "DKG running")
+
+	rec = get(t, mux, "/status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status dkg.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, node.status, status)
+
+	node.ready = nil
+	require.Equal(t, http.StatusOK, get(t, mux, "/readyz").Code)
+
+	node.status.Refresh = &dkg.RefreshStatus{Epoch: 7, State: dkg.RefreshFailed, Error: "refresh failed: evicted"}
+	rec = get(t, mux, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "evicted")
+
+	node.status.DKG = dkg.DKGStatus{State: dkg.DKGFailed, Phase: "justification", Error: "timeout"}
+	rec = get(t, mux, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "timeout")
+}
+
+func TestRandomness(t *testing.T) {
+	node := &testNode{ready: fmt.Errorf("%w: DKG running", dkg.ErrNotReady)}
+
+	mux := http.NewServeMux()
+	Register(mux, node)
+
+	require.Equal(t, http.StatusServiceUnavailable, post(t, mux, "/randomness", `{"input":"01"}`).Code)
+
+	node.ready = nil
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/randomness", `{"input":"zz"}`).Code)
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/randomness", `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/randomness", `{"input":"`+strings.Repeat("00", maxRequestSize)+`"}`).Code)
+
+	rec := post(t, mux, "/randomness", `{"input":"0102"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var out dkg.Output
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.Equal(t, "0102", out.Input)
+	// httptest requests come from 192.0.2.1:1234
+	require.Equal(t, []string{"192.0.2.1"}, node.clients)
+
+	node.err = fmt.Errorf("client 192.0.2.1: %w", rng.ErrRateLimited)
+	require.Equal(t, http.StatusTooManyRequests, post(t, mux, "/randomness", `{"input":"0102"}`).Code)
+
+	node.err = fmt.Errorf("%w: 0102", dkg.ErrRequestStarted)
+	require.Equal(t, http.StatusConflict, post(t, mux, "/randomness", `{"input":"0102"}`).Code)
+
+	// round 0 is a round like any other
+	node.err = nil
+	rec = post(t, mux, "/randomness", `{"round":0}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.Equal(t, hex.EncodeToString(timelock.RoundMessage(0)), out.Input)
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/randomness", `{"input":"0102","round":1}`).Code)
+
+	node.err = fmt.Errorf("failed to sign data: %w", timelock.ErrTooEarly)
+	require.Equal(t, http.StatusTooEarly, post(t, mux, "/randomness", `{"round":1}`).Code)
+}
+
+// newGroupNode adds to m a single validator running group id, whose DKG never
+// starts.
+func newGroupNode(t *testing.T, m *group.Manager, id string) {
+	scheme := crypto.DefaultScheme()
+	longterm := scheme.KeyGroup.Scalar().Pick(random.New())
+	net := sim.New(clock.NewVirtual(time.Unix(0, 0)), 1, 0, sim.Link{})
+
+	node, err := dkg.NewNode(t.Context(), &dkg.Config{
+		LongtermKey: longterm,
+		Nonce:       pedersen_dkg.GetNonce(),
+		Scheme:      scheme,
+		Nodes:       []pedersen_dkg.Node{{Index: 0, Public: scheme.KeyGroup.Point().Mul(longterm, nil)}},
+		Threshold:   1,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Clock:       net.Clock(),
+		Transport:   net.Transport(0),
+		Group:       id,
+	}, net.Board(0), nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, m.Add(node, nil))
+}
+
+func TestGroups(t *testing.T) {
+	m := group.NewManager()
+	defer m.Close()
+	newGroupNode(t, m, "prod")
+	newGroupNode(t, m, "staging")
+
+	mux := http.NewServeMux()
+	RegisterGroups(mux, m)
+
+	rec := get(t, mux, "/groups")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var statuses []dkg.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 2)
+	require.Equal(t, "prod", statuses[0].Group)
+	require.Equal(t, "staging", statuses[1].Group)
+
+	rec = get(t, mux, "/groups/staging/status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status dkg.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, "staging", status.Group)
+	require.Equal(t, dkg.DKGNotStarted, status.DKG.State)
+
+	require.Equal(t, http.StatusOK, get(t, mux, "/groups/prod/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get(t, mux, "/groups/prod/readyz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, post(t, mux, "/groups/prod/randomness", `{"input":"01"}`).Code)
+
+	rec = get(t, mux, "/groups/test/status")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), group.ErrUnknownGroup.Error())
+	require.Equal(t, http.StatusNotFound, post(t, mux, "/groups/test/randomness", `{"input":"01"}`).Code)
+	// the endpoints of a single node are not served
+	require.Equal(t, http.StatusNotFound, get(t, mux, "/status").Code)
+}
+
+func TestEpochs(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm := scheme.KeyGroup.Scalar().Pick(random.New())
+	public := scheme.KeyGroup.Point().Mul(longterm, nil)
+	committee := []pedersen_dkg.Node{{Index: 0, Public: public}}
+	clk := clock.NewVirtual(time.Unix(0, 0))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	net := sim.New(clk, 1, 0, sim.Link{})
+	node, err := dkg.NewNode(t.Context(), &dkg.Config{
+		LongtermKey: longterm,
+		Nonce:       pedersen_dkg.GetNonce(),
+		Scheme:      scheme,
+		Nodes:       committee,
+		Threshold:   1,
+		Logger:      logger,
+		Clock:       clk,
+		Transport:   net.Transport(0),
+	}, net.Board(0), nil, nil)
+	require.NoError(t, err)
+	node.StartDKG()
+	net.Run(3 * time.Second)
+	_, err = node.WaitDKG()
+	require.NoError(t, err)
+
+	// the handover never ends: the clock stands still
+	handover := sim.New(clk, 1, 0, sim.Link{})
+	m, err := epoch.New(t.Context(), &epoch.Config{
+		Scheme:   scheme,
+		Longterm: longterm,
+		Clock:    clk,
+		Board: func(prev, next *epoch.Committee) (pedersen_dkg.Board, error) {
+			return handover.Board(0), nil
+		},
+		Logger: logger,
+	}, epoch.Committee{Epoch: 0, FirstRound: 1, Nodes: committee, Threshold: 1}, node)
+	require.NoError(t, err)
+	defer m.Close()
+
+	mux := http.NewServeMux()
+	RegisterEpochs(mux, scheme, m)
+
+	rec := get(t, mux, "/epochs")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var statuses []epoch.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	require.Equal(t, epoch.StateActive, statuses[0].State)
+	require.Len(t, statuses[0].Commits, 1)
+
+	require.Equal(t, http.StatusOK, get(t, mux, "/epochs/0/healthz").Code)
+	require.Equal(t, http.StatusNotFound, get(t, mux, "/epochs/1/status").Code)
+	require.Equal(t, http.StatusNotFound, get(t, mux, "/epochs/x/status").Code)
+	require.Equal(t, http.StatusNotFound, post(t, mux, "/epochs/randomness", `{"round":0}`).Code)
+
+	pub, err := public.MarshalBinary()
+	require.NoError(t, err)
+	// the only member of epoch 0 approves every announcement
+	announce := func(e, firstRound uint64, key string) *httptest.ResponseRecorder {
+		a, err := epoch.Approve(scheme, longterm, &epoch.Committee{Epoch: e, FirstRound: firstRound, Nodes: committee, Threshold: 1})
+		require.NoError(t, err)
+		approval, err := NewApproval(a)
+		require.NoError(t, err)
+		return post(t, mux, "/epochs", fmt.Sprintf(`{"epoch":%d,"first_round":%d,"committee":[%q],"threshold":1,"approvals":[{"public":%q,"signature":%q}]}`,
+			e, firstRound, key, approval.Public, approval.Signature))
+	}
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/epochs", `{`).Code)
+	require.Equal(t, http.StatusBadRequest, announce(1, 10, "zz").Code)
+	require.Equal(t, http.StatusBadRequest, post(t, mux, "/epochs", fmt.Sprintf(`{"epoch":1,"first_round":10,"committee":[%q],"threshold":1,"approvals":[{"public":"zz"}]}`, hex.EncodeToString(pub))).Code)
+	require.Equal(t, http.StatusForbidden, post(t, mux, "/epochs", fmt.Sprintf(`{"epoch":1,"first_round":10,"committee":[%q],"threshold":1}`, hex.EncodeToString(pub))).Code)
+	require.Equal(t, http.StatusBadRequest, announce(2, 10, hex.EncodeToString(pub)).Code)
+
+	rec = announce(1, 10, hex.EncodeToString(pub))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 2)
+	require.Equal(t, epoch.StatePending, statuses[1].State)
+
+	require.Equal(t, http.StatusConflict, announce(2, 20, hex.EncodeToString(pub)).Code)
+	require.Equal(t, http.StatusConflict, post(t, mux, "/epochs/randomness", `{"round":10}`).Code)
+	require.Equal(t, http.StatusNotFound, get(t, mux, "/epochs/1/status").Code)
+}
@@ -0,0 +1,173 @@
+package byzantine
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"random-network-poc/signer"
+
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var _ pedersen_dkg.Board = (*Board)(nil)
+
+// Board wraps the DKG board of a validator and applies the rules of the
+// Deal, Response and Justification kinds to the bundles it pushes. Incoming
+// bundles are left alone.
+type Board struct {
+	inner  pedersen_dkg.Board
+	signer signer.Signer
+
+	mu    sync.Mutex
+	rules []Rule
+	// held are the pushes reordered after the next one.
+	held []func()
+}
+
+// NewBoard wraps inner, signing tampered bundles with the longterm key of s.
+// Rules of the Partial kind are left to Signer.
+func NewBoard(inner pedersen_dkg.Board, s signer.Signer, rules ...Rule) *Board {
+	return &Board{inner: inner, signer: s, rules: rules}
+}
+
+// SetRules replaces the rules applied to the next bundles.
+func (b *Board) SetRules(rules ...Rule) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rules = rules
+}
+
+func (b *Board) PushDeals(bundle *pedersen_dkg.DealBundle) {
+	b.push(Deal, func() { b.inner.PushDeals(bundle) }, func() {
+		if tampered, err := b.corruptDeals(bundle); err == nil {
+			b.inner.PushDeals(tampered)
+		}
+	})
+}
+
+func (b *Board) IncomingDeal() <-chan pedersen_dkg.DealBundle {
+	return b.inner.IncomingDeal()
+}
+
+func (b *Board) PushResponses(bundle *pedersen_dkg.ResponseBundle) {
+	b.push(Response, func() { b.inner.PushResponses(bundle) }, func() {
+		if tampered, err := b.corruptResponses(bundle); err == nil {
+			b.inner.PushResponses(tampered)
+		}
+	})
+}
+
+func (b *Board) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
+	return b.inner.IncomingResponse()
+}
+
+func (b *Board) PushJustifications(bundle *pedersen_dkg.JustificationBundle) {
+	b.push(Justification, func() { b.inner.PushJustifications(bundle) }, func() {
+		if tampered, err := b.corruptJustifications(bundle); err == nil {
+			b.inner.PushJustifications(tampered)
+		}
+	})
+}
+
+func (b *Board) IncomingJustification() <-chan pedersen_dkg.JustificationBundle {
+	return b.inner.IncomingJustification()
+}
+
+// push sends a bundle of kind through send, or its tampered copy through
+// tampered, as the rules say, then sends the bundles held back before it. A
+// bundle that cannot be tampered with is dropped: a validator unable to sign
+// cannot send anything either.
+func (b *Board) push(kind Kind, send, tampered func()) {
+	b.mu.Lock()
+	rule, ok := find(b.rules, kind)
+	if ok && rule.Fault == Reorder {
+		b.held = append(b.held, send)
+		b.mu.Unlock()
+		return
+	}
+	held := b.held
+	b.held = nil
+	b.mu.Unlock()
+
+	switch {
+	case !ok:
+		send()
+	case rule.Fault == Drop:
+	case rule.Fault == Delay:
+		time.AfterFunc(rule.Delay, send)
+	case rule.Fault == Duplicate:
+		send()
+		send()
+	case rule.Fault == Corrupt:
+		tampered()
+	case rule.Fault == Equivocate:
+		send()
+		tampered()
+	}
+
+	for _, send := range held {
+		send()
+	}
+}
+
+// corruptDeals returns a copy of bundle whose encrypted shares no longer
+// decrypt.
+func (b *Board) corruptDeals(bundle *pedersen_dkg.DealBundle) (*pedersen_dkg.DealBundle, error) {
+	tampered := *bundle
+	tampered.Deals = make([]pedersen_dkg.Deal, len(bundle.Deals))
+	for i, deal := range bundle.Deals {
+		share := slices.Clone(deal.EncryptedShare)
+		if len(share) > 0 {
+			share[len(share)-1] ^= 0xff
+		}
+		tampered.Deals[i] = pedersen_dkg.Deal{ShareIndex: deal.ShareIndex, EncryptedShare: share}
+	}
+
+	sig, err := b.sign(&tampered)
+	tampered.Signature = sig
+	return &tampered, err
+}
+
+// corruptResponses returns a copy of bundle with every status flipped.
+func (b *Board) corruptResponses(bundle *pedersen_dkg.ResponseBundle) (*pedersen_dkg.ResponseBundle, error) {
+	tampered := *bundle
+	tampered.Responses = make([]pedersen_dkg.Response, len(bundle.Responses))
+	for i, resp := range bundle.Responses {
+		status := pedersen_dkg.Complaint
+		if resp.Status == pedersen_dkg.Complaint {
+			status = pedersen_dkg.Success
+		}
+		tampered.Responses[i] = pedersen_dkg.Response{DealerIndex: resp.DealerIndex, Status: status}
+	}
+
+	sig, err := b.sign(&tampered)
+	tampered.Signature = sig
+	return &tampered, err
+}
+
+// corruptJustifications returns a copy of bundle whose revealed shares are
+// off by one.
+func (b *Board) corruptJustifications(bundle *pedersen_dkg.JustificationBundle) (*pedersen_dkg.JustificationBundle, error) {
+	tampered := *bundle
+	tampered.Justifications = make([]pedersen_dkg.Justification, len(bundle.Justifications))
+	for i, just := range bundle.Justifications {
+		share := just.Share.Clone()
+		share.Add(share, just.Share.Clone().One())
+		tampered.Justifications[i] = pedersen_dkg.Justification{ShareIndex: just.ShareIndex, Share: share}
+	}
+
+	sig, err := b.sign(&tampered)
+	tampered.Signature = sig
+	return &tampered, err
+}
+
+// sign signs a bundle with the longterm key, as the DKG does.
+func (b *Board) sign(p pedersen_dkg.Packet) ([]byte, error) {
+	hash, err := p.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return b.signer.SignLongterm(hash)
+}
@@ -0,0 +1,114 @@
+// Package byzantine turns a validator into an adversary for tests. Board wraps
+// the DKG board of a validator and Signer its signer, and both misbehave on
+// the messages the validator sends according to a set of rules: they drop,
+// delay, reorder, duplicate, corrupt or equivocate on them. Corrupted and
+// equivocating messages are signed again with the longterm key of the
+// validator, so that they pass authentication and reach the protocol.
+package byzantine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kind is a kind of message sent by a validator.
+type Kind int
+
+const (
+	// Deal is a DKG deal bundle.
+	Deal Kind = iota
+	// Response is a DKG response bundle. Outside of FastSync the DKG only
+	// sends responses carrying complaints.
+	Response
+	// Justification is a DKG justification bundle.
+	Justification
+	// Partial is a partial signature of an RNG round.
+	Partial
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Deal:
+		return "deal"
+	case Response:
+		return "response"
+	case Justification:
+		return "justification"
+	case Partial:
+		return "partial"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Fault is a way to misbehave on a message.
+type Fault int
+
+const (
+	// Drop never sends the message.
+	Drop Fault = iota
+	// Delay sends the message after Rule.Delay.
+	Delay
+	// Reorder holds the message back and sends it after the next message
+	// of the validator. A message with none after it is never sent.
+	Reorder
+	// Duplicate sends the message twice.
+	Duplicate
+	// Corrupt sends a tampered message instead: deals with undecryptable
+	// shares, responses with every status flipped, justifications with
+	// wrong shares and partials with a flipped byte.
+	Corrupt
+	// Equivocate sends the message and a conflicting one, tampered as by
+	// Corrupt. A partial is signed over other data instead, so that it
+	// is a valid signature for the wrong round.
+	Equivocate
+)
+
+func (f Fault) String() string {
+	switch f {
+	case Drop:
+		return "drop"
+	case Delay:
+		return "delay"
+	case Reorder:
+		return "reorder"
+	case Duplicate:
+		return "duplicate"
+	case Corrupt:
+		return "corrupt"
+	case Equivocate:
+		return "equivocate"
+	default:
+		return fmt.Sprintf("fault(%d)", int(f))
+	}
+}
+
+// ErrUnsupported is returned for a fault the wrapper of its kind cannot
+// inject.
+var ErrUnsupported = errors.New("unsupported fault")
+
+// Rule applies a fault to every message of a kind.
+type Rule struct {
+	Kind  Kind
+	Fault Fault
+	// Delay is the delay of the Delay fault.
+	Delay time.Duration
+}
+
+func (r Rule) String() string {
+	if r.Fault == Delay {
+		return fmt.Sprintf("%s %s %s", r.Fault, r.Kind, r.Delay)
+	}
+	return fmt.Sprintf("%s %s", r.Fault, r.Kind)
+}
+
+// find returns the first rule for kind.
+func find(rules []Rule, kind Kind) (Rule, bool) {
+	for _, r := range rules {
+		if r.Kind == kind {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,205 @@
+package byzantine
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/signer"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/share"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+// recordBoard records the bundles pushed to it.
+type recordBoard struct {
+	pushed chan any
+}
+
+func newRecordBoard() *recordBoard {
+	return &recordBoard{pushed: make(chan any, 8)}
+}
+
+func (r *recordBoard) PushDeals(b *pedersen_dkg.DealBundle) {
+	r.pushed <- b
+}
+
+func (r *recordBoard) PushResponses(b *pedersen_dkg.ResponseBundle) {
+	r.pushed <- b
+}
+
+func (r *recordBoard) PushJustifications(b *pedersen_dkg.JustificationBundle) {
+	r.pushed <- b
+}
+
+func (r *recordBoard) IncomingDeal() <-chan pedersen_dkg.DealBundle {
+	return nil
+}
+
+func (r *recordBoard) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
+	return nil
+}
+
+func (r *recordBoard) IncomingJustification() <-chan pedersen_dkg.JustificationBundle {
+	return nil
+}
+
+func (r *recordBoard) next(t *testing.T) any {
+	t.Helper()
+
+	select {
+	case b := <-r.pushed:
+		return b
+	case <-time.After(time.Second):
+		t.Fatal("no bundle pushed")
+		return nil
+	}
+}
+
+func (r *recordBoard) requireEmpty(t *testing.T) {
+	t.Helper()
+	require.Len(t, r.pushed, 0)
+}
+
+func TestBoard(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm, public := scheme.AuthScheme.NewKeyPair(random.New())
+	local := signer.NewLocal(scheme, longterm)
+
+	deal := &pedersen_dkg.DealBundle{
+		DealerIndex: 1,
+		Deals:       []pedersen_dkg.Deal{{ShareIndex: 0, EncryptedShare: []byte{1, 2, 3}}},
+		Public:      []kyber.Point{public},
+		SessionID:   []byte("session"),
+	}
+	resp := &pedersen_dkg.ResponseBundle{
+		ShareIndex: 1,
+		Responses:  []pedersen_dkg.Response{{DealerIndex: 0, Status: pedersen_dkg.Complaint}},
+		SessionID:  []byte("session"),
+	}
+	just := &pedersen_dkg.JustificationBundle{
+		DealerIndex:    1,
+		Justifications: []pedersen_dkg.Justification{{ShareIndex: 0, Share: scheme.KeyGroup.Scalar().SetInt64(7)}},
+		SessionID:      []byte("session"),
+	}
+
+	verify := func(p pedersen_dkg.Packet) {
+		hash, err := p.Hash()
+		require.NoError(t, err)
+		require.NoError(t, scheme.AuthScheme.Verify(public, hash, p.Sig()))
+	}
+
+	inner := newRecordBoard()
+	b := NewBoard(inner, local)
+
+	b.PushDeals(deal)
+	require.Same(t, deal, inner.next(t))
+
+	b.SetRules(Rule{Kind: Deal, Fault: Drop})
+	b.PushDeals(deal)
+	b.PushResponses(resp)
+	require.Same(t, resp, inner.next(t))
+	inner.requireEmpty(t)
+
+	b.SetRules(Rule{Kind: Deal, Fault: Duplicate})
+	b.PushDeals(deal)
+	require.Same(t, deal, inner.next(t))
+	require.Same(t, deal, inner.next(t))
+
+	b.SetRules(Rule{Kind: Deal, Fault: Delay, Delay: 50 * time.Millisecond})
+	start := time.Now()
+	b.PushDeals(deal)
+	require.Same(t, deal, inner.next(t))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// the deal goes out after the response, the justification in between
+	// being reordered too
+	b.SetRules(Rule{Kind: Deal, Fault: Reorder}, Rule{Kind: Justification, Fault: Reorder})
+	b.PushDeals(deal)
+	b.PushJustifications(just)
+	inner.requireEmpty(t)
+	b.PushResponses(resp)
+	require.Same(t, resp, inner.next(t))
+	require.Same(t, deal, inner.next(t))
+	require.Same(t, just, inner.next(t))
+
+	b.SetRules(
+		Rule{Kind: Deal, Fault: Corrupt},
+		Rule{Kind: Response, Fault: Corrupt},
+		Rule{Kind: Justification, Fault: Corrupt},
+	)
+	b.PushDeals(deal)
+	tamperedDeal := inner.next(t).(*pedersen_dkg.DealBundle)
+	require.Equal(t, []byte{1, 2, 3}, deal.Deals[0].EncryptedShare)
+	require.NotEqual(t, deal.Deals[0].EncryptedShare, tamperedDeal.Deals[0].EncryptedShare)
+	verify(tamperedDeal)
+
+	b.PushResponses(resp)
+	tamperedResp := inner.next(t).(*pedersen_dkg.ResponseBundle)
+	require.Equal(t, pedersen_dkg.Complaint, resp.Responses[0].Status)
+	require.Equal(t, pedersen_dkg.Success, tamperedResp.Responses[0].Status)
+	verify(tamperedResp)
+
+	b.PushJustifications(just)
+	tamperedJust := inner.next(t).(*pedersen_dkg.JustificationBundle)
+	require.True(t, just.Justifications[0].Share.Equal(scheme.KeyGroup.Scalar().SetInt64(7)))
+	require.True(t, tamperedJust.Justifications[0].Share.Equal(scheme.KeyGroup.Scalar().SetInt64(8)))
+	verify(tamperedJust)
+
+	// both versions are validly signed, with different hashes
+	b.SetRules(Rule{Kind: Deal, Fault: Equivocate})
+	b.PushDeals(deal)
+	require.Same(t, deal, inner.next(t))
+	equivocated := inner.next(t).(*pedersen_dkg.DealBundle)
+	verify(equivocated)
+	hash, err := deal.Hash()
+	require.NoError(t, err)
+	other, err := equivocated.Hash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other)
+}
+
+func TestSigner(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm, _ := scheme.AuthScheme.NewKeyPair(random.New())
+
+	secret := scheme.KeyGroup.Scalar().Pick(random.New())
+	poly := share.NewPriPoly(scheme.KeyGroup, 2, secret, random.New())
+	pub := poly.Commit(nil)
+
+	local := signer.NewLocal(scheme, longterm)
+	require.NoError(t, local.SetShare(poly.Shares(3)[1]))
+
+	_, err := NewSigner(local, Rule{Kind: Partial, Fault: Duplicate})
+	require.ErrorIs(t, err, ErrUnsupported)
+
+	// rules of the board are ignored
+	s, err := NewSigner(local, Rule{Kind: Deal, Fault: Corrupt})
+	require.NoError(t, err)
+
+	msg := []byte("round")
+	partial, err := s.SignPartial(crypto.PurposeVRF, msg)
+	require.NoError(t, err)
+	require.NoError(t, scheme.ThresholdScheme.VerifyPartial(pub, msg, partial))
+
+	require.NoError(t, s.SetRules(Rule{Kind: Partial, Fault: Drop}))
+	_, err = s.SignPartial(crypto.PurposeVRF, msg)
+	require.ErrorIs(t, err, errDropped)
+
+	require.NoError(t, s.SetRules(Rule{Kind: Partial, Fault: Corrupt}))
+	partial, err = s.SignPartial(crypto.PurposeVRF, msg)
+	require.NoError(t, err)
+	index, err := scheme.ThresholdScheme.IndexOf(partial)
+	require.NoError(t, err)
+	require.Equal(t, 1, index)
+	require.Error(t, scheme.ThresholdScheme.VerifyPartial(pub, msg, partial))
+
+	require.NoError(t, s.SetRules(Rule{Kind: Partial, Fault: Equivocate}))
+	partial, err = s.SignPartial(crypto.PurposeVRF, msg)
+	require.NoError(t, err)
+	require.Error(t, scheme.ThresholdScheme.VerifyPartial(pub, msg, partial))
+	require.NoError(t, scheme.ThresholdScheme.VerifyPartial(pub, []byte("roundequivocate"), partial))
+}
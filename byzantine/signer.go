@@ -0,0 +1,78 @@
+package byzantine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"random-network-poc/signer"
+)
+
+// errDropped is returned for the partial signatures a Drop rule withholds,
+// which the RNG protocol then never sends.
+var errDropped = errors.New("partial signature dropped")
+
+var _ signer.Signer = (*Signer)(nil)
+
+// Signer wraps the signer of a validator and applies the rules of the Partial
+// kind to the partial signatures it makes. Reorder and Duplicate are not
+// supported: partials go to the aggregator of their round only, which drops
+// duplicates and does not care about order.
+type Signer struct {
+	signer.Signer
+
+	mu    sync.Mutex
+	rules []Rule
+}
+
+// NewSigner wraps inner. Rules of the other kinds are left to Board.
+func NewSigner(inner signer.Signer, rules ...Rule) (*Signer, error) {
+	s := &Signer{Signer: inner}
+	if err := s.SetRules(rules...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetRules replaces the rules applied to the next partial signatures.
+func (s *Signer) SetRules(rules ...Rule) error {
+	if r, ok := find(rules, Partial); ok && (r.Fault == Reorder || r.Fault == Duplicate) {
+		return fmt.Errorf("%w: %s", ErrUnsupported, r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = rules
+	return nil
+}
+
+func (s *Signer) SignPartial(purpose string, msg []byte) ([]byte, error) {
+	s.mu.Lock()
+	rule, ok := find(s.rules, Partial)
+	s.mu.Unlock()
+
+	if !ok {
+		return s.Signer.SignPartial(purpose, msg)
+	}
+
+	switch rule.Fault {
+	case Drop:
+		return nil, errDropped
+	case Delay:
+		time.Sleep(rule.Delay)
+	case Corrupt:
+		sig, err := s.Signer.SignPartial(purpose, msg)
+		if err != nil {
+			return nil, err
+		}
+		// the share index in front is left alone
+		sig[len(sig)-1] ^= 0xff
+		return sig, nil
+	case Equivocate:
+		return s.Signer.SignPartial(purpose, append(slices.Clip(msg), "equivocate"...))
+	}
+	return s.Signer.SignPartial(purpose, msg)
+}
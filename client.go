@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"random-network-poc/admin"
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/epoch"
+	"random-network-poc/keystore"
+
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// request asks a running validator for a round and prints its output.
+func request(args []string) {
+	fs := flag.NewFlagSet("request", flag.ExitOnError)
+	node := fs.String("node", "http://127.0.0.1:9101", "Admin address of a validator")
+	groupID := fs.String("group", "", "Group of a validator running -groups")
+	inputHex := fs.String("input", "", "Input to sign in hex format (defaults to a block input with a random seed)")
+	round := fs.Uint64("round", 0, "Beacon round to sign for timelock decryption, refused before it is due (replaces -input)")
+	epochs := fs.Bool("epochs", false, "Have -round signed by the committee of its epoch, on a validator running -epochs")
+	timeout := fs.Duration("timeout", admin.RequestTimeout, "Timeout of the request")
+	fs.Parse(args)
+	hasRound := isSet(fs, "round")
+
+	client := &http.Client{Timeout: *timeout}
+	if *epochs {
+		if !hasRound {
+			fatal("Round is required", errors.New("-epochs needs -round"))
+		}
+		body, err := json.Marshal(admin.RoundRequest{Round: *round})
+		if err != nil {
+			fatal("Failed to encode request", err)
+		}
+		data, err := postJSON(client, adminURL(*node, "", "epochs/randomness"), body)
+		if err != nil {
+			fatal("Request failed", err)
+		}
+		printJSON(data)
+		return
+	}
+
+	var req admin.Request
+	var input []byte
+	var err error
+	switch {
+	case hasRound:
+		req.Round = round
+	case *inputHex != "":
+		input, err = dkg.HexToBytes(*inputHex)
+		if err != nil {
+			fatal("Failed to decode input", err)
+		}
+	default:
+		input, err = dkg.BlockInput(make([]byte, sha256.Size), 1, pedersen_dkg.GetNonce())
+		if err != nil {
+			fatal("Failed to build VRF input", err)
+		}
+	}
+
+	if input != nil {
+		req.Input = hex.EncodeToString(input)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		fatal("Failed to encode request", err)
+	}
+
+	data, err := postJSON(client, adminURL(*node, *groupID, "randomness"), body)
+	if err != nil {
+		fatal("Request failed", err)
+	}
+	printJSON(data)
+}
+
+// status prints the status of a running validator.
+func status(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	node := fs.String("node", "http://127.0.0.1:9101", "Admin address of a validator")
+	groupID := fs.String("group", "", "Group of a validator running -groups")
+	epochs := fs.Bool("epochs", false, "List the epochs of a validator running -epochs")
+	epochN := fs.Int64("epoch", -1, "Epoch of a validator running -epochs (-1 for none)")
+	fs.Parse(args)
+
+	endpoint := "status"
+	switch {
+	case *epochs:
+		endpoint = "epochs"
+	case *epochN >= 0:
+		endpoint = fmt.Sprintf("epochs/%d/status", *epochN)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	data, err := getJSON(client, adminURL(*node, *groupID, endpoint))
+	if err != nil {
+		fatal("Status failed", err)
+	}
+	printJSON(data)
+}
+
+// nextFlags are the flags of the committee of the next epoch, shared by
+// approve and announce.
+type nextFlags struct {
+	epoch         *uint64
+	firstRound    *uint64
+	committee     *string
+	committeeFile *string
+	threshold     *int
+}
+
+func newNextFlags(fs *flag.FlagSet) *nextFlags {
+	return &nextFlags{
+		epoch:         fs.Uint64("epoch", 0, "Epoch of the next committee, the current one plus 1 (or the epoch of a failed handover, to retry it)"),
+		firstRound:    fs.Uint64("first-round", 0, "First round the next committee signs"),
+		committee:     fs.String("committee", "", "Comma separated public keys of the next committee in hex format"),
+		committeeFile: fs.String("committee-file", "", "File of the public keys of the next committee, as for run (replaces -committee)"),
+		threshold:     fs.Int("threshold", 0, "Threshold of the next committee"),
+	}
+}
+
+// keys returns the public keys of the next committee, or exits when the flags
+// are missing.
+func (f *nextFlags) keys() []string {
+	if *f.epoch == 0 || *f.threshold == 0 || *f.committee == "" && *f.committeeFile == "" {
+		fatal("Missing flags", errors.New("-epoch, -committee or -committee-file and -threshold are required"))
+	}
+	if *f.committeeFile == "" {
+		return strings.Split(*f.committee, ",")
+	}
+	keys, err := readCommitteeFile(*f.committeeFile)
+	if err != nil {
+		fatal("Failed to read committee", err)
+	}
+	return keys
+}
+
+// approve signs the announcement of the committee of the next epoch with the
+// longterm key of a member of the current committee, and prints the approval
+// as a JSON line for announce -approvals.
+func approve(args []string) {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	in := fs.String("keystore", "keystore.json", "Keystore file of a member of the current committee")
+	passwordFile := fs.String("password-file", "", "File holding the keystore password (defaults to $"+passwordEnv+")")
+	network := fs.String("network", crypto.DefaultNetwork, "Network name, part of the domain separation tag of every signature")
+	next := newNextFlags(fs)
+	fs.Parse(args)
+
+	keys := next.keys()
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		fatal("Failed to read password", err)
+	}
+	key, err := keystore.Load(*in, password)
+	if err != nil {
+		fatal("Failed to load keystore", err)
+	}
+	scheme, err := crypto.ParseSchemeWithDomain(key.Scheme.Name, crypto.Domain{Network: *network, Purpose: crypto.PurposeVRF})
+	if err != nil {
+		fatal("Failed to parse scheme", err)
+	}
+	nodes, err := dkg.ParseNodes(scheme, keys)
+	if err != nil {
+		fatal("Failed to parse committee", err)
+	}
+
+	a, err := epoch.Approve(scheme, key.Longterm, &epoch.Committee{
+		Epoch:      *next.epoch,
+		FirstRound: *next.firstRound,
+		Nodes:      nodes,
+		Threshold:  *next.threshold,
+	})
+	if err != nil {
+		fatal("Failed to approve", err)
+	}
+	approval, err := admin.NewApproval(a)
+	if err != nil {
+		fatal("Failed to encode approval", err)
+	}
+	data, err := json.Marshal(approval)
+	if err != nil {
+		fatal("Failed to encode approval", err)
+	}
+	fmt.Println(string(data))
+}
+
+// readApprovals reads the approvals printed by approve, one per line.
+func readApprovals(path string) ([]admin.Approval, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var approvals []admin.Approval
+	dec := json.NewDecoder(file)
+	for {
+		var a admin.Approval
+		if err := dec.Decode(&a); err == io.EOF {
+			return approvals, nil
+		} else if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+}
+
+// announce announces the committee of the next epoch to every validator of
+// the current and next committees, with the commits of the current epoch
+// taken from a member and the approvals of the current committee.
+func announce(args []string) {
+	fs := flag.NewFlagSet("announce", flag.ExitOnError)
+	nodes := fs.String("nodes", "", "Comma separated admin addresses of every validator of the current and next committees")
+	approvalsFile := fs.String("approvals", "", "File of the approvals of a threshold of the current committee, the lines printed by approve")
+	next := newNextFlags(fs)
+	fs.Parse(args)
+
+	if *nodes == "" || *approvalsFile == "" {
+		fatal("Missing flags", errors.New("-nodes and -approvals are required"))
+	}
+	keys := next.keys()
+	approvals, err := readApprovals(*approvalsFile)
+	if err != nil {
+		fatal("Failed to read approvals", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	addrs := strings.Split(*nodes, ",")
+
+	// validators joining the group need the public polynomial of the key
+	var commits []string
+	for _, addr := range addrs {
+		data, err := getJSON(client, adminURL(addr, "", "epochs"))
+		if err != nil {
+			fatal("Failed to query epochs of "+addr, err)
+		}
+		var statuses []epoch.Status
+		if err := json.Unmarshal(data, &statuses); err != nil {
+			fatal("Failed to decode epochs of "+addr, err)
+		}
+		for _, s := range statuses {
+			if s.Epoch == *next.epoch-1 && len(s.Commits) > 0 {
+				commits = s.Commits
+			}
+		}
+	}
+	if commits == nil {
+		fatal("Failed to find the commits of the current epoch", fmt.Errorf("no member of epoch %d among -nodes", *next.epoch-1))
+	}
+
+	body, err := json.Marshal(admin.Announcement{
+		Epoch:      *next.epoch,
+		FirstRound: *next.firstRound,
+		Committee:  keys,
+		Threshold:  *next.threshold,
+		Commits:    commits,
+		Approvals:  approvals,
+	})
+	if err != nil {
+		fatal("Failed to encode announcement", err)
+	}
+
+	// every validator starts the handover at about the same time
+	var wg sync.WaitGroup
+	errs := make([]error, len(addrs))
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = postJSON(client, adminURL(addr, "", "epochs"), body)
+		}()
+	}
+	wg.Wait()
+
+	failed := false
+	for i, err := range errs {
+		if err != nil {
+			slog.Error("Failed to announce", "node", addrs[i], "err", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Printf("announced epoch %d from round %d to %d validators\n", *next.epoch, *next.firstRound, len(addrs))
+}
+
+// postJSON returns the response to a POST of body to url.
+func postJSON(client *http.Client, url string, body []byte) ([]byte, error) {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return readResponse(resp)
+}
+
+// getJSON returns the response to a GET of url.
+func getJSON(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return readResponse(resp)
+}
+
+// printJSON prints an indented JSON response.
+func printJSON(data []byte) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		fatal("Failed to decode response", err)
+	}
+	out.WriteTo(os.Stdout)
+}
+
+// isSet reports whether the flag name was given, whatever its value.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// adminURL returns the URL of an admin endpoint of a validator, of group
+// unless empty.
+func adminURL(node, group, endpoint string) string {
+	url := strings.TrimSuffix(node, "/")
+	if group != "" {
+		url += "/groups/" + group
+	}
+	return url + "/" + endpoint
+}
+
+// readResponse returns the body of a successful response, or the error
+// message of a failed one.
+func readResponse(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status + ": " + strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
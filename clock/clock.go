@@ -0,0 +1,37 @@
+// Package clock abstracts the time source of the DKG phases and RNG
+// deadlines, so simulations can run them on virtual time.
+package clock
+
+import "time"
+
+// Clock tells the time and runs functions after a delay.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel receiving the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f once d has passed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call of AfterFunc.
+type Timer interface {
+	// Stop cancels the call, reporting whether it was still pending.
+	Stop() bool
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,164 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Virtual is a clock that only moves when told to. Functions of AfterFunc run
+// on the goroutine moving the clock, in the order of their deadline and, for
+// equal deadlines, of their scheduling, so a run only depends on the order of
+// the calls made to the clock.
+type Virtual struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers timerHeap
+}
+
+var _ Clock = (*Virtual)(nil)
+
+// NewVirtual returns a virtual clock set to start.
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.now
+}
+
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	v.AfterFunc(d, func() { ch <- v.Now() })
+	return ch
+}
+
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if d < 0 {
+		d = 0
+	}
+	v.seq++
+	t := &virtualTimer{clock: v, at: v.now.Add(d), seq: v.seq, f: f, index: -1}
+	heap.Push(&v.timers, t)
+	return t
+}
+
+// Next returns the deadline of the earliest pending timer.
+func (v *Virtual) Next() (time.Time, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.timers) == 0 {
+		return time.Time{}, false
+	}
+	return v.timers[0].at, true
+}
+
+// Pending returns the number of pending timers.
+func (v *Virtual) Pending() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.timers)
+}
+
+// Step moves the clock to the earliest pending timer and runs it, reporting
+// whether there was one.
+func (v *Virtual) Step() bool {
+	v.mu.Lock()
+	if len(v.timers) == 0 {
+		v.mu.Unlock()
+		return false
+	}
+	t := heap.Pop(&v.timers).(*virtualTimer)
+	if t.at.After(v.now) {
+		v.now = t.at
+	}
+	v.mu.Unlock()
+
+	t.f()
+	return true
+}
+
+// Advance moves the clock by d, running every timer due meanwhile, including
+// the ones scheduled by the timers it runs.
+func (v *Virtual) Advance(d time.Duration) {
+	v.AdvanceTo(v.Now().Add(d))
+}
+
+// AdvanceTo moves the clock to t, see Advance.
+func (v *Virtual) AdvanceTo(t time.Time) {
+	for {
+		next, ok := v.Next()
+		if !ok || next.After(t) {
+			break
+		}
+		v.Step()
+	}
+
+	v.mu.Lock()
+	if t.After(v.now) {
+		v.now = t
+	}
+	v.mu.Unlock()
+}
+
+type virtualTimer struct {
+	clock *Virtual
+	at    time.Time
+	seq   uint64
+	f     func()
+	// index in the heap, -1 once fired or stopped
+	index int
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.clock.timers, t.index)
+	return true
+}
+
+// timerHeap orders timers by deadline, then by scheduling.
+type timerHeap []*virtualTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*virtualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,38 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtual(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := NewVirtual(start)
+
+	var order []string
+	v.AfterFunc(2*time.Second, func() { order = append(order, "b") })
+	v.AfterFunc(time.Second, func() {
+		order = append(order, "a")
+		// scheduled while running, still due within the advance
+		v.AfterFunc(time.Second, func() { order = append(order, "c") })
+	})
+	stopped := v.AfterFunc(time.Second, func() { order = append(order, "stopped") })
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+	after := v.After(3 * time.Second)
+
+	v.Advance(1500 * time.Millisecond)
+	require.Equal(t, []string{"a"}, order)
+	require.Equal(t, start.Add(1500*time.Millisecond), v.Now())
+
+	v.Advance(time.Second)
+	// equal deadlines run in the order they were scheduled
+	require.Equal(t, []string{"a", "b", "c"}, order)
+	require.Equal(t, 1, v.Pending())
+
+	require.True(t, v.Step())
+	require.Equal(t, start.Add(3*time.Second), <-after)
+	require.False(t, v.Step())
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/timelock"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  timelock encrypt -pub <hex> -round <n> [-scheme name] [-network name] [-in file] [-out file]
+  timelock decrypt -pub <hex> -sig <hex> [-scheme name] [-network name] [-in file] [-out file]
+`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "encrypt":
+		encrypt(os.Args[2:])
+	case "decrypt":
+		decrypt(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func encrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	pub := fs.String("pub", "", "Group public key in hex format")
+	round := fs.Uint64("round", 0, "Beacon round to lock the message to")
+	schemeName := fs.String("scheme", crypto.DefaultSchemeName, "Cryptographic scheme of the network")
+	network := fs.String("network", crypto.DefaultNetwork, "Network name used in the domain separation tag")
+	in := fs.String("in", "", "Input file (default stdin)")
+	out := fs.String("out", "", "Output file (default stdout)")
+	fs.Parse(args)
+
+	scheme := mustScheme(*schemeName, *network)
+	public := mustGroupKey(scheme, *pub)
+
+	msg, err := readInput(*in)
+	if err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+
+	ct, err := timelock.Encrypt(scheme, public, *round, msg)
+	if err != nil {
+		log.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	data, err := timelock.CiphertextToJSON(ct)
+	if err != nil {
+		log.Fatalf("Failed to encode ciphertext: %v", err)
+	}
+
+	if err := writeOutput(*out, data); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+}
+
+func decrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	pub := fs.String("pub", "", "Group public key in hex format")
+	sig := fs.String("sig", "", "Threshold BLS signature of the round in hex format")
+	schemeName := fs.String("scheme", crypto.DefaultSchemeName, "Cryptographic scheme of the network")
+	network := fs.String("network", crypto.DefaultNetwork, "Network name used in the domain separation tag")
+	in := fs.String("in", "", "Input file (default stdin)")
+	out := fs.String("out", "", "Output file (default stdout)")
+	fs.Parse(args)
+
+	scheme := mustScheme(*schemeName, *network)
+	public := mustGroupKey(scheme, *pub)
+
+	signature, err := dkg.HexToBytes(*sig)
+	if err != nil {
+		log.Fatalf("Failed to decode signature: %v", err)
+	}
+
+	data, err := readInput(*in)
+	if err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+
+	ct, err := timelock.CiphertextFromJSON(scheme, data)
+	if err != nil {
+		log.Fatalf("Failed to decode ciphertext: %v", err)
+	}
+
+	msg, err := timelock.Decrypt(scheme, public, signature, ct)
+	if err != nil {
+		log.Fatalf("Failed to decrypt: %v", err)
+	}
+
+	if err := writeOutput(*out, msg); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+}
+
+func mustScheme(name, network string) *crypto.Scheme {
+	scheme, err := crypto.ParseSchemeWithDomain(name, crypto.Domain{Network: network, Purpose: crypto.PurposeRound})
+	if err != nil {
+		log.Fatalf("Failed to parse scheme: %v", err)
+	}
+	return scheme
+}
+
+func mustGroupKey(scheme *crypto.Scheme, hexStr string) kyber.Point {
+	if hexStr == "" {
+		log.Fatal("Group public key is required")
+	}
+
+	pubBytes, err := dkg.HexToBytes(hexStr)
+	if err != nil {
+		log.Fatalf("Failed to decode group public key: %v", err)
+	}
+
+	public, err := scheme.PointFromBytes(pubBytes)
+	if err != nil {
+		log.Fatalf("Failed to unmarshal group public key: %v", err)
+	}
+
+	return public
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+pragma solidity ^0.8.20;
+
+/// @title RandomnessVerifier
+/// @notice Reference verifier for randomness produced by a network running the
+/// bn254-g1 scheme. The input is hashed to G1 on chain (RFC 9380 SVDW with
+/// expand_message_xmd over keccak256 and the network DST), so a signature
+/// cannot be replayed for another input or network. Arguments match
+/// dkg.EVMProof.
+contract RandomnessVerifier {
+    // Base field modulus of BN254.
+    uint256 internal constant P = 21888242871839275222246405745257275088696311157297823662689037894645226208583;
+
+    // 2^256 mod P, used to reduce 48 byte field elements.
+    uint256 internal constant R256 = (type(uint256).max % P) + 1;
+
+    // SVDW constants for y^2 = x^3 + 3 with Z = 1.
+    uint256 internal constant C1 = 4;
+    uint256 internal constant C2 = 10944121435919637611123202872628637544348155578648911831344518947322613104291;
+    uint256 internal constant C3 = 8815841940592487685674414971303048083897117035520822607866;
+    uint256 internal constant C4 = 7296080957279758407415468581752425029565437052432607887563012631548408736189;
+
+    // G2 generator in precompile order: x imaginary, x real, y imaginary, y real.
+    uint256 internal constant G2_X_IM = 11559732032986387107991004021392285783925812861821192530917403151452391805634;
+    uint256 internal constant G2_X_RE = 10857046999023057135944570762232829481370756359578518086990519993285655852781;
+    uint256 internal constant G2_Y_IM = 4082367875863433681332203403145435568316851327593401208105741076214120093531;
+    uint256 internal constant G2_Y_RE = 8495653923123431417604973247489272438418190587263600148770280649306958101930;
+
+    uint256[4] public groupKey;
+
+    // Domain separation tag of the network, crypto.Scheme.DST.
+    bytes public dst;
+
+    constructor(uint256[4] memory _groupKey, bytes memory _dst) {
+        require(_dst.length > 0 && _dst.length <= 255, "invalid dst");
+        groupKey = _groupKey;
+        dst = _dst;
+    }
+
+    /// @notice Checks e(-signature, G2) * e(H(input), groupKey) == 1.
+    function verify(bytes calldata input, uint256[2] calldata signature) public view returns (bool) {
+        uint256[2] memory h = hashToPoint(input);
+
+        uint256[12] memory pairing = [
+            signature[0],
+            (P - signature[1] % P) % P,
+            G2_X_IM,
+            G2_X_RE,
+            G2_Y_IM,
+            G2_Y_RE,
+            h[0],
+            h[1],
+            groupKey[0],
+            groupKey[1],
+            groupKey[2],
+            groupKey[3]
+        ];
+
+        uint256[1] memory out;
+        bool ok;
+        assembly {
+            ok := staticcall(gas(), 0x08, pairing, 384, out, 32)
+        }
+        return ok && out[0] == 1;
+    }
+
+    /// @notice Returns the random value of a valid signature, equal to
+    /// sha256 of the uncompressed signature as computed by the nodes.
+    function randomness(bytes calldata input, uint256[2] calldata signature) external view returns (uint256) {
+        require(verify(input, signature), "invalid signature");
+        return uint256(sha256(abi.encodePacked(signature[0], signature[1])));
+    }
+
+    function hashToPoint(bytes memory input) public view returns (uint256[2] memory) {
+        bytes memory u = expandMsg(input);
+        uint256[2] memory p0 = mapToPoint(reduce(u, 0));
+        uint256[2] memory p1 = mapToPoint(reduce(u, 48));
+
+        uint256[4] memory sum = [p0[0], p0[1], p1[0], p1[1]];
+        uint256[2] memory out;
+        bool ok;
+        assembly {
+            ok := staticcall(gas(), 0x06, sum, 128, out, 64)
+        }
+        require(ok, "ecAdd failed");
+        return out;
+    }
+
+    function expandMsg(bytes memory input) internal view returns (bytes memory) {
+        bytes memory dstPrime = abi.encodePacked(dst, uint8(dst.length));
+
+        bytes32 b0 = keccak256(abi.encodePacked(new bytes(136), input, uint16(96), uint8(0), dstPrime));
+        bytes32 b1 = keccak256(abi.encodePacked(b0, uint8(1), dstPrime));
+        bytes32 b2 = keccak256(abi.encodePacked(b0 ^ b1, uint8(2), dstPrime));
+        bytes32 b3 = keccak256(abi.encodePacked(b0 ^ b2, uint8(3), dstPrime));
+
+        return abi.encodePacked(b1, b2, b3);
+    }
+
+    // reduce interprets 48 bytes of u at offset as a big-endian integer mod P.
+    function reduce(bytes memory u, uint256 offset) internal pure returns (uint256) {
+        uint256 hi;
+        uint256 lo;
+        assembly {
+            hi := shr(128, mload(add(add(u, 32), offset)))
+            lo := mload(add(add(u, 48), offset))
+        }
+        return addmod(mulmod(hi, R256, P), lo % P, P);
+    }
+
+    function mapToPoint(uint256 u) internal view returns (uint256[2] memory) {
+        uint256 tv1 = mulmod(mulmod(u, u, P), C1, P);
+        uint256 tv2 = addmod(1, tv1, P);
+        tv1 = addmod(1, P - tv1, P);
+        uint256 tv3 = expMod(mulmod(tv1, tv2, P), P - 2);
+        uint256 tv5 = mulmod(mulmod(mulmod(u, tv1, P), tv3, P), C3, P);
+        uint256 x1 = addmod(C2, P - tv5, P);
+        uint256 x2 = addmod(C2, tv5, P);
+        uint256 tv8 = mulmod(mulmod(tv2, tv2, P), tv3, P);
+        uint256 x3 = addmod(1, mulmod(C4, mulmod(tv8, tv8, P), P), P);
+
+        uint256 x;
+        if (isSquare(curve(x1))) {
+            x = x1;
+        } else if (isSquare(curve(x2))) {
+            x = x2;
+        } else {
+            x = x3;
+        }
+
+        uint256 y = expMod(curve(x), (P + 1) / 4);
+        if ((u & 1) != (y & 1)) {
+            y = (P - y) % P;
+        }
+        return [x, y];
+    }
+
+    function curve(uint256 x) internal pure returns (uint256) {
+        return addmod(mulmod(mulmod(x, x, P), x, P), 3, P);
+    }
+
+    function isSquare(uint256 x) internal view returns (bool) {
+        return expMod(x, (P - 1) / 2) == 1;
+    }
+
+    function expMod(uint256 base, uint256 e) internal view returns (uint256) {
+        uint256[6] memory input = [uint256(32), 32, 32, base, e, P];
+        uint256[1] memory out;
+        bool ok;
+        assembly {
+            ok := staticcall(gas(), 0x05, input, 192, out, 32)
+        }
+        require(ok, "modexp failed");
+        return out[0];
+    }
+}
@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/sign"
+)
+
+// DomainVersion is part of every domain separation tag. Bump it whenever the
+// layout of signed inputs changes so old and new signatures never verify
+// against each other.
+const DomainVersion = 1
+
+const (
+	DefaultNetwork = "mainnet"
+	// PurposeVRF signs the inputs of clients.
+	PurposeVRF = "VRF"
+	// PurposeRound signs the numbered rounds of the beacon, whose signatures
+	// open the ciphertexts of package timelock.
+	PurposeRound = "ROUND"
+)
+
+const (
+	// HashTryAndIncrement is kyber's legacy BN256 hash: sha256 of the input
+	// and a counter until a point is found. Not constant time and not
+	// standardised, the domain tag is prefixed to the input.
+	HashTryAndIncrement = "try-and-increment"
+	// HashRFC9380 is the hash_to_curve of RFC 9380 with the domain tag as
+	// DST.
+	HashRFC9380 = "rfc9380"
+)
+
+const maxDSTLength = 255
+
+// Domain names what a network signs. Two schemes with different domains
+// hash the same input to unrelated points.
+type Domain struct {
+	Network string
+	Purpose string
+}
+
+// DefaultDomain returns the domain of the client inputs of the default
+// network.
+func DefaultDomain() Domain {
+	return Domain{Network: DefaultNetwork, Purpose: PurposeVRF}
+}
+
+// Tag returns the domain separation tag for a hash to curve suite, following
+// the RFC 9380 recommendation "<app>-V<version>-<...>-<suite ID>".
+func (d Domain) Tag(suiteID string) ([]byte, error) {
+	for _, part := range []string{d.Network, d.Purpose} {
+		if part == "" || strings.Contains(part, "-") {
+			return nil, fmt.Errorf("invalid domain %q, network and purpose must be non-empty and contain no '-'", d.Network+"/"+d.Purpose)
+		}
+	}
+
+	tag := fmt.Sprintf("RNN-V%02d-%s-%s-%s", DomainVersion, d.Network, d.Purpose, suiteID)
+	if len(tag) > maxDSTLength {
+		return nil, fmt.Errorf("domain separation tag is longer than %d bytes", maxDSTLength)
+	}
+	return []byte(tag), nil
+}
+
+// DomainInput returns the bytes hashed to the signature group when msg is
+// signed. RFC 9380 suites carry the tag themselves, the legacy hash gets it
+// prefixed with its length.
+func (s *Scheme) DomainInput(msg []byte) []byte {
+	if s.HashToCurve == HashRFC9380 {
+		return msg
+	}
+	out := make([]byte, 0, 1+len(s.DST)+len(msg))
+	out = append(out, byte(len(s.DST)))
+	out = append(out, s.DST...)
+	return append(out, msg...)
+}
+
+// domainScheme applies the domain of a legacy scheme before signing and
+// verifying.
+type domainScheme struct {
+	scheme *Scheme
+	inner  sign.Scheme
+}
+
+func (d *domainScheme) NewKeyPair(random cipher.Stream) (kyber.Scalar, kyber.Point) {
+	return d.inner.NewKeyPair(random)
+}
+
+func (d *domainScheme) Sign(private kyber.Scalar, msg []byte) ([]byte, error) {
+	return d.inner.Sign(private, d.scheme.DomainInput(msg))
+}
+
+func (d *domainScheme) Verify(public kyber.Point, msg, sig []byte) error {
+	return d.inner.Verify(public, d.scheme.DomainInput(msg), sig)
+}
+
+// domainThresholdScheme is the threshold counterpart of domainScheme.
+type domainThresholdScheme struct {
+	scheme *Scheme
+	inner  sign.ThresholdScheme
+}
+
+func (d *domainThresholdScheme) Sign(private *share.PriShare, msg []byte) ([]byte, error) {
+	return d.inner.Sign(private, d.scheme.DomainInput(msg))
+}
+
+func (d *domainThresholdScheme) IndexOf(signature []byte) (int, error) {
+	return d.inner.IndexOf(signature)
+}
+
+func (d *domainThresholdScheme) Recover(public *share.PubPoly, msg []byte, sigs [][]byte, t, n int) ([]byte, error) {
+	return d.inner.Recover(public, d.scheme.DomainInput(msg), sigs, t, n)
+}
+
+func (d *domainThresholdScheme) VerifyPartial(public *share.PubPoly, msg, sig []byte) error {
+	return d.inner.VerifyPartial(public, d.scheme.DomainInput(msg), sig)
+}
+
+func (d *domainThresholdScheme) VerifyRecovered(public kyber.Point, msg, sig []byte) error {
+	return d.inner.VerifyRecovered(public, d.scheme.DomainInput(msg), sig)
+}
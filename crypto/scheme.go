@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/pairing"
+	"go.dedis.ch/kyber/v4/pairing/bls12381/kilic"
+	"go.dedis.ch/kyber/v4/pairing/bn254"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/sign"
+	"go.dedis.ch/kyber/v4/sign/bls"
+	"go.dedis.ch/kyber/v4/sign/schnorr"
+	"go.dedis.ch/kyber/v4/sign/tbls"
+)
+
+const (
+	CurveBN256    = "bn256"
+	CurveBN254    = "bn254"
+	CurveBLS12381 = "bls12381"
+
+	GroupG1 = "g1"
+	GroupG2 = "g2"
+)
+
+// DefaultSchemeName is the scheme the network has used since the first DKG:
+// BN256 with keys on G2 and signatures on G1.
+const DefaultSchemeName = CurveBN256 + "-" + GroupG1
+
+// Scheme bundles every cryptographic choice of the network: the pairing
+// curve, which of its groups carries keys and which carries signatures, and
+// the signature schemes built on top of them.
+type Scheme struct {
+	Name     string
+	Curve    string
+	SigOnG1  bool
+	Pairing  pairing.Suite
+	KeyGroup pedersen_dkg.Suite
+	SigGroup kyber.Group
+
+	// Domain and DST separate the signatures of this network and purpose
+	// from any other use of the same keys.
+	Domain Domain
+	DST    []byte
+	// HashToCurve is the method hashing inputs to the signature group.
+	HashToCurve string
+
+	// ThresholdScheme signs, verifies and recovers partial signatures.
+	ThresholdScheme sign.ThresholdScheme
+	// SigScheme verifies recovered signatures against the group public key.
+	SigScheme sign.Scheme
+	// AuthScheme signs DKG bundles with the longterm keys.
+	AuthScheme sign.Scheme
+}
+
+// DefaultScheme returns the BN256 scheme with signatures on G1.
+func DefaultScheme() *Scheme {
+	s, err := NewScheme(CurveBN256, GroupG1)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ParseScheme builds a scheme from a name such as "bn256-g1" or
+// "bls12381-g2", where the suffix is the signature group.
+func ParseScheme(name string) (*Scheme, error) {
+	return ParseSchemeWithDomain(name, DefaultDomain())
+}
+
+// ParseSchemeWithDomain is ParseScheme for the given domain.
+func ParseSchemeWithDomain(name string, domain Domain) (*Scheme, error) {
+	curve, group, ok := strings.Cut(name, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid scheme name %q", name)
+	}
+	return NewSchemeWithDomain(curve, group, domain)
+}
+
+// NewScheme builds a scheme for the given curve with signatures on sigGroup
+// and keys on the other source group.
+func NewScheme(curve, sigGroup string) (*Scheme, error) {
+	return NewSchemeWithDomain(curve, sigGroup, DefaultDomain())
+}
+
+// NewSchemeWithDomain is NewScheme for the given domain.
+func NewSchemeWithDomain(curve, sigGroup string, domain Domain) (*Scheme, error) {
+	var sigOnG1 bool
+	switch sigGroup {
+	case GroupG1:
+		sigOnG1 = true
+	case GroupG2:
+	default:
+		return nil, fmt.Errorf("unknown signature group %q", sigGroup)
+	}
+
+	s := &Scheme{
+		Name:    curve + "-" + sigGroup,
+		Curve:   curve,
+		SigOnG1: sigOnG1,
+		Domain:  domain,
+	}
+
+	var err error
+	switch curve {
+	case CurveBN256:
+		if !sigOnG1 {
+			return nil, fmt.Errorf("%s has no hash to G2, signatures must be on G1", curve)
+		}
+		if s.DST, err = domain.Tag("BN256G1_TAI:SHA-256_"); err != nil {
+			return nil, err
+		}
+		s.HashToCurve = HashTryAndIncrement
+		s.Pairing = bn256.NewSuite()
+		s.KeyGroup = bn256.NewSuiteG2()
+	case CurveBN254:
+		if !sigOnG1 {
+			return nil, fmt.Errorf("%s has no hash to G2, signatures must be on G1", curve)
+		}
+		if s.DST, err = domain.Tag("BN254G1_XMD:KECCAK-256_SVDW_RO_"); err != nil {
+			return nil, err
+		}
+		s.HashToCurve = HashRFC9380
+		p := bn254.NewSuite()
+		p.SetDomainG1(s.DST)
+		s.Pairing = p
+		s.KeyGroup = bn254.NewSuiteG2()
+	case CurveBLS12381:
+		suiteID := "BLS12381G1_XMD:SHA-256_SSWU_RO_"
+		if !sigOnG1 {
+			suiteID = "BLS12381G2_XMD:SHA-256_SSWU_RO_"
+		}
+		if s.DST, err = domain.Tag(suiteID); err != nil {
+			return nil, err
+		}
+		s.HashToCurve = HashRFC9380
+		p := kilic.NewBLS12381SuiteWithDST(s.DST, s.DST)
+		s.Pairing = p
+		if sigOnG1 {
+			s.KeyGroup = &keySuite{Group: p.G2(), Suite: p}
+		} else {
+			s.KeyGroup = &keySuite{Group: p.G1(), Suite: p}
+		}
+	default:
+		return nil, fmt.Errorf("unknown curve %q", curve)
+	}
+
+	if sigOnG1 {
+		s.SigGroup = s.Pairing.G1()
+		s.ThresholdScheme = tbls.NewThresholdSchemeOnG1(s.Pairing)
+		s.SigScheme = bls.NewSchemeOnG1(s.Pairing)
+	} else {
+		s.SigGroup = s.Pairing.G2()
+		s.ThresholdScheme = tbls.NewThresholdSchemeOnG2(s.Pairing)
+		s.SigScheme = bls.NewSchemeOnG2(s.Pairing)
+	}
+	s.AuthScheme = schnorr.NewScheme(s.KeyGroup)
+
+	if s.HashToCurve != HashRFC9380 {
+		s.ThresholdScheme = &domainThresholdScheme{scheme: s, inner: s.ThresholdScheme}
+		s.SigScheme = &domainScheme{scheme: s, inner: s.SigScheme}
+	}
+
+	return s, nil
+}
+
+// WithPurpose returns the scheme of the same network and curve signing for
+// purpose, s itself if it already does.
+func (s *Scheme) WithPurpose(purpose string) (*Scheme, error) {
+	if s.Domain.Purpose == purpose {
+		return s, nil
+	}
+	return ParseSchemeWithDomain(s.Name, Domain{Network: s.Domain.Network, Purpose: purpose})
+}
+
+// ErrNonCanonical is returned for a point or scalar encoded otherwise than
+// its group marshals it, e.g. with trailing bytes. Such encodings would let
+// one value travel under several byte strings.
+var ErrNonCanonical = errors.New("non-canonical encoding")
+
+// PointFromBytes unmarshals a point of the key group from its canonical
+// encoding.
+func (s *Scheme) PointFromBytes(b []byte) (kyber.Point, error) {
+	return unmarshalCanonical(s.KeyGroup.Point(), b)
+}
+
+// ScalarFromBytes unmarshals a scalar of the key group from its canonical
+// encoding.
+func (s *Scheme) ScalarFromBytes(b []byte) (kyber.Scalar, error) {
+	return unmarshalCanonical(s.KeyGroup.Scalar(), b)
+}
+
+// SignatureFromBytes unmarshals a recovered signature into a point of the
+// signature group, from its canonical encoding.
+func (s *Scheme) SignatureFromBytes(b []byte) (kyber.Point, error) {
+	return unmarshalCanonical(s.SigGroup.Point(), b)
+}
+
+// unmarshalCanonical unmarshals b into v, failing unless v marshals back to
+// b.
+func unmarshalCanonical[T kyber.Marshaling](v T, b []byte) (T, error) {
+	var zero T
+	if len(b) != v.MarshalSize() {
+		return zero, fmt.Errorf("%w: %d bytes instead of %d", ErrNonCanonical, len(b), v.MarshalSize())
+	}
+	if err := v.UnmarshalBinary(b); err != nil {
+		return zero, err
+	}
+	out, err := v.MarshalBinary()
+	if err != nil {
+		return zero, err
+	}
+	if !bytes.Equal(out, b) {
+		return zero, ErrNonCanonical
+	}
+	return v, nil
+}
+
+// keySuite turns one group of a pairing suite into a DKG suite, borrowing the
+// hash, XOF and randomness of the pairing suite.
+type keySuite struct {
+	kyber.Group
+	pairing.Suite
+}
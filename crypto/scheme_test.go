@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestSchemesThresholdSign(t *testing.T) {
+	for _, name := range []string{"bn256-g1", "bn254-g1", "bls12381-g1", "bls12381-g2"} {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseScheme(name)
+			require.NoError(t, err)
+			require.Equal(t, name, s.Name)
+
+			pri := share.NewPriPoly(s.KeyGroup, 2, nil, random.New())
+			pub := pri.Commit(s.KeyGroup.Point().Base())
+			msg := []byte("Hello World")
+
+			var partials [][]byte
+			for _, sh := range pri.Shares(3) {
+				sig, err := s.ThresholdScheme.Sign(sh, msg)
+				require.NoError(t, err)
+				require.NoError(t, s.ThresholdScheme.VerifyPartial(pub, msg, sig))
+				partials = append(partials, sig)
+			}
+
+			sig, err := s.ThresholdScheme.Recover(pub, msg, partials, 2, 3)
+			require.NoError(t, err)
+			require.NoError(t, s.SigScheme.Verify(pub.Commit(), msg, sig))
+
+			_, err = s.SignatureFromBytes(sig)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseSchemeErrors(t *testing.T) {
+	for _, name := range []string{"bn256", "bn256-g2", "bn254-g2", "bn256-g3", "secp256k1-g1"} {
+		_, err := ParseScheme(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestSchemeDomainSeparation(t *testing.T) {
+	for _, name := range []string{"bn256-g1", "bn254-g1", "bls12381-g1", "bls12381-g2"} {
+		t.Run(name, func(t *testing.T) {
+			mainnet, err := ParseScheme(name)
+			require.NoError(t, err)
+			testnet, err := ParseSchemeWithDomain(name, Domain{Network: "testnet", Purpose: PurposeVRF})
+			require.NoError(t, err)
+			require.NotEqual(t, mainnet.DST, testnet.DST)
+			round, err := mainnet.WithPurpose(PurposeRound)
+			require.NoError(t, err)
+			require.Equal(t, name, round.Name)
+			require.NotEqual(t, mainnet.DST, round.DST)
+			others := []*Scheme{testnet, round}
+
+			pri := share.NewPriPoly(mainnet.KeyGroup, 2, nil, random.New())
+			pub := pri.Commit(mainnet.KeyGroup.Point().Base())
+			msg := []byte("Hello World")
+
+			var partials [][]byte
+			for _, sh := range pri.Shares(3) {
+				sig, err := mainnet.ThresholdScheme.Sign(sh, msg)
+				require.NoError(t, err)
+				for _, other := range others {
+					require.Error(t, other.ThresholdScheme.VerifyPartial(pub, msg, sig))
+				}
+				partials = append(partials, sig)
+			}
+
+			sig, err := mainnet.ThresholdScheme.Recover(pub, msg, partials, 2, 3)
+			require.NoError(t, err)
+			require.NoError(t, mainnet.SigScheme.Verify(pub.Commit(), msg, sig))
+			for _, other := range others {
+				require.Error(t, other.SigScheme.Verify(pub.Commit(), msg, sig))
+			}
+		})
+	}
+}
+
+func TestDomainTag(t *testing.T) {
+	tag, err := DefaultDomain().Tag("BN254G1_XMD:KECCAK-256_SVDW_RO_")
+	require.NoError(t, err)
+	require.Equal(t, "RNN-V01-mainnet-VRF-BN254G1_XMD:KECCAK-256_SVDW_RO_", string(tag))
+
+	for _, d := range []Domain{{}, {Network: "main-net", Purpose: PurposeVRF}, {Network: "mainnet"}} {
+		_, err := d.Tag("suite")
+		require.Error(t, err, d)
+	}
+}
+
+func TestFromBytesCanonical(t *testing.T) {
+	for _, name := range []string{"bn256-g1", "bn254-g1", "bls12381-g1", "bls12381-g2"} {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseScheme(name)
+			require.NoError(t, err)
+
+			point, err := s.KeyGroup.Point().Pick(random.New()).MarshalBinary()
+			require.NoError(t, err)
+			_, err = s.PointFromBytes(point)
+			require.NoError(t, err)
+			_, err = s.PointFromBytes(append(point, 0))
+			require.ErrorIs(t, err, ErrNonCanonical)
+
+			scalar, err := s.KeyGroup.Scalar().Pick(random.New()).MarshalBinary()
+			require.NoError(t, err)
+			_, err = s.ScalarFromBytes(scalar)
+			require.NoError(t, err)
+			_, err = s.ScalarFromBytes(append(scalar, 0))
+			require.ErrorIs(t, err, ErrNonCanonical)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package dkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"random-network-poc/rng"
+	"random-network-poc/sim"
+	"random-network-poc/wire"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+// The benchmarks run whole committees in process, over the simulated network
+// on virtual time: the DKG costs the CPU time of every node together, with no
+// phase waited out. Compare commits with benchstat, e.g.
+//
+//	go test ./dkg -run '^$' -bench 'DKG/n=(4|16)/' -count 6 > old.txt
+
+// benchSizes are the committee sizes of the benchmarks.
+var benchSizes = []int{4, 16, 64, 128}
+
+// benchCommittees runs f for every committee size, with a majority and a
+// two-thirds threshold.
+func benchCommittees(b *testing.B, f func(b *testing.B, n, threshold int)) {
+	for _, n := range benchSizes {
+		for _, threshold := range slices.Compact([]int{n/2 + 1, 2*n/3 + 1}) {
+			b.Run(fmt.Sprintf("n=%d/t=%d", n, threshold), func(b *testing.B) {
+				f(b, n, threshold)
+			})
+		}
+	}
+}
+
+// countingBoard counts the bundles pushed through it and their size in
+// protobuf.
+type countingBoard struct {
+	pedersen_dkg.Board
+	bundles *atomic.Int64
+	bytes   *atomic.Int64
+}
+
+func (c *countingBoard) count(bundle any) {
+	data, err := EncodeBoardMessage(wire.FormatProtobuf, bundle)
+	if err == nil {
+		c.bundles.Add(1)
+		c.bytes.Add(int64(len(data)))
+	}
+}
+
+func (c *countingBoard) PushDeals(bundle *pedersen_dkg.DealBundle) {
+	c.count(bundle)
+	c.Board.PushDeals(bundle)
+}
+
+func (c *countingBoard) PushResponses(bundle *pedersen_dkg.ResponseBundle) {
+	c.count(bundle)
+	c.Board.PushResponses(bundle)
+}
+
+func (c *countingBoard) PushJustifications(bundle *pedersen_dkg.JustificationBundle) {
+	c.count(bundle)
+	c.Board.PushJustifications(bundle)
+}
+
+// BenchmarkDKG measures a DKG run of the whole committee and the bundles it
+// publishes: every bundle reaches the n-1 other nodes.
+func BenchmarkDKG(b *testing.B) {
+	benchCommittees(b, func(b *testing.B, n, threshold int) {
+		var bundles, bytes atomic.Int64
+		var messages int
+		wrap := func(board pedersen_dkg.Board) pedersen_dkg.Board {
+			return &countingBoard{Board: board, bundles: &bundles, bytes: &bytes}
+		}
+
+		qual := make([]uint32, n)
+		for i := range qual {
+			qual[i] = uint32(i)
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			c := newWrappedSimCluster(b, n, threshold, uint64(i), sim.Link{Latency: 10 * time.Millisecond}, wrap)
+			b.StartTimer()
+
+			outcomes := c.runDKG()
+
+			b.StopTimer()
+			for j, outcome := range outcomes {
+				require.Equal(b, fmt.Sprint(qual), outcome, "node %d", j)
+			}
+			messages += c.net.Stats().Sent
+			c.close(b)
+			b.StartTimer()
+		}
+		b.StopTimer()
+
+		b.ReportMetric(float64(bundles.Load())/float64(b.N), "bundles/op")
+		b.ReportMetric(float64(messages)/float64(b.N), "msgs/op")
+		b.ReportMetric(float64(bytes.Load()*int64(n-1))/float64(b.N), "wire-B/op")
+	})
+}
+
+// benchRound is a node holding share 0 of a dealt key, with the input of a
+// round and the partials of the first threshold shares over it.
+type benchRound struct {
+	node     *Node
+	input    []byte
+	partials [][]byte
+}
+
+// newBenchRound deals the key of a committee of n instead of running the
+// DKG, which the signing costs do not depend on.
+func newBenchRound(b *testing.B, n, threshold int) *benchRound {
+	node := newSimCluster(b, n, threshold, 0, sim.Link{}).nodes[0]
+
+	pri := share.NewPriPoly(node.scheme.KeyGroup, threshold, nil, random.New())
+	_, commits := pri.Commit(node.scheme.KeyGroup.Point().Base()).Info()
+	shares := pri.Shares(n)
+	node.SetResult(&pedersen_dkg.Result{
+		QUAL: node.nodes,
+		Key:  &pedersen_dkg.DistKeyShare{Commits: commits, Share: shares[0]},
+	})
+
+	r := &benchRound{node: node, input: []byte("round")}
+	for _, s := range shares[:threshold] {
+		partial, err := node.scheme.ThresholdScheme.Sign(s, r.input)
+		require.NoError(b, err)
+		r.partials = append(r.partials, partial)
+	}
+	return r
+}
+
+// BenchmarkSignVRF measures the partial signature of a validator over an
+// announced round.
+func BenchmarkSignVRF(b *testing.B) {
+	benchCommittees(b, func(b *testing.B, n, threshold int) {
+		r := newBenchRound(b, n, threshold)
+		signVRF := rng.SignVRF{RequestID: "round", Data: hex.EncodeToString(r.input)}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.node.SignVRF(signVRF); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkRecoverBLSSignature measures the recovery of a round from a
+// threshold of partials.
+func BenchmarkRecoverBLSSignature(b *testing.B) {
+	benchCommittees(b, func(b *testing.B, n, threshold int) {
+		r := newBenchRound(b, n, threshold)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			requestID := fmt.Sprintf("round-%d", i)
+			r.node.mu.Lock()
+			r.node.rounds[requestID] = &round{data: r.input, partials: r.partials, done: make(chan struct{}, 1)}
+			r.node.mu.Unlock()
+			b.StartTimer()
+
+			if _, err := r.node.RecoverBLSSignature(requestID, r.input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkVerifyBLSSignature measures the verification of a round output
+// against the group public key.
+func BenchmarkVerifyBLSSignature(b *testing.B) {
+	benchCommittees(b, func(b *testing.B, n, threshold int) {
+		r := newBenchRound(b, n, threshold)
+		r.node.mu.Lock()
+		r.node.rounds["round"] = &round{data: r.input, partials: r.partials, done: make(chan struct{}, 1)}
+		r.node.mu.Unlock()
+		sig, err := r.node.RecoverBLSSignature("round", r.input)
+		require.NoError(b, err)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := r.node.VerifyBLSSignature(r.input, sig); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package dkg
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"random-network-poc/byzantine"
+	"random-network-poc/crypto"
+	"random-network-poc/envelope"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// faultyNodes applies rules to the nodes at indices.
+func faultyNodes(indices []int, rules ...byzantine.Rule) map[int][]byzantine.Rule {
+	faulty := make(map[int][]byzantine.Rule)
+	for _, i := range indices {
+		faulty[i] = rules
+	}
+	return faulty
+}
+
+// With 5 validators and a threshold of 3, the DKG tolerates 2 faulty dealers:
+// it either evicts them or goes on as if they were honest.
+func TestByzantineDKG(t *testing.T) {
+	bad := []int{3, 4}
+
+	for _, tc := range []struct {
+		name   string
+		faulty map[int][]byzantine.Rule
+		qual   []uint32
+	}{
+		{
+			name:   "delayed deals",
+			faulty: faultyNodes(bad, byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Delay, Delay: 200 * time.Millisecond}),
+			qual:   []uint32{0, 1, 2, 3, 4},
+		},
+		{
+			name:   "duplicated deals",
+			faulty: faultyNodes(bad, byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Duplicate}),
+			qual:   []uint32{0, 1, 2, 3, 4},
+		},
+		{
+			// both versions of its complaints evict the share holder, not
+			// the dealer
+			name: "equivocated responses",
+			faulty: map[int][]byzantine.Rule{
+				3: {{Kind: byzantine.Deal, Fault: byzantine.Corrupt}},
+				4: {{Kind: byzantine.Response, Fault: byzantine.Equivocate}},
+			},
+			qual: []uint32{0, 1, 2, 4},
+		},
+		{
+			name:   "dropped deals",
+			faulty: faultyNodes(bad, byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Drop}),
+			qual:   []uint32{0, 1, 2},
+		},
+		{
+			name: "corrupted deals and justifications",
+			faulty: faultyNodes(bad,
+				byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Corrupt},
+				byzantine.Rule{Kind: byzantine.Justification, Fault: byzantine.Corrupt},
+			),
+			qual: []uint32{0, 1, 2},
+		},
+		{
+			name:   "equivocated deals",
+			faulty: faultyNodes(bad, byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Equivocate}),
+			qual:   []uint32{0, 1, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := newFaultyCluster(t, ctx, 5, 3, tc.faulty)
+			defer c.close(t)
+
+			public := c.runFaultyDKG(t, tc.qual)
+
+			// a round of an honest node still reaches the threshold
+			input := []byte("round")
+			out, err := c.nodes[0].Generate(ctx, "byzantine", input)
+			require.NoError(t, err)
+			sig, err := hex.DecodeString(out.Signature)
+			require.NoError(t, err)
+			require.NoError(t, c.scheme.SigScheme.Verify(public, input, sig))
+		})
+	}
+}
+
+func TestByzantineDKGTooManyFaults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newFaultyCluster(t, ctx, 5, 3, faultyNodes([]int{2, 3, 4}, byzantine.Rule{Kind: byzantine.Deal, Fault: byzantine.Drop}))
+	defer c.close(t)
+
+	for _, node := range c.nodes {
+		node.StartDKG()
+	}
+
+	// the honest nodes are left with fewer deals than the threshold
+	for i, node := range c.nodes[:2] {
+		_, err := node.WaitDKG()
+		require.ErrorContains(t, err, "only 2/3 valid deals", "node %d", i)
+		require.Equal(t, DKGFailed, node.Status().DKG.State, "node %d", i)
+	}
+}
+
+// A member cannot pass the bundle of another one off as its own: the board
+// drops bundles whose dealer or share holder is not the sender.
+func TestBoardDropsRelayedBundles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(2)
+	require.NoError(t, err)
+	defer mn.Close()
+
+	scheme := crypto.DefaultScheme()
+	tns := GenerateTestNodes(scheme.KeyGroup, 2)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var boards []*BoardP2P
+	for i, h := range mn.Hosts() {
+		ps, err := pubsub.NewGossipSub(ctx, h)
+		require.NoError(t, err)
+		codec := envelope.New(&envelope.Config{Scheme: scheme, Index: uint32(i), Longterm: tns[i].Private, Nodes: NodesFromTest(tns)})
+		board, err := NewBoardP2P(ctx, ps, h.ID(), scheme, nil, codec, logger, nil)
+		require.NoError(t, err)
+		defer board.Close()
+		boards = append(boards, board)
+	}
+	require.NoError(t, mn.ConnectAllButSelf())
+	require.Eventually(t, func() bool {
+		return len(boards[0].topic.ListPeers()) == 1 && len(boards[1].topic.ListPeers()) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	// lets gossipsub build its mesh before the first publish
+	time.Sleep(time.Second)
+
+	// the deal and the response of member 1, relayed by member 0
+	deal, resp, _ := testBundles(scheme, 2)
+	resp.ShareIndex = 1
+	boards[0].PushDeals(deal)
+	boards[0].PushResponses(resp)
+
+	own := *deal
+	own.DealerIndex = 0
+	boards[0].PushDeals(&own)
+
+	select {
+	case got := <-boards[1].IncomingDeal():
+		require.Equal(t, uint32(0), got.DealerIndex)
+	case <-time.After(10 * time.Second):
+		t.Fatal("deal of member 0 not received")
+	}
+	require.Empty(t, boards[1].IncomingResponse())
+}
+
+// runFaultyDKG runs the DKG on every node and returns the group public key,
+// failing unless the nodes in qual agree on it and the others are evicted.
+func (c *cluster) runFaultyDKG(t *testing.T, qual []uint32) kyber.Point {
+	for _, node := range c.nodes {
+		node.StartDKG()
+	}
+
+	var public kyber.Point
+	for i, node := range c.nodes {
+		result, err := node.WaitDKG()
+		if !slices.Contains(qual, uint32(i)) {
+			require.ErrorIs(t, err, pedersen_dkg.ErrEvicted, "node %d", i)
+			continue
+		}
+		require.NoError(t, err, "node %d", i)
+
+		var indices []uint32
+		for _, n := range result.QUAL {
+			indices = append(indices, n.Index)
+		}
+		require.ElementsMatch(t, qual, indices, "node %d", i)
+
+		if public == nil {
+			public = result.Key.Public()
+		}
+		require.True(t, public.Equal(result.Key.Public()), "node %d", i)
+	}
+
+	return public
+}
+
+// Partials of the RNG are verified by the aggregator of the round: a round
+// tolerates n - t faulty signers, and fails with a clear error beyond.
+func TestByzantineRNG(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the faulty nodes behave until the DKG is over
+	c := newFaultyCluster(t, ctx, 5, 3, faultyNodes([]int{2, 3, 4}))
+	defer c.close(t)
+	public := c.runDKG(t)
+
+	// the other faulty nodes behave again
+	setRules := func(indices []int, rules ...byzantine.Rule) {
+		for i, s := range c.faultySigners {
+			if slices.Contains(indices, i) {
+				require.NoError(t, s.SetRules(rules...))
+			} else {
+				require.NoError(t, s.SetRules())
+			}
+		}
+	}
+
+	round := 0
+	generate := func(timeout time.Duration) (*Output, error) {
+		round++
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		// every round has its own client, to stay under the client limits
+		return c.nodes[0].Generate(ctx, fmt.Sprintf("client-%d", round), []byte(fmt.Sprintf("round-%d", round)))
+	}
+
+	for _, fault := range []byzantine.Fault{byzantine.Drop, byzantine.Delay, byzantine.Corrupt, byzantine.Equivocate} {
+		setRules([]int{3, 4}, byzantine.Rule{Kind: byzantine.Partial, Fault: fault, Delay: 200 * time.Millisecond})
+
+		out, err := generate(10 * time.Second)
+		require.NoError(t, err, "%s", fault)
+		sig, err := hex.DecodeString(out.Signature)
+		require.NoError(t, err)
+		require.NoError(t, c.scheme.SigScheme.Verify(public, []byte(fmt.Sprintf("round-%d", round)), sig), "%s", fault)
+	}
+
+	// invalid partials count against the round, which fails at once
+	for _, fault := range []byzantine.Fault{byzantine.Corrupt, byzantine.Equivocate} {
+		setRules([]int{2, 3, 4}, byzantine.Rule{Kind: byzantine.Partial, Fault: fault})
+
+		_, err := generate(10 * time.Second)
+		require.ErrorIs(t, err, ErrRequestRejected, "%s", fault)
+		require.ErrorContains(t, err, "invalid partial signature", "%s", fault)
+	}
+
+	// missing partials leave the round waiting for its deadline
+	setRules([]int{2, 3, 4}, byzantine.Rule{Kind: byzantine.Partial, Fault: byzantine.Drop})
+	_, err := generate(2 * time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "has 2 of 3 partials")
+
+	for i, node := range c.nodes {
+		require.Zero(t, node.Status().PendingRequests, "node %d", i)
+	}
+}
@@ -6,79 +6,359 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/envelope"
+	"random-network-poc/metrics"
 	"random-network-poc/rng"
+	"random-network-poc/signer"
+	"random-network-poc/timelock"
+	"random-network-poc/wire"
+	"runtime"
+	"slices"
 	"sync"
 	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
-	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/host"
 	"go.dedis.ch/kyber/v4"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share"
 	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
-	"go.dedis.ch/kyber/v4/sign/bls"
-	"go.dedis.ch/kyber/v4/sign/schnorr"
-	"go.dedis.ch/kyber/v4/sign/tbls"
 )
 
+// Config holds the static parameters of a validator.
+type Config struct {
+	Index    uint32
+	Longterm []byte
+	Nonce    []byte
+	// LongtermKey replaces Longterm, e.g. with a key loaded from a
+	// keystore.
+	LongtermKey kyber.Scalar
+
+	// Scheme defaults to crypto.DefaultScheme. It signs the inputs of
+	// clients, and the inputs of beacon rounds for crypto.PurposeRound on the
+	// same network, see timelock.RoundMessage.
+	Scheme *crypto.Scheme
+	// Nodes is the committee, defaults to the built-in BN256 committee.
+	Nodes []pedersen_dkg.Node
+	// Threshold defaults to the package Threshold.
+	Threshold int
+	// RefreshInterval enables proactive share refresh, see StartRefresh.
+	RefreshInterval time.Duration
+	// Wire picks the format of RNG messages, nil publishes JSON.
+	Wire *wire.Negotiator
+	// Envelope seals RNG messages, defaults to a codec signing with
+	// Longterm for the committee in Nodes.
+	Envelope *envelope.Codec
+	// PeerLimits bounds the rounds each committee member can announce,
+	// defaults to rng.DefaultPeerLimits.
+	PeerLimits *rng.Limits
+	// ClientLimits bounds the requests of each client of RequestRandomness,
+	// defaults to rng.DefaultClientLimits.
+	ClientLimits *rng.Limits
+	// Logger defaults to slog.Default. The node adds its index, and the DKG
+	// and refresh rounds their session ID.
+	Logger *slog.Logger
+	// Metrics records DKG runs and RNG rounds, nil records nothing.
+	Metrics *metrics.Metrics
+	// SaveResult persists the share after the DKG, every refresh and
+	// Node.SaveResult, nil keeps it in memory only. heldBySigner is set with ForgetShare: the
+	// result then holds no share, see SetHeldResult.
+	SaveResult func(result *pedersen_dkg.Result, heldBySigner bool) error
+	// Signer signs partial signatures with the share, and DKG bundles and
+	// envelopes with the longterm key. It defaults to signing in process.
+	// Only the share can be kept out of the node: the DKG and refresh
+	// protocols still decrypt deals with the longterm key, which the node
+	// needs either way.
+	Signer signer.Signer
+	// ForgetShare zeroes the private share once handed to Signer, which then
+	// holds the only copy, e.g. in another process, such as a
+	// signer.Stored. SaveResult gets the result without it, and the node can
+	// neither refresh nor hand over its share: RefreshInterval must be zero.
+	ForgetShare bool
+	// Clock times the DKG and refresh phases and the RNG rounds, defaults to
+	// the wall clock.
+	Clock clock.Clock
+	// RoundTimeout fails the rounds of this node still short of the
+	// threshold after it, with ErrRoundExpired. Zero leaves rounds pending
+	// until their caller gives up.
+	RoundTimeout time.Duration
+	// Transport carries the RNG rounds, defaults to an rng.Protocol over the
+	// pubsub and host given to NewNode, which may then be nil.
+	Transport func(ctx context.Context, handleSignVRF rng.HandleSignVRF, handleSignature rng.HandleSignature) (rng.Transport, error)
+	// Group names the committee when a host takes part in several, see
+	// rng.GroupTopic. Empty for the default group.
+	Group string
+	// Epoch is the committee epoch of the node, see package epoch, reported
+	// by Status and in outputs.
+	Epoch uint64
+	// Schedule times the beacon rounds, see GenerateRound: the node signs no
+	// round before it is due. Nil refuses to sign any round.
+	Schedule *timelock.Schedule
+}
+
 var (
-	Suite        = bn256.NewSuiteG2()
-	SigSuite     = bn256.NewSuiteG1()
-	ThresholdBLS = tbls.NewThresholdSchemeOnG1(SigSuite)
+	// ErrRequestRejected is returned when too many signers refused a
+	// request, or sent invalid partials for it, for it to reach the
+	// threshold.
+	ErrRequestRejected = errors.New("request rejected")
+	// ErrNodeClosed is returned once the node is closed, including for the
+	// rounds pending at that time.
+	ErrNodeClosed = errors.New("node closed")
+	// ErrRequestStarted is returned for a request already pending.
+	ErrRequestStarted = errors.New("request already started")
+	// ErrRoundExpired is returned for a round short of the threshold after
+	// Config.RoundTimeout.
+	ErrRoundExpired = errors.New("round expired")
+	// ErrNotQualified is returned for signing on a node left out of the QUAL
+	// of its share, and for partials of such nodes.
+	ErrNotQualified = errors.New("not qualified")
+	// ErrShareForgotten is returned for refreshing or handing over the share
+	// of a node which left it to its signer only, see Config.ForgetShare.
+	ErrShareForgotten = errors.New("share held by the signer only")
+	// ErrNoSchedule is returned for signing a beacon round without a
+	// Config.Schedule.
+	ErrNoSchedule = errors.New("no round schedule")
+	// ErrRoundInput is returned for the input of a beacon round passed to
+	// Generate, which runs client inputs only.
+	ErrRoundInput = errors.New("input of a beacon round")
 )
 
+// phaseDuration is the length of each phase of the DKG and refresh protocols.
+const phaseDuration = 1 * time.Second
+
 type Node struct {
-	index      uint32
-	privateKey kyber.Scalar
-	publicKey  kyber.Point
-	phaser     *pedersen_dkg.TimePhaser
-	Protocol   *pedersen_dkg.Protocol
-	rnd        *rng.Protocol
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wg tracks the goroutines of the node, waited for by Close.
+	wg sync.WaitGroup
+
+	index  uint32
+	group  string
+	scheme *crypto.Scheme
+	// roundScheme signs the inputs of beacon rounds
+	roundScheme *crypto.Scheme
+	nodes       []pedersen_dkg.Node
+	threshold   int
+	privateKey  kyber.Scalar
+	publicKey   kyber.Point
+	signer      signer.Signer
+	nonce       []byte
+	clock       clock.Clock
+	phaser      *phaser
+	dkgStart    time.Time
+	Protocol    *pedersen_dkg.Protocol
+	rnd         rng.Transport
+	ps          *pubsub.PubSub
+
+	// state of the initial DKG, under mu
+	dkgState DKGState
+	dkgPhase string
+	dkgErr   error
+	// dkgDone is closed once the initial DKG ended.
+	dkgDone chan struct{}
 
 	board pedersen_dkg.Board
+	log   *slog.Logger
 
+	// Result is swapped under mu when the share is refreshed.
 	Result *pedersen_dkg.Result
+	// qual holds the sorted share indices of the QUAL of Result, under mu.
+	// evicted is set once the DKG or a refresh evicted this node, whose
+	// share is then unusable.
+	qual    []uint32
+	evicted bool
+
+	refreshInterval time.Duration
+	refreshMu       sync.Mutex
+	// pending is the share of a completed refresh waiting for SwitchShare,
+	// and refresh the state of the last refresh, both under mu.
+	pending *pedersen_dkg.Result
+	refresh *RefreshStatus
+
+	mu        *sync.Mutex
+	rounds    map[string]*round
+	lastRound *RoundStatus
+	closed    bool
 
-	mu          *sync.Mutex
-	requests    map[string][][]byte
-	requestWait map[string]chan struct{}
+	clients      *rng.Limiter[string]
+	roundTimeout time.Duration
+	metrics      *metrics.Metrics
+	saveResult   func(*pedersen_dkg.Result, bool) error
+	forgetShare  bool
+	epoch        uint64
+	schedule     *timelock.Schedule
 }
 
-func NewNode(index uint32, privKey []byte, nonce []byte, board pedersen_dkg.Board, pub *pubsub.PubSub, peerId peer.ID) (*Node, error) {
-	privateKey := Suite.Scalar().SetBytes(privKey)
-	publicKey := Suite.Point().Mul(privateKey, nil)
+// round is an RNG round started by this node.
+type round struct {
+	data       []byte
+	start      time.Time
+	partials   [][]byte
+	rejections []string
+	// expired is set once the round outlived the RoundTimeout of the node,
+	// whose timer is deadline.
+	expired  bool
+	deadline clock.Timer
+	// done is signalled once partials reach the threshold, rejections make
+	// it unreachable or the round expired.
+	done chan struct{}
+}
 
-	conf := pedersen_dkg.Config{
-		Suite:     Suite,
-		NewNodes:  Nodes,
-		Threshold: Threshold,
-		Longterm:  privateKey,
-		Nonce:     nonce,
-		Auth:      schnorr.NewScheme(Suite),
+// wake signals done, unless it is already.
+func (r *round) wake() {
+	select {
+	case r.done <- struct{}{}:
+	default:
 	}
+}
 
-	phaser := pedersen_dkg.NewTimePhaser(1 * time.Second)
+// NewNode creates a node running the DKG over board and RNG rounds over pub
+// and h. The node stops once ctx is done or Close is called.
+func NewNode(ctx context.Context, c *Config, board pedersen_dkg.Board, pub *pubsub.PubSub, h host.Host) (*Node, error) {
+	if c.ForgetShare && c.RefreshInterval != 0 {
+		return nil, fmt.Errorf("%w: cannot refresh", ErrShareForgotten)
+	}
 
-	protocol, err := pedersen_dkg.NewProtocol(&conf, board, phaser, false)
+	scheme := c.Scheme
+	if scheme == nil {
+		scheme = crypto.DefaultScheme()
+	}
+	roundScheme, err := scheme.WithPurpose(crypto.PurposeRound)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create dkg protocol: %w", err)
+		return nil, err
+	}
+
+	nodes := c.Nodes
+	if nodes == nil {
+		nodes = Nodes
+	}
+
+	threshold := c.Threshold
+	if threshold == 0 {
+		threshold = Threshold
 	}
 
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("node", c.Index)
+	if c.Group != "" {
+		logger = logger.With("group", c.Group)
+	}
+
+	clk := c.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+
+	privateKey := c.LongtermKey
+	if privateKey == nil {
+		privateKey = scheme.KeyGroup.Scalar().SetBytes(c.Longterm)
+	}
+	publicKey := scheme.KeyGroup.Point().Mul(privateKey, nil)
+
+	sgn := c.Signer
+	if sgn == nil {
+		sgn = signer.NewLocal(scheme, privateKey)
+	}
+
+	conf := pedersen_dkg.Config{
+		Suite:     scheme.KeyGroup,
+		NewNodes:  nodes,
+		Threshold: threshold,
+		Longterm:  privateKey,
+		Nonce:     c.Nonce,
+		Auth:      signer.AuthScheme(sgn, scheme.AuthScheme),
+		Log:       NewLogger(logger.With("session", hex.EncodeToString(c.Nonce))),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
 	n := &Node{
-		index:       index,
+		ctx:         ctx,
+		cancel:      cancel,
+		index:       c.Index,
+		group:       c.Group,
+		scheme:      scheme,
+		roundScheme: roundScheme,
+		nodes:       nodes,
+		threshold:   threshold,
 		privateKey:  privateKey,
 		publicKey:   publicKey,
-		phaser:      phaser,
+		signer:      sgn,
+		nonce:       c.Nonce,
+		clock:       clk,
 		board:       board,
-		Protocol:    protocol,
+		log:         logger,
+		ps:          pub,
 		mu:          &sync.Mutex{},
-		requests:    make(map[string][][]byte),
-		requestWait: make(map[string]chan struct{}),
+		rounds:      make(map[string]*round),
+		metrics:     c.Metrics,
+		saveResult:  c.SaveResult,
+		forgetShare: c.ForgetShare,
+		epoch:       c.Epoch,
+		schedule:    c.Schedule,
+		dkgState:    DKGNotStarted,
+		dkgDone:     make(chan struct{}),
+
+		refreshInterval: c.RefreshInterval,
+		roundTimeout:    c.RoundTimeout,
+	}
+
+	n.phaser = n.newPhaser(metrics.KindDKG, n.dkgDone)
+
+	protocol, err := pedersen_dkg.NewProtocol(&conf, board, n.phaser, false)
+	if err != nil {
+		n.phaser.release()
+		cancel()
+		return nil, fmt.Errorf("failed to create dkg protocol: %w", err)
+	}
+	n.Protocol = protocol
+
+	n.wg.Add(1)
+	go n.waitDKG()
+
+	codec := c.Envelope
+	if codec == nil {
+		codec = envelope.New(&envelope.Config{
+			Scheme:   scheme,
+			Index:    c.Index,
+			Longterm: privateKey,
+			Nodes:    nodes,
+			Signer:   sgn,
+			Clock:    clk,
+		})
+	}
+
+	clientLimits := c.ClientLimits
+	if clientLimits == nil {
+		clientLimits = &rng.DefaultClientLimits
+	}
+	n.clients = rng.NewLimiter[string](*clientLimits)
+	n.clients.SetClock(clk)
+
+	transport := c.Transport
+	if transport == nil {
+		transport = func(ctx context.Context, handleSignVRF rng.HandleSignVRF, handleSignature rng.HandleSignature) (rng.Transport, error) {
+			return rng.NewProtocol(ctx, pub, h, &rng.Config{
+				Envelope: codec,
+				Wire:     c.Wire,
+				Limits:   c.PeerLimits,
+				Logger:   logger,
+				Metrics:  c.Metrics,
+				Clock:    clk,
+				Group:    c.Group,
+			}, handleSignVRF, handleSignature)
+		}
 	}
 
-	rnd, err := rng.NewProtocol(context.Background(), pub, peerId, n.SignVRF, n.HandleSignature)
+	rnd, err := transport(ctx, n.SignVRF, n.HandleSignature)
 	if err != nil {
+		n.Close()
 		return nil, fmt.Errorf("failed to create rng protocol: %w", err)
 	}
 
@@ -88,20 +368,293 @@ func NewNode(index uint32, privKey []byte, nonce []byte, board pedersen_dkg.Boar
 }
 
 func (n *Node) StartDKG() {
-	go n.phaser.Start()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed || n.dkgState != DKGNotStarted {
+		return
+	}
+	n.dkgStart = n.clock.Now()
+	n.dkgState = DKGRunning
+
+	n.phaser.Start()
 }
 
-func (n *Node) SignVRF(vrf rng.SignVRF) (rng.Signature, error) {
+// WaitDKG waits for the DKG started by StartDKG and returns its result.
+func (n *Node) WaitDKG() (*pedersen_dkg.Result, error) {
+	select {
+	case <-n.dkgDone:
+	case <-n.ctx.Done():
+		return nil, ErrNodeClosed
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.dkgErr != nil {
+		return nil, n.dkgErr
+	}
 	if n.Result == nil {
-		return rng.Signature{}, errors.New("DKG not completed")
+		// closed before the DKG ended
+		return nil, ErrNodeClosed
+	}
+	return n.Result, nil
+}
+
+// waitDKG installs the result of the initial DKG. The DKG protocol only
+// returns once its phaser reached the last phase, which Close hastens.
+func (n *Node) waitDKG() {
+	defer n.wg.Done()
+	defer close(n.dkgDone)
+
+	result := <-n.Protocol.WaitEnd()
+	if n.ctx.Err() != nil {
+		return
+	}
+
+	n.metrics.DKGRun(metrics.KindDKG, result.Error, n.clock.Now().Sub(n.dkgStart))
+	if result.Error != nil {
+		n.mu.Lock()
+		n.dkgState = DKGFailed
+		n.dkgErr = result.Error
+		n.evicted = errors.Is(result.Error, pedersen_dkg.ErrEvicted)
+		n.mu.Unlock()
+		return
+	}
+
+	n.SetResult(result.Result)
+	n.save(result.Result)
+}
+
+// save persists a new share, see Config.SaveResult.
+func (n *Node) save(result *pedersen_dkg.Result) {
+	if n.saveResult == nil {
+		return
+	}
+	if err := n.saveResult(result, n.forgetShare); err != nil {
+		n.log.Error("Failed to save share", "err", err)
+	}
+}
+
+// Close stops the node. Pending rounds are woken up and fail with
+// ErrNodeClosed, a running DKG or refresh is cut short, and the RNG protocol
+// leaves its topics. The board is closed by its owner.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	for _, r := range n.rounds {
+		r.wake()
+	}
+	n.mu.Unlock()
+
+	// the phasers move the DKG and refresh protocols straight to their end
+	n.cancel()
+	n.wg.Wait()
+
+	if n.rnd == nil {
+		return nil
+	}
+	return n.rnd.Close()
+}
+
+// newPhaser returns a phaser moving to the next phase every phaseDuration of
+// the node clock and recording the duration of each phase. Phases of the
+// initial DKG are also reported by Status. done is closed once the protocol
+// driven by the phaser ended.
+func (n *Node) newPhaser(kind string, done <-chan struct{}) *phaser {
+	p := newBoardPhaser(n.ctx, n.clock, n.board, kind, n.metrics, done)
+	if kind == metrics.KindDKG {
+		p.begin = func(phase pedersen_dkg.Phase) {
+			n.mu.Lock()
+			n.dkgPhase = phase.String()
+			n.mu.Unlock()
+		}
+	}
+	return p
+}
+
+// newBoardPhaser returns a phaser driving a protocol over board, stopped once
+// ctx is done.
+func newBoardPhaser(ctx context.Context, clk clock.Clock, board pedersen_dkg.Board, kind string, m *metrics.Metrics, done <-chan struct{}) *phaser {
+	p := &phaser{
+		ctx:   ctx,
+		clock: clk,
+		board: board,
+		out:   make(chan pedersen_dkg.Phase),
+		done:  done,
+		begin: func(pedersen_dkg.Phase) {},
+		end: func(phase pedersen_dkg.Phase, d time.Duration) {
+			m.DKGPhase(kind, phase.String(), d)
+		},
+	}
+	_, p.lockstep = clk.(*clock.Virtual)
+	p.unhook = context.AfterFunc(ctx, p.stop)
+	return p
+}
+
+// phases are the timed phases of the DKG, refresh and handover protocols.
+var phases = []pedersen_dkg.Phase{pedersen_dkg.DealPhase, pedersen_dkg.ResponsePhase, pedersen_dkg.JustifPhase}
+
+// phaser works like pedersen_dkg.TimePhaser but runs on a clock.Clock and can
+// be stopped: once ctx is done it moves the protocol straight to FinishPhase,
+// so the protocol returns without publishing the bundles of the remaining
+// phases.
+//
+// On the wall clock phases follow each other every phaseDuration, as with
+// TimePhaser. On a clock.Virtual the phaser runs in lockstep with the
+// protocol: a phase is only handed over once the protocol took the bundles
+// already on the board, and only counts as sent once the protocol handled it,
+// so the phases see the same bundles on every run.
+type phaser struct {
+	ctx   context.Context
+	clock clock.Clock
+	board pedersen_dkg.Board
+	out   chan pedersen_dkg.Phase
+	done  <-chan struct{}
+	// begin is called as a phase starts and end once it is over.
+	begin func(pedersen_dkg.Phase)
+	end   func(pedersen_dkg.Phase, time.Duration)
+	// unhook cancels the call of stop once ctx is done.
+	unhook func() bool
+	// lockstep is set on a clock.Virtual, see settle.
+	lockstep bool
+
+	// mu is held while handing a phase over.
+	mu       sync.Mutex
+	next     int
+	started  time.Time
+	timer    clock.Timer
+	finished bool
+}
+
+// Start sends the first phase and schedules the next ones. It does not block.
+func (p *phaser) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timer = p.clock.AfterFunc(0, p.step)
+}
+
+func (p *phaser) NextPhase() chan pedersen_dkg.Phase {
+	return p.out
+}
+
+// step ends the current phase and sends the next one.
+func (p *phaser) step() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// once ctx is done stop finishes the protocol
+	if p.finished || p.ctx.Err() != nil {
+		return
+	}
+
+	if p.next > 0 {
+		p.end(phases[p.next-1], p.clock.Now().Sub(p.started))
+	}
+	if p.next == len(phases) {
+		p.finished = true
+		if p.settle() {
+			p.send(pedersen_dkg.FinishPhase)
+		}
+		return
+	}
+
+	phase := phases[p.next]
+	p.next++
+	p.begin(phase)
+	if !p.hand(phase) {
+		p.finished = true
+		return
+	}
+	p.started = p.clock.Now()
+	p.timer = p.clock.AfterFunc(phaseDuration, p.step)
+}
+
+// stop moves the protocol to FinishPhase.
+func (p *phaser) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.finish()
+}
+
+// release stops watching ctx once the protocol ended.
+func (p *phaser) release() {
+	p.unhook()
+}
+
+func (p *phaser) finish() {
+	p.finished = true
+	p.send(pedersen_dkg.FinishPhase)
+}
+
+// hand sends phase, in lockstep once the protocol took the bundles on the
+// board and until it handled it. It fails once the protocol ended.
+func (p *phaser) hand(phase pedersen_dkg.Phase) bool {
+	if !p.lockstep {
+		return p.send(phase)
+	}
+	// the protocol ignores InitPhase, and only takes it once done with
+	// phase, including pushing its bundles to the board
+	return p.settle() && p.send(phase) && p.send(pedersen_dkg.InitPhase)
+}
+
+// settle waits, in lockstep, for the protocol to take the bundles on the
+// board: it picks at random among its ready channels, and the bundles
+// delivered before a phase have to count for it. Virtual time stands still
+// meanwhile, so this only yields to the protocol goroutine. It fails once the
+// protocol ended.
+func (p *phaser) settle() bool {
+	if !p.lockstep {
+		return true
+	}
+	for len(p.board.IncomingDeal())+len(p.board.IncomingResponse())+len(p.board.IncomingJustification()) > 0 {
+		select {
+		case <-p.done:
+			return false
+		default:
+		}
+		runtime.Gosched()
+	}
+	return true
+}
+
+// send hands a phase to the protocol, reporting false once it ended.
+func (p *phaser) send(phase pedersen_dkg.Phase) bool {
+	select {
+	case p.out <- phase:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func (n *Node) SignVRF(vrf rng.SignVRF) (rng.Signature, error) {
+	if err := n.canSign(); err != nil {
+		return rng.Signature{}, err
 	}
 
 	data, err := hex.DecodeString(vrf.Data)
 	if err != nil {
 		return rng.Signature{}, fmt.Errorf("failed to decode data: %w", err)
 	}
+	// the aggregator learns at once that the round cannot be signed yet
+	if err := n.checkRound(data); err != nil {
+		return rng.Signature{RequestID: vrf.RequestID, Rejected: err.Error()}, nil
+	}
 
-	sig, err := ThresholdBLS.Sign(n.Result.Key.PriShare(), data)
+	sig, err := n.signer.SignPartial(n.schemeOf(data).Domain.Purpose, data)
 	if err != nil {
 		return rng.Signature{}, fmt.Errorf("failed to sign data: %w", err)
 	}
@@ -113,91 +666,541 @@ func (n *Node) SignVRF(vrf rng.SignVRF) (rng.Signature, error) {
 }
 
 func (n *Node) HandleSignature(signature rng.Signature) error {
-	if n.Result == nil {
+	if signature.Rejected != "" {
+		return n.handleRejection(signature)
+	}
+
+	key := n.key()
+	if key == nil {
 		return errors.New("DKG not completed")
 	}
 
+	reqID := signature.RequestID
+
+	n.mu.Lock()
+	// partials broadcast for rounds of other nodes, or of finished rounds
+	r, ok := n.rounds[reqID]
+	qualified := slices.Contains(n.qual, signature.SenderIndex)
+	n.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown request %s", reqID)
+	}
+	// a share outside QUAL is not part of the group key, whatever it signs
+	if !qualified {
+		n.metrics.PartialRejected("not_qualified")
+		return fmt.Errorf("%w: partial of node %d", ErrNotQualified, signature.SenderIndex)
+	}
+
+	// verified on receipt, so only valid partials count towards the
+	// threshold. The RNG protocol delivers one message per signer and
+	// round, so a signer sending an invalid partial is out of the round.
+	sig, err := n.verifyPartial(key, r, signature)
+	if err != nil {
+		// fails only for a round finished meanwhile
+		_ = n.reject(reqID, err.Error())
+		return err
+	}
+	n.metrics.PartialVerified()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	r.partials = append(r.partials, sig)
+
+	if len(r.partials) == n.threshold {
+		r.wake()
+	}
+
+	return nil
+}
+
+// verifyPartial returns the partial signature of the round r carried by
+// signature, checking that it is valid and signed with the share of its
+// authenticated sender.
+func (n *Node) verifyPartial(key *pedersen_dkg.DistKeyShare, r *round, signature rng.Signature) ([]byte, error) {
 	sig, err := hex.DecodeString(signature.Signature)
 	if err != nil {
-		return fmt.Errorf("failed to decode signature: %w", err)
+		n.metrics.PartialRejected("malformed")
+		return nil, fmt.Errorf("failed to decode signature of node %d: %w", signature.SenderIndex, err)
 	}
 
-	reqID := signature.RequestID
+	// a partial carries the share index, which must be the one of the
+	// authenticated sender
+	scheme := n.schemeOf(r.data)
+	index, err := scheme.ThresholdScheme.IndexOf(sig)
+	if err != nil {
+		n.metrics.PartialRejected("malformed")
+		return nil, fmt.Errorf("failed to read partial signature index of node %d: %w", signature.SenderIndex, err)
+	}
+	if uint32(index) != signature.SenderIndex {
+		n.metrics.PartialRejected("wrong_index")
+		return nil, fmt.Errorf("partial signature of share %d sent by node %d", index, signature.SenderIndex)
+	}
 
+	poly := share.NewPubPoly(n.scheme.KeyGroup, n.scheme.KeyGroup.Point().Base(), key.Commits)
+	if err := scheme.ThresholdScheme.VerifyPartial(poly, r.data, sig); err != nil {
+		n.metrics.PartialRejected("invalid")
+		return nil, fmt.Errorf("invalid partial signature of share %d: %w", index, err)
+	}
+
+	return sig, nil
+}
+
+// handleRejection records the refusal of a signer. Signers outside QUAL are
+// not counted on, so their refusals are ignored.
+func (n *Node) handleRejection(signature rng.Signature) error {
+	n.mu.Lock()
+	qualified := slices.Contains(n.qual, signature.SenderIndex)
+	n.mu.Unlock()
+	if !qualified {
+		return fmt.Errorf("%w: rejection of node %d", ErrNotQualified, signature.SenderIndex)
+	}
+
+	if err := n.reject(signature.RequestID, signature.Rejected); err != nil {
+		return err
+	}
+	n.metrics.PartialRejected("refused")
+	return nil
+}
+
+// reject records that a signer will not contribute to a round, for reason.
+// Once the remaining signers cannot reach the threshold the round is woken up
+// and fails to recover.
+func (n *Node) reject(reqID, reason string) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	n.requests[reqID] = append(n.requests[reqID], sig)
+	r, ok := n.rounds[reqID]
+	if !ok {
+		return fmt.Errorf("unknown request %s", reqID)
+	}
 
-	if len(n.requests[reqID]) >= Threshold {
-		n.requestWait[reqID] <- struct{}{}
+	r.rejections = append(r.rejections, reason)
+
+	if len(r.rejections) == len(n.qual)-n.threshold+1 {
+		r.wake()
 	}
 
 	return nil
 }
 
+// expire fails the round r of reqID if it is still short of the threshold.
+func (n *Node) expire(reqID string, r *round) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.rounds[reqID] != r || len(r.partials) >= n.threshold {
+		return
+	}
+	r.expired = true
+	r.wake()
+}
+
+// WaitRNGRound returns a channel signalled once the request has enough
+// partials to recover, too many rejections to ever do so, or expired.
 func (n *Node) WaitRNGRound(requestID string) <-chan struct{} {
-	return n.requestWait[requestID]
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	r, ok := n.rounds[requestID]
+	if !ok {
+		return nil
+	}
+	return r.done
 }
 
-func (n *Node) StartRandomNumberGeneration(requestID string, data []byte) error {
-	if err := n.rnd.Start(requestID, data); err != nil {
-		return fmt.Errorf("failed to start rng protocol: %w", err)
+// RequestRandomness starts a round on behalf of a client, within the
+// ClientLimits of that client.
+func (n *Node) RequestRandomness(client, requestID string, data []byte) error {
+	if err := n.clients.Allow(client); err != nil {
+		return fmt.Errorf("client %s: %w", client, err)
+	}
+	return n.StartRandomNumberGeneration(requestID, data)
+}
+
+// Output is a recovered round as returned to clients.
+type Output struct {
+	RequestID string `json:"request_id"`
+	// Input is the hex signed input.
+	Input string `json:"input"`
+	// Signature is the hex threshold signature of the input.
+	Signature string `json:"signature"`
+	// Randomness is the decimal hash of the signature.
+	Randomness string    `json:"randomness"`
+	Proof      *EVMProof `json:"proof,omitempty"`
+	// Epoch is the committee epoch of the round, see Config.Epoch. The
+	// first epoch is 0, as rounds run without one.
+	Epoch uint64 `json:"epoch,omitempty"`
+}
+
+// Generate runs a round over input on behalf of client, see
+// RequestRandomness, and returns its verified output. The round is dropped if
+// ctx is done first. Beacon rounds run through GenerateRound only.
+func (n *Node) Generate(ctx context.Context, client string, input []byte) (*Output, error) {
+	if _, ok := timelock.ParseRound(input); ok {
+		return nil, ErrRoundInput
+	}
+	return n.generate(ctx, client, input)
+}
+
+// GenerateRound runs the beacon round on behalf of client, see Generate, over
+// the input of the round, see timelock.RoundMessage. The node and every other
+// signer refuse the round before the Schedule makes it due, with
+// timelock.ErrTooEarly.
+func (n *Node) GenerateRound(ctx context.Context, client string, round uint64) (*Output, error) {
+	return n.generate(ctx, client, timelock.RoundMessage(round))
+}
+
+func (n *Node) generate(ctx context.Context, client string, input []byte) (*Output, error) {
+	requestID := hex.EncodeToString(input)
+	if err := n.RequestRandomness(client, requestID, input); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-n.WaitRNGRound(requestID):
+	case <-ctx.Done():
+		n.mu.Lock()
+		var partials int
+		if r, ok := n.rounds[requestID]; ok {
+			partials = len(r.partials)
+		}
+		n.mu.Unlock()
+
+		n.finish(requestID)
+		return nil, fmt.Errorf("round %s has %d of %d partials: %w", requestID, partials, n.threshold, ctx.Err())
+	}
+
+	sig, err := n.RecoverBLSSignature(requestID, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.VerifyBLSSignature(input, sig); err != nil {
+		return nil, fmt.Errorf("failed to verify signature: %w", err)
 	}
 
-	n.requestWait[requestID] = make(chan struct{}, 1)
+	out := &Output{
+		RequestID:  requestID,
+		Input:      hex.EncodeToString(input),
+		Signature:  hex.EncodeToString(sig),
+		Randomness: Randomness(sig).String(),
+		Epoch:      n.epoch,
+	}
+	// only BN254 signatures can be verified on chain
+	if proof, err := n.ExportEVMProof(input, sig); err == nil {
+		out.Proof = proof
+	}
+
+	return out, nil
+}
+
+func (n *Node) StartRandomNumberGeneration(requestID string, data []byte) error {
+	n.mu.Lock()
+	closed := n.closed
+	n.mu.Unlock()
+	if closed {
+		return ErrNodeClosed
+	}
+	if len(data) > rng.MaxDataSize {
+		return fmt.Errorf("%w: data of %d bytes", ErrTooLarge, len(data))
+	}
 
 	sig, err := n.Sign(data)
 	if err != nil {
 		return fmt.Errorf("failed to sign data: %w", err)
 	}
 
-	n.mu.Lock()
-	defer n.mu.Unlock()
+	r := &round{
+		data:     data,
+		start:    n.clock.Now(),
+		partials: [][]byte{sig},
+		done:     make(chan struct{}, 1),
+	}
+	if n.threshold == 1 {
+		r.wake()
+	}
 
-	n.requests[requestID] = append(n.requests[requestID], sig)
+	n.mu.Lock()
+	if _, ok := n.rounds[requestID]; ok {
+		n.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRequestStarted, requestID)
+	}
+	// registered before the announcement, partials may come back at once
+	n.rounds[requestID] = r
+	if n.roundTimeout > 0 {
+		r.deadline = n.clock.AfterFunc(n.roundTimeout, func() { n.expire(requestID, r) })
+	}
+	n.mu.Unlock()
 
-	if len(n.requests[requestID]) >= Threshold {
-		n.requestWait[requestID] <- struct{}{}
+	if err := n.rnd.Start(requestID, data); err != nil {
+		n.mu.Lock()
+		delete(n.rounds, requestID)
+		n.mu.Unlock()
+		if r.deadline != nil {
+			r.deadline.Stop()
+		}
+		return fmt.Errorf("failed to start rng protocol: %w", err)
 	}
 
+	n.log.Info("Started round", "request", requestID)
+
 	return nil
 }
 
+// RecoverBLSSignature recovers the threshold signature of a request and
+// finishes it: later partials and replays of the request are dropped.
 func (n *Node) RecoverBLSSignature(requestID string, data []byte) ([]byte, error) {
-	sigShares := n.requests[requestID]
-	if len(sigShares) == 0 {
+	n.mu.Lock()
+	r, ok := n.rounds[requestID]
+	var sigShares [][]byte
+	var rejections []string
+	var expired bool
+	if ok {
+		sigShares = r.partials
+		rejections = r.rejections
+		expired = r.expired
+	}
+	closed := n.closed
+	if ok && closed {
+		delete(n.rounds, requestID)
+	}
+	qual := len(n.qual)
+	n.mu.Unlock()
+
+	if closed {
+		return nil, fmt.Errorf("%w: request %s", ErrNodeClosed, requestID)
+	}
+	if ok && len(rejections) > qual-n.threshold {
+		n.finish(requestID)
+		n.metrics.Round(metrics.OutcomeRejected, n.clock.Now().Sub(r.start))
+		return nil, fmt.Errorf("%w by %d nodes: %s", ErrRequestRejected, len(rejections), rejections[0])
+	}
+	if expired {
+		n.finish(requestID)
+		n.metrics.Round(metrics.OutcomeFailure, n.clock.Now().Sub(r.start))
+		return nil, fmt.Errorf("round %s has %d of %d partials: %w", requestID, len(sigShares), n.threshold, ErrRoundExpired)
+	}
+	if !ok || len(sigShares) == 0 {
 		return nil, errors.New("no signature shares")
 	}
 
-	poly := share.NewPubPoly(Suite, Suite.Point().Base(), n.Result.Key.Commits)
+	key := n.key()
+	if key == nil {
+		return nil, errors.New("DKG not completed")
+	}
 
-	sig, err := ThresholdBLS.Recover(poly, data, sigShares, Threshold, 3)
+	poly := share.NewPubPoly(n.scheme.KeyGroup, n.scheme.KeyGroup.Point().Base(), key.Commits)
+
+	sig, err := n.schemeOf(data).ThresholdScheme.Recover(poly, data, sigShares, n.threshold, qual)
 	if err != nil {
+		n.metrics.Round(metrics.OutcomeFailure, n.clock.Now().Sub(r.start))
 		return nil, fmt.Errorf("failed to recover signature: %w", err)
 	}
 
+	n.finish(requestID)
+	n.metrics.Round(metrics.OutcomeSuccess, n.clock.Now().Sub(r.start))
+
+	n.mu.Lock()
+	n.lastRound = &RoundStatus{
+		RequestID: requestID,
+		Signature: hex.EncodeToString(sig),
+		Partials:  len(sigShares),
+		Time:      n.clock.Now(),
+	}
+	n.mu.Unlock()
+
+	n.log.Info("Recovered signature", "request", requestID, "partials", len(sigShares))
+
 	return sig, nil
 }
 
+// finish drops the state of a request and has the protocol drop its later
+// messages.
+func (n *Node) finish(requestID string) {
+	n.rnd.Finish(requestID)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if r, ok := n.rounds[requestID]; ok && r.deadline != nil {
+		r.deadline.Stop()
+	}
+	delete(n.rounds, requestID)
+}
+
 func (n *Node) VerifyBLSSignature(data []byte, signature []byte) error {
-	poly := share.NewPubPoly(Suite, Suite.Point().Base(), n.Result.Key.Commits)
+	key := n.key()
+	if key == nil {
+		return errors.New("DKG not completed")
+	}
+	return n.schemeOf(data).SigScheme.Verify(key.Public(), data, signature)
+}
 
-	blsSchema := bls.NewSchemeOnG1(SigSuite)
+// ReplayCounters returns the number of RNG messages dropped as replays.
+func (n *Node) ReplayCounters() rng.Counters {
+	return n.rnd.Counters()
+}
 
-	return blsSchema.Verify(poly.Commit(), data, signature)
+// Group returns the committee group of the node, empty for the default one.
+func (n *Node) Group() string {
+	return n.group
+}
+
+// Scheme returns the cryptographic scheme of the node.
+func (n *Node) Scheme() *crypto.Scheme {
+	return n.scheme
+}
+
+// schemeOf returns the scheme of the signatures over data: the inputs of
+// beacon rounds are signed for crypto.PurposeRound, any other input for the
+// purpose of the node scheme. Signers and verifiers tell them apart the same
+// way, whoever started the round.
+func (n *Node) schemeOf(data []byte) *crypto.Scheme {
+	if _, ok := timelock.ParseRound(data); ok {
+		return n.roundScheme
+	}
+	return n.scheme
 }
 
 func (n *Node) GenerateRandomNumber(tblsSig []byte) *big.Int {
+	return Randomness(tblsSig)
+}
+
+// Randomness derives the random number of a round from its threshold
+// signature.
+func Randomness(tblsSig []byte) *big.Int {
 	hash := sha256.Sum256(tblsSig)
 	return big.NewInt(0).SetBytes(hash[:])
 }
 
 func (n *Node) Sign(data []byte) ([]byte, error) {
+	if err := n.canSign(); err != nil {
+		return nil, err
+	}
+	if err := n.checkRound(data); err != nil {
+		return nil, err
+	}
+	return n.signer.SignPartial(n.schemeOf(data).Domain.Purpose, data)
+}
+
+// checkRound fails for the input of a beacon round not due yet, or for any
+// round without a schedule.
+func (n *Node) checkRound(data []byte) error {
+	round, ok := timelock.ParseRound(data)
+	if !ok {
+		return nil
+	}
+	if n.schedule == nil {
+		return fmt.Errorf("%w: round %d", ErrNoSchedule, round)
+	}
+	return n.schedule.Check(round, n.clock.Now())
+}
+
+// canSign returns nil once the node holds a share it may sign with.
+func (n *Node) canSign() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.Result == nil && !n.evicted {
+		return errors.New("DKG not completed")
+	}
+	if !n.qualifiedLocked() {
+		return fmt.Errorf("%w: node %d", ErrNotQualified, n.index)
+	}
+	return nil
+}
+
+// SetResult installs the result of the initial DKG.
+func (n *Node) SetResult(result *pedersen_dkg.Result) {
+	n.setResult(result)
+	n.setShare(result.Key)
+}
+
+// SetHeldResult is SetResult for a result saved without its share, which the
+// signer kept, see Config.SaveResult: the signer keeps its share.
+func (n *Node) SetHeldResult(result *pedersen_dkg.Result) {
+	n.setResult(result)
+}
+
+// SaveResult persists the installed result, see Config.SaveResult, for a
+// result the node did not run itself such as that of a handover.
+func (n *Node) SaveResult() error {
+	n.mu.Lock()
+	result := n.Result
+	n.mu.Unlock()
+
+	if n.saveResult == nil || result == nil {
+		return nil
+	}
+	return n.saveResult(result, n.forgetShare)
+}
+
+// setResult installs the result of a completed DKG.
+func (n *Node) setResult(result *pedersen_dkg.Result) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.setResultLocked(result)
+	n.dkgState = DKGDone
+	n.dkgPhase = ""
+}
+
+// setResultLocked swaps the result of the node and its QUAL, and returns the
+// previous result. n.mu must be held.
+func (n *Node) setResultLocked(result *pedersen_dkg.Result) *pedersen_dkg.Result {
+	previous := n.Result
+	n.Result = result
+	n.qual = n.qual[:0:0]
+	for _, node := range result.QUAL {
+		n.qual = append(n.qual, node.Index)
+	}
+	slices.Sort(n.qual)
+	n.evicted = !slices.Contains(n.qual, n.index)
+	return previous
+}
+
+// QUAL returns the sorted share indices of the nodes qualified by the DKG or
+// refresh the share of the node comes from, nil before the DKG ends.
+func (n *Node) QUAL() []uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return slices.Clone(n.qual)
+}
+
+// Qualified reports whether the node holds a share of the group key: it took
+// part in the QUAL of its share, and no refresh evicted it since.
+func (n *Node) Qualified() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.qualifiedLocked()
+}
+
+func (n *Node) qualifiedLocked() bool {
+	return n.Result != nil && !n.evicted
+}
+
+// setShare hands a new share to the signer, and zeroes it with
+// Config.ForgetShare.
+func (n *Node) setShare(key *pedersen_dkg.DistKeyShare) {
+	if err := n.signer.SetShare(key.PriShare()); err != nil {
+		n.log.Error("Failed to set share of signer", "err", err)
+	}
+	if n.forgetShare {
+		zeroDistKeyShare(key)
+	}
+}
+
+// key returns the current distributed key share, nil before the DKG ends.
+func (n *Node) key() *pedersen_dkg.DistKeyShare {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	if n.Result == nil {
-		return nil, errors.New("DKG not completed")
+		return nil
 	}
-	return ThresholdBLS.Sign(n.Result.Key.PriShare(), data)
+	return n.Result.Key
 }
 
 // HexToBytes converts a hex string to bytes
@@ -1,9 +1,20 @@
 package dkg
 
 import (
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"testing"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/rng"
+	"random-network-poc/signer"
+	"random-network-poc/sim"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/kyber/v4"
@@ -195,6 +206,69 @@ func RunDKG(t *testing.T, tns []*TestNode, conf pedersen_dkg.Config,
 	return results
 }
 
+// testCommittee is a committee with the results of a DKG run by RunDKG, whose
+// nodes run over a simulated network.
+type testCommittee struct {
+	scheme  *crypto.Scheme
+	tns     []*TestNode
+	results []*pedersen_dkg.Result
+	net     *sim.Network
+}
+
+// newTestCommittee runs the DKG of a committee of n validators with a
+// threshold of 2 over the scheme name.
+func newTestCommittee(t *testing.T, name string, n int) *testCommittee {
+	scheme, err := crypto.ParseScheme(name)
+	require.NoError(t, err)
+
+	tns := GenerateTestNodes(scheme.KeyGroup, n)
+	results := RunDKG(t, tns, pedersen_dkg.Config{
+		Suite:     scheme.KeyGroup,
+		NewNodes:  NodesFromTest(tns),
+		Threshold: 2,
+		Auth:      scheme.AuthScheme,
+	}, nil, nil, nil)
+
+	return &testCommittee{
+		scheme:  scheme,
+		tns:     tns,
+		results: results,
+		net:     sim.New(clock.NewVirtual(time.Unix(0, 0)), n, 1, sim.Link{}),
+	}
+}
+
+// node creates the node of validator i, configured by conf unless nil. Its
+// DKG is not started: SetResult hands it its share.
+func (c *testCommittee) node(t *testing.T, i int, conf func(*Config)) *Node {
+	config := &Config{
+		Index:       uint32(i),
+		LongtermKey: c.tns[i].Private,
+		Nonce:       pedersen_dkg.GetNonce(),
+		Scheme:      c.scheme,
+		Nodes:       NodesFromTest(c.tns),
+		Threshold:   2,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Clock:       c.net.Clock(),
+		Transport:   c.net.Transport(i),
+	}
+	if conf != nil {
+		conf(config)
+	}
+
+	n, err := NewNode(t.Context(), config, c.net.Board(i), nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, n.Close()) })
+	return n
+}
+
+// addRound registers a round of the node on data, as if it started it.
+func addRound(n *Node, requestID string, data []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.rounds[requestID] = &round{data: data, done: make(chan struct{}, 1)}
+}
+
 func TestSelfEvictionShareHolder(t *testing.T) {
 	n := 3
 	threshold := 2
@@ -249,3 +323,167 @@ func TestSelfEvictionShareHolder(t *testing.T) {
 	blsSchema := bls.NewSchemeOnG1(sigSuite)
 	require.NoError(t, blsSchema.Verify(poly.Commit(), msg, sig))
 }
+
+func TestDKGSchemes(t *testing.T) {
+	n := 3
+	threshold := 2
+
+	for _, name := range []string{"bn256-g1", "bls12381-g1", "bls12381-g2"} {
+		t.Run(name, func(t *testing.T) {
+			scheme, err := crypto.ParseScheme(name)
+			require.NoError(t, err)
+
+			tns := GenerateTestNodes(scheme.KeyGroup, n)
+
+			conf := pedersen_dkg.Config{
+				Suite:     scheme.KeyGroup,
+				NewNodes:  NodesFromTest(tns),
+				Threshold: threshold,
+				Auth:      scheme.AuthScheme,
+			}
+
+			results := RunDKG(t, tns, conf, nil, nil, nil)
+			testResults(t, scheme.KeyGroup, threshold, n, results)
+
+			msg := []byte("Hello World")
+			poly := share.NewPubPoly(scheme.KeyGroup, scheme.KeyGroup.Point().Base(), results[0].Key.Commits)
+
+			var sigShares [][]byte
+			for _, res := range results[:threshold] {
+				sig, err := scheme.ThresholdScheme.Sign(res.Key.PriShare(), msg)
+				require.NoError(t, err)
+				sigShares = append(sigShares, sig)
+			}
+
+			sig, err := scheme.ThresholdScheme.Recover(poly, msg, sigShares, threshold, n)
+			require.NoError(t, err)
+			require.NoError(t, scheme.SigScheme.Verify(results[0].Key.Public(), msg, sig))
+		})
+	}
+}
+
+func TestHandleRejection(t *testing.T) {
+	c := newTestCommittee(t, "bn256-g1", 3)
+	n := c.node(t, 0, nil)
+	n.SetResult(c.results[0])
+	addRound(n, "round-1", []byte("round-1"))
+
+	require.Error(t, n.HandleSignature(rng.Signature{RequestID: "round-2", Rejected: "rate limited"}))
+
+	// two signers are left, enough for the threshold
+	require.NoError(t, n.HandleSignature(rng.Signature{RequestID: "round-1", Rejected: "rate limited"}))
+	require.Empty(t, n.WaitRNGRound("round-1"))
+
+	require.NoError(t, n.HandleSignature(rng.Signature{RequestID: "round-1", Rejected: "rate limited"}))
+	require.Len(t, n.WaitRNGRound("round-1"), 1)
+}
+
+func TestHandleSignatureVerifiesPartials(t *testing.T) {
+	c := newTestCommittee(t, "bn256-g1", 4)
+	n := c.node(t, 0, nil)
+	n.SetResult(c.results[0])
+	addRound(n, "round-1", []byte("round-1"))
+	addRound(n, "round-2", []byte("round-2"))
+
+	partial := func(i int, requestID, msg string) rng.Signature {
+		sig, err := c.scheme.ThresholdScheme.Sign(c.results[i].Key.PriShare(), []byte(msg))
+		require.NoError(t, err)
+		return rng.Signature{RequestID: requestID, Signature: hex.EncodeToString(sig), SenderIndex: uint32(i)}
+	}
+
+	// signed over other data
+	require.Error(t, n.HandleSignature(partial(1, "round-1", "round-2")))
+	// relayed by another node
+	forged := partial(1, "round-1", "round-1")
+	forged.SenderIndex = 2
+	require.Error(t, n.HandleSignature(forged))
+
+	// two of the four signers are out, the other two reach the threshold
+	require.NoError(t, n.HandleSignature(partial(3, "round-1", "round-1")))
+	require.Empty(t, n.WaitRNGRound("round-1"))
+	require.NoError(t, n.HandleSignature(partial(0, "round-1", "round-1")))
+	require.Len(t, n.WaitRNGRound("round-1"), 1)
+
+	// with three signers out the threshold is out of reach
+	for i := 1; i < 4; i++ {
+		require.Error(t, n.HandleSignature(partial(i, "round-2", "round-1")))
+	}
+	require.Len(t, n.WaitRNGRound("round-2"), 1)
+	require.Len(t, n.rounds["round-2"].rejections, 3)
+	require.Contains(t, n.rounds["round-2"].rejections[0], "invalid partial signature")
+}
+
+// With ForgetShare only the signer keeps the share: the node zeroes its copy
+// and saves its result without it.
+func TestForgetShare(t *testing.T) {
+	c := newTestCommittee(t, "bn256-g1", 3)
+	scheme, results := c.scheme, c.results
+	poly := share.NewPubPoly(scheme.KeyGroup, scheme.KeyGroup.Point().Base(), results[0].Key.Commits)
+
+	var saved []byte
+	sgn := signer.NewLocal(scheme, c.tns[0].Private)
+	forget := func(conf *Config) {
+		conf.Signer = sgn
+		conf.ForgetShare = true
+		conf.SaveResult = func(result *pedersen_dkg.Result, heldBySigner bool) (err error) {
+			require.True(t, heldBySigner)
+			saved, err = ResultToJSON(result, heldBySigner)
+			return err
+		}
+	}
+	n := c.node(t, 0, forget)
+
+	n.SetResult(results[0])
+	n.save(results[0])
+	require.True(t, results[0].Key.Share.V.Equal(scheme.KeyGroup.Scalar().Zero()))
+	require.NotContains(t, string(saved), `"share"`)
+	require.Contains(t, string(saved), `"heldBySigner": true`)
+
+	sign := func(n *Node) {
+		sig, err := n.Sign([]byte("msg"))
+		require.NoError(t, err)
+		require.NoError(t, scheme.ThresholdScheme.VerifyPartial(poly, []byte("msg"), sig))
+	}
+	sign(n)
+
+	// a restarted node loads the saved result, the signer keeps its share
+	loaded, held, err := ResultFromJSON(scheme, saved)
+	require.NoError(t, err)
+	require.True(t, held)
+	require.True(t, loaded.PublicEqual(results[0]))
+	require.NoError(t, n.Close())
+	n = c.node(t, 0, forget)
+	n.SetHeldResult(loaded)
+	sign(n)
+
+	require.ErrorIs(t, n.Refresh(1), ErrShareForgotten)
+	_, err = n.Handover(context.Background(), &HandoverConfig{}, nil)
+	require.ErrorIs(t, err, ErrShareForgotten)
+}
+
+func TestStatus(t *testing.T) {
+	c := newTestCommittee(t, "bn256-g1", 3)
+	results := c.results
+	n := c.node(t, 1, nil)
+	addRound(n, "round-1", []byte("round-1"))
+
+	status := n.Status()
+	require.Equal(t, DKGNotStarted, status.DKG.State)
+	require.Empty(t, status.PublicKey)
+	require.Nil(t, status.ShareIndex)
+	require.False(t, status.Qualified)
+	require.Equal(t, 1, status.PendingRequests)
+	require.ErrorIs(t, n.Ready(), ErrNotReady)
+
+	n.SetResult(results[1])
+
+	status = n.Status()
+	require.Equal(t, DKGDone, status.DKG.State)
+	pub, err := results[1].Key.Public().MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(pub), status.PublicKey)
+	require.Equal(t, uint32(1), *status.ShareIndex)
+	require.Equal(t, []uint32{0, 1, 2}, status.QUAL)
+	require.True(t, status.Qualified)
+	require.NoError(t, n.Ready())
+}
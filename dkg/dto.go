@@ -3,8 +3,12 @@ package dkg
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4/share"
 	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
 )
 
@@ -14,6 +18,23 @@ const (
 	MessageJustificationBundle = 3
 )
 
+// Limits of the board messages decoded from the network, well above what a
+// committee sends. They bound what a message can cost before its signature is
+// checked.
+const (
+	// MaxCommittee bounds the deals, public coefficients, responses and
+	// justifications of a bundle.
+	MaxCommittee = 1024
+	// MaxFieldSize bounds every byte field of a bundle once decoded.
+	MaxFieldSize = 1024
+	// MaxMessageSize bounds an encoded board message, the default message
+	// size limit of pubsub.
+	MaxMessageSize = 1 << 20
+)
+
+// ErrTooLarge is returned for a board message beyond the limits above.
+var ErrTooLarge = errors.New("message exceeds limits")
+
 type Message struct {
 	Type int    `json:"type"`
 	Data []byte `json:"data"`
@@ -65,6 +86,10 @@ func NewJustificationBundleMessage(bundle *pedersen_dkg.JustificationBundle) (*M
 }
 
 func ParseMessage(data []byte) (*Message, error) {
+	if len(data) > MaxMessageSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, len(data))
+	}
+
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, err
@@ -146,30 +171,37 @@ func MarshalDealBundle(bundle *pedersen_dkg.DealBundle) (*DealBundleDTO, error)
 }
 
 // UnmarshalDealBundle converts a DealBundleDTO to a pedersen_dkg.DealBundle
-func UnmarshalDealBundle(dto *DealBundleDTO) (*pedersen_dkg.DealBundle, error) {
+func UnmarshalDealBundle(s *crypto.Scheme, dto *DealBundleDTO) (*pedersen_dkg.DealBundle, error) {
+	if err := checkEntries("deals", len(dto.Deals)); err != nil {
+		return nil, err
+	}
+	if err := checkEntries("public points", len(dto.Public)); err != nil {
+		return nil, err
+	}
+
 	bundle := &pedersen_dkg.DealBundle{
 		DealerIndex: dto.DealerIndex,
 	}
 
 	// Unmarshal session ID
-	sessionID, err := hex.DecodeString(dto.SessionID)
+	sessionID, err := decodeField("session ID", dto.SessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode session ID: %w", err)
+		return nil, err
 	}
 	bundle.SessionID = sessionID
 
 	// Unmarshal signature
-	signature, err := hex.DecodeString(dto.Signature)
+	signature, err := decodeField("signature", dto.Signature)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode signature: %w", err)
+		return nil, err
 	}
 	bundle.Signature = signature
 
 	// Unmarshal deals
 	for _, dealDTO := range dto.Deals {
-		encryptedShare, err := hex.DecodeString(dealDTO.EncryptedShare)
+		encryptedShare, err := decodeField("encrypted share", dealDTO.EncryptedShare)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode encrypted share: %w", err)
+			return nil, err
 		}
 		bundle.Deals = append(bundle.Deals, pedersen_dkg.Deal{
 			ShareIndex:     dealDTO.ShareIndex,
@@ -179,12 +211,12 @@ func UnmarshalDealBundle(dto *DealBundleDTO) (*pedersen_dkg.DealBundle, error) {
 
 	// Unmarshal public points
 	for _, pubStr := range dto.Public {
-		pubBytes, err := hex.DecodeString(pubStr)
+		pubBytes, err := decodeField("public point", pubStr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode public point: %w", err)
+			return nil, err
 		}
-		point := Suite.Point()
-		if err := point.UnmarshalBinary(pubBytes); err != nil {
+		point, err := s.PointFromBytes(pubBytes)
+		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal public point: %w", err)
 		}
 		bundle.Public = append(bundle.Public, point)
@@ -214,35 +246,82 @@ func MarshalResponseBundle(bundle *pedersen_dkg.ResponseBundle) (*ResponseBundle
 
 // UnmarshalResponseBundle converts a ResponseBundleDTO to a pedersen_dkg.ResponseBundle
 func UnmarshalResponseBundle(dto *ResponseBundleDTO) (*pedersen_dkg.ResponseBundle, error) {
+	if err := checkEntries("responses", len(dto.Responses)); err != nil {
+		return nil, err
+	}
+
 	bundle := &pedersen_dkg.ResponseBundle{
 		ShareIndex: dto.ShareIndex,
 	}
 
 	// Unmarshal session ID
-	sessionID, err := hex.DecodeString(dto.SessionID)
+	sessionID, err := decodeField("session ID", dto.SessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode session ID: %w", err)
+		return nil, err
 	}
 	bundle.SessionID = sessionID
 
 	// Unmarshal signature
-	signature, err := hex.DecodeString(dto.Signature)
+	signature, err := decodeField("signature", dto.Signature)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode signature: %w", err)
+		return nil, err
 	}
 	bundle.Signature = signature
 
 	// Unmarshal responses
 	for _, respDTO := range dto.Responses {
+		status, err := decodeStatus(respDTO.Status)
+		if err != nil {
+			return nil, err
+		}
 		bundle.Responses = append(bundle.Responses, pedersen_dkg.Response{
 			DealerIndex: respDTO.DealerIndex,
-			Status:      pedersen_dkg.Status(respDTO.Status),
+			Status:      status,
 		})
 	}
 
 	return bundle, nil
 }
 
+// checkEntries fails for more than MaxCommittee entries of a bundle.
+func checkEntries(what string, n int) error {
+	if n > MaxCommittee {
+		return fmt.Errorf("%w: %d %s", ErrTooLarge, n, what)
+	}
+	return nil
+}
+
+// checkField fails for a byte field larger than MaxFieldSize.
+func checkField(what string, b []byte) error {
+	if len(b) > MaxFieldSize {
+		return fmt.Errorf("%w: %s of %d bytes", ErrTooLarge, what, len(b))
+	}
+	return nil
+}
+
+// decodeField decodes a hex byte field of at most MaxFieldSize bytes.
+func decodeField(what, s string) ([]byte, error) {
+	if len(s) > 2*MaxFieldSize {
+		return nil, fmt.Errorf("%w: %s of %d hex characters", ErrTooLarge, what, len(s))
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", what, err)
+	}
+	return b, nil
+}
+
+// decodeStatus fails for a response status other than Success and
+// Complaint.
+func decodeStatus(status int32) (pedersen_dkg.Status, error) {
+	switch s := pedersen_dkg.Status(status); s {
+	case pedersen_dkg.Success, pedersen_dkg.Complaint:
+		return s, nil
+	default:
+		return 0, fmt.Errorf("unknown response status %d", status)
+	}
+}
+
 // DealBundleToJSON converts a pedersen_dkg.DealBundle to JSON bytes
 func DealBundleToJSON(bundle *pedersen_dkg.DealBundle) ([]byte, error) {
 	dto, err := MarshalDealBundle(bundle)
@@ -253,13 +332,13 @@ func DealBundleToJSON(bundle *pedersen_dkg.DealBundle) ([]byte, error) {
 }
 
 // DealBundleFromJSON converts JSON bytes to a pedersen_dkg.DealBundle
-func DealBundleFromJSON(data []byte) (*pedersen_dkg.DealBundle, error) {
+func DealBundleFromJSON(s *crypto.Scheme, data []byte) (*pedersen_dkg.DealBundle, error) {
 	var dto DealBundleDTO
 	if err := json.Unmarshal(data, &dto); err != nil {
 		return nil, err
 	}
 
-	return UnmarshalDealBundle(&dto)
+	return UnmarshalDealBundle(s, &dto)
 }
 
 // ResponseBundleToJSON converts a pedersen_dkg.ResponseBundle to JSON bytes
@@ -291,13 +370,13 @@ func JustificationBundleToJSON(bundle *pedersen_dkg.JustificationBundle) ([]byte
 }
 
 // JustificationBundleFromJSON converts JSON bytes to a pedersen_dkg.JustificationBundle
-func JustificationBundleFromJSON(data []byte) (*pedersen_dkg.JustificationBundle, error) {
+func JustificationBundleFromJSON(s *crypto.Scheme, data []byte) (*pedersen_dkg.JustificationBundle, error) {
 	var dto JustificationBundleDTO
 	if err := json.Unmarshal(data, &dto); err != nil {
 		return nil, err
 	}
 
-	return UnmarshalJustificationBundle(&dto)
+	return UnmarshalJustificationBundle(s, &dto)
 }
 
 // MarshalJustificationBundle converts a pedersen_dkg.JustificationBundle to a JustificationBundleDTO
@@ -324,15 +403,19 @@ func MarshalJustificationBundle(bundle *pedersen_dkg.JustificationBundle) (*Just
 }
 
 // UnmarshalJustificationBundle converts a JustificationBundleDTO to a pedersen_dkg.JustificationBundle
-func UnmarshalJustificationBundle(dto *JustificationBundleDTO) (*pedersen_dkg.JustificationBundle, error) {
-	sessionID, err := hex.DecodeString(dto.SessionID)
+func UnmarshalJustificationBundle(s *crypto.Scheme, dto *JustificationBundleDTO) (*pedersen_dkg.JustificationBundle, error) {
+	if err := checkEntries("justifications", len(dto.Justifications)); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := decodeField("session ID", dto.SessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode session ID: %w", err)
+		return nil, err
 	}
 
-	signature, err := hex.DecodeString(dto.Signature)
+	signature, err := decodeField("signature", dto.Signature)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode signature: %w", err)
+		return nil, err
 	}
 
 	bundle := &pedersen_dkg.JustificationBundle{
@@ -343,13 +426,13 @@ func UnmarshalJustificationBundle(dto *JustificationBundleDTO) (*pedersen_dkg.Ju
 	}
 
 	for i, justificationDTO := range dto.Justifications {
-		share, err := hex.DecodeString(justificationDTO.Share)
+		share, err := decodeField("share", justificationDTO.Share)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode share: %w", err)
+			return nil, err
 		}
 
-		scalar := Suite.Scalar()
-		if err := scalar.UnmarshalBinary(share); err != nil {
+		scalar, err := s.ScalarFromBytes(share)
+		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal share: %w", err)
 		}
 
@@ -361,3 +444,133 @@ func UnmarshalJustificationBundle(dto *JustificationBundleDTO) (*pedersen_dkg.Ju
 
 	return bundle, nil
 }
+
+// ResultDTO is a Data Transfer Object for pedersen_dkg.Result, the share of a
+// validator written by the dkg command and loaded by the run command.
+type ResultDTO struct {
+	QUAL       []NodeDTO `json:"qual"`
+	Commits    []string  `json:"commits"`
+	ShareIndex uint32    `json:"shareIndex"`
+	Share      string    `json:"share,omitempty"`
+	// HeldBySigner is set for a share held by the signer only, see
+	// Config.ForgetShare. Share is then empty.
+	HeldBySigner bool `json:"heldBySigner,omitempty"`
+}
+
+// NodeDTO is a Data Transfer Object for pedersen_dkg.Node
+type NodeDTO struct {
+	Index  uint32 `json:"index"`
+	Public string `json:"public"`
+}
+
+// MarshalResult converts a pedersen_dkg.Result to a ResultDTO, without the
+// share if heldBySigner.
+func MarshalResult(result *pedersen_dkg.Result, heldBySigner bool) (*ResultDTO, error) {
+	dto := &ResultDTO{
+		ShareIndex:   result.Key.Share.I,
+		HeldBySigner: heldBySigner,
+	}
+
+	if !heldBySigner {
+		share, err := result.Key.Share.V.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal share: %w", err)
+		}
+		dto.Share = hex.EncodeToString(share)
+	}
+
+	for _, node := range result.QUAL {
+		pubBytes, err := node.Public.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal public key: %w", err)
+		}
+		dto.QUAL = append(dto.QUAL, NodeDTO{Index: node.Index, Public: hex.EncodeToString(pubBytes)})
+	}
+
+	for _, commit := range result.Key.Commits {
+		commitBytes, err := commit.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal commitment: %w", err)
+		}
+		dto.Commits = append(dto.Commits, hex.EncodeToString(commitBytes))
+	}
+
+	return dto, nil
+}
+
+// UnmarshalResult converts a ResultDTO to a pedersen_dkg.Result. The share of
+// a result held by the signer is zero.
+func UnmarshalResult(s *crypto.Scheme, dto *ResultDTO) (*pedersen_dkg.Result, error) {
+	if dto.HeldBySigner == (dto.Share != "") {
+		return nil, errors.New("result needs either a share or to be held by the signer")
+	}
+
+	v := s.KeyGroup.Scalar().Zero()
+	if !dto.HeldBySigner {
+		shareBytes, err := hex.DecodeString(dto.Share)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode share: %w", err)
+		}
+		v, err = s.ScalarFromBytes(shareBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+		}
+	}
+
+	result := &pedersen_dkg.Result{
+		Key: &pedersen_dkg.DistKeyShare{
+			Share: &share.PriShare{I: dto.ShareIndex, V: v},
+		},
+	}
+
+	for _, node := range dto.QUAL {
+		pubBytes, err := hex.DecodeString(node.Public)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode public key: %w", err)
+		}
+		public, err := s.PointFromBytes(pubBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal public key: %w", err)
+		}
+		result.QUAL = append(result.QUAL, pedersen_dkg.Node{Index: node.Index, Public: public})
+	}
+
+	for _, commit := range dto.Commits {
+		commitBytes, err := hex.DecodeString(commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode commitment: %w", err)
+		}
+		point, err := s.PointFromBytes(commitBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal commitment: %w", err)
+		}
+		result.Key.Commits = append(result.Key.Commits, point)
+	}
+	if len(result.Key.Commits) == 0 {
+		return nil, errors.New("result without commitments")
+	}
+
+	return result, nil
+}
+
+// ResultToJSON converts a pedersen_dkg.Result to JSON bytes, see
+// MarshalResult.
+func ResultToJSON(result *pedersen_dkg.Result, heldBySigner bool) ([]byte, error) {
+	dto, err := MarshalResult(result, heldBySigner)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(dto, "", "  ")
+}
+
+// ResultFromJSON converts JSON bytes to a pedersen_dkg.Result, and reports
+// whether its share is held by the signer.
+func ResultFromJSON(s *crypto.Scheme, data []byte) (*pedersen_dkg.Result, bool, error) {
+	var dto ResultDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return nil, false, err
+	}
+
+	result, err := UnmarshalResult(s, &dto)
+	return result, dto.HeldBySigner, err
+}
@@ -0,0 +1,40 @@
+package dkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultJSON(t *testing.T) {
+	c := newTestCommittee(t, "bls12381-g1", 3)
+	scheme, results := c.scheme, c.results
+
+	data, err := ResultToJSON(results[1], false)
+	require.NoError(t, err)
+
+	result, held, err := ResultFromJSON(scheme, data)
+	require.NoError(t, err)
+	require.False(t, held)
+	require.True(t, result.PublicEqual(results[1]))
+	require.Equal(t, results[1].Key.Share.I, result.Key.Share.I)
+	require.True(t, results[1].Key.Share.V.Equal(result.Key.Share.V))
+	require.Len(t, result.QUAL, 3)
+
+	_, _, err = ResultFromJSON(scheme, []byte(`{"share":"00"}`))
+	require.Error(t, err)
+
+	// a share held by the signer is left out, explicitly
+	data, err = ResultToJSON(results[1], true)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"share"`)
+	result, held, err = ResultFromJSON(scheme, data)
+	require.NoError(t, err)
+	require.True(t, held)
+	require.True(t, result.PublicEqual(results[1]))
+	require.Equal(t, results[1].Key.Share.I, result.Key.Share.I)
+
+	// a result with neither is corrupt
+	_, _, err = ResultFromJSON(scheme, []byte(`{"shareIndex":1}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package dkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+const evmWordSize = 32
+
+var ErrSchemeNotEVMCompatible = errors.New("scheme is not verifiable on the EVM, use " + crypto.CurveBN254 + "-" + crypto.GroupG1)
+
+// EVMProof is a randomness output encoded as the uint256 coordinates expected
+// by the BN254 precompiles (EIP-196 and EIP-197). G2 coordinates follow the
+// precompile order: x imaginary, x real, y imaginary, y real.
+type EVMProof struct {
+	GroupKey  [4]*big.Int
+	Hash      [2]*big.Int
+	Signature [2]*big.Int
+	// DST is the domain separation tag the verifier hashes inputs with.
+	DST []byte
+}
+
+// ExportEVMProof encodes the group public key, the hash to G1 of the input and
+// the recovered signature for an on-chain verifier. Only the bn254-g1 scheme
+// runs on the curve of the EVM precompiles.
+func ExportEVMProof(s *crypto.Scheme, public kyber.Point, input, signature []byte) (*EVMProof, error) {
+	if s.Curve != crypto.CurveBN254 || !s.SigOnG1 {
+		return nil, ErrSchemeNotEVMCompatible
+	}
+
+	if err := s.SigScheme.Verify(public, input, signature); err != nil {
+		return nil, fmt.Errorf("failed to verify signature: %w", err)
+	}
+
+	pubBytes, err := public.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	hashBytes, err := s.SigGroup.Point().(kyber.HashablePoint).Hash(input).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hash point: %w", err)
+	}
+
+	proof := &EVMProof{DST: s.DST}
+	copy(proof.GroupKey[:], words(pubBytes))
+	copy(proof.Hash[:], words(hashBytes))
+	copy(proof.Signature[:], words(signature))
+
+	return proof, nil
+}
+
+// ExportEVMProof encodes a recovered signature of this node's group.
+func (n *Node) ExportEVMProof(input, signature []byte) (*EVMProof, error) {
+	key := n.key()
+	if key == nil {
+		return nil, errors.New("DKG not completed")
+	}
+	return ExportEVMProof(n.schemeOf(input), key.Public(), input, signature)
+}
+
+// ABIEncode returns abi.encode(uint256[4] groupKey, uint256[2] hash,
+// uint256[2] signature).
+func (p *EVMProof) ABIEncode() []byte {
+	var out []byte
+	for _, w := range p.all() {
+		out = append(out, word(w)...)
+	}
+	return out
+}
+
+// PairingInput returns the EIP-197 input checking
+// e(-signature, G2) * e(hash, groupKey) == 1.
+func (p *EVMProof) PairingInput() []byte {
+	negY := new(big.Int).Sub(bn254P, p.Signature[1])
+	negY.Mod(negY, bn254P)
+
+	var out []byte
+	for _, w := range []*big.Int{p.Signature[0], negY} {
+		out = append(out, word(w)...)
+	}
+	for _, w := range bn254G2 {
+		out = append(out, word(w)...)
+	}
+	for _, w := range append(p.Hash[:], p.GroupKey[:]...) {
+		out = append(out, word(w)...)
+	}
+	return out
+}
+
+// Randomness returns the random value the verifier derives from the
+// signature, equal to GenerateRandomNumber.
+func (p *EVMProof) Randomness() *big.Int {
+	hash := sha256.Sum256(append(word(p.Signature[0]), word(p.Signature[1])...))
+	return new(big.Int).SetBytes(hash[:])
+}
+
+// MarshalJSON encodes the coordinates as decimal strings, as accepted by
+// Solidity tooling for uint256 arguments.
+func (p *EVMProof) MarshalJSON() ([]byte, error) {
+	dec := func(ws []*big.Int) []string {
+		out := make([]string, len(ws))
+		for i, w := range ws {
+			out[i] = w.String()
+		}
+		return out
+	}
+	return json.Marshal(struct {
+		GroupKey  []string `json:"groupKey"`
+		Hash      []string `json:"hash"`
+		Signature []string `json:"signature"`
+		DST       string   `json:"dst"`
+	}{
+		GroupKey:  dec(p.GroupKey[:]),
+		Hash:      dec(p.Hash[:]),
+		Signature: dec(p.Signature[:]),
+		DST:       "0x" + hex.EncodeToString(p.DST),
+	})
+}
+
+func (p *EVMProof) all() []*big.Int {
+	out := append([]*big.Int{}, p.GroupKey[:]...)
+	out = append(out, p.Hash[:]...)
+	return append(out, p.Signature[:]...)
+}
+
+// bn254P is the base field modulus of the EVM curve.
+var bn254P = bigFromDecimal("21888242871839275222246405745257275088696311157297823662689037894645226208583")
+
+// bn254G2 is the G2 generator in precompile order.
+var bn254G2 = [4]*big.Int{
+	bigFromDecimal("11559732032986387107991004021392285783925812861821192530917403151452391805634"),
+	bigFromDecimal("10857046999023057135944570762232829481370756359578518086990519993285655852781"),
+	bigFromDecimal("4082367875863433681332203403145435568316851327593401208105741076214120093531"),
+	bigFromDecimal("8495653923123431417604973247489272438418190587263600148770280649306958101930"),
+}
+
+func bigFromDecimal(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid decimal " + s)
+	}
+	return n
+}
+
+// words splits a big-endian point encoding into uint256 words.
+func words(b []byte) []*big.Int {
+	out := make([]*big.Int, 0, len(b)/evmWordSize)
+	for i := 0; i+evmWordSize <= len(b); i += evmWordSize {
+		out = append(out, new(big.Int).SetBytes(b[i:i+evmWordSize]))
+	}
+	return out
+}
+
+func word(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, evmWordSize))
+}
@@ -0,0 +1,192 @@
+package dkg
+
+import (
+	"math/big"
+	"testing"
+
+	"random-network-poc/crypto"
+
+	bn "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+	"golang.org/x/crypto/sha3"
+)
+
+// evmPairing runs the EIP-197 precompile logic of go-ethereum on input.
+func evmPairing(t *testing.T, input []byte) bool {
+	require.Zero(t, len(input)%192)
+
+	var g1s []*bn.G1
+	var g2s []*bn.G2
+	for i := 0; i < len(input); i += 192 {
+		g1 := new(bn.G1)
+		_, err := g1.Unmarshal(input[i : i+64])
+		require.NoError(t, err)
+		g2 := new(bn.G2)
+		_, err = g2.Unmarshal(input[i+64 : i+192])
+		require.NoError(t, err)
+		g1s = append(g1s, g1)
+		g2s = append(g2s, g2)
+	}
+
+	return bn.PairingCheck(g1s, g2s)
+}
+
+func recoverEVMSignature(t *testing.T, s *crypto.Scheme, msg []byte) (*share.PubPoly, []byte) {
+	pri := share.NewPriPoly(s.KeyGroup, 2, nil, random.New())
+	pub := pri.Commit(s.KeyGroup.Point().Base())
+
+	var partials [][]byte
+	for _, sh := range pri.Shares(3)[1:] {
+		sig, err := s.ThresholdScheme.Sign(sh, msg)
+		require.NoError(t, err)
+		partials = append(partials, sig)
+	}
+
+	sig, err := s.ThresholdScheme.Recover(pub, msg, partials, 2, 3)
+	require.NoError(t, err)
+
+	return pub, sig
+}
+
+func TestEVMProofPairing(t *testing.T) {
+	scheme, err := crypto.ParseScheme("bn254-g1")
+	require.NoError(t, err)
+
+	msg := []byte("block 1")
+	pub, sig := recoverEVMSignature(t, scheme, msg)
+
+	proof, err := ExportEVMProof(scheme, pub.Commit(), msg, sig)
+	require.NoError(t, err)
+	require.Len(t, proof.ABIEncode(), 8*evmWordSize)
+	require.True(t, evmPairing(t, proof.PairingInput()))
+	require.Equal(t, (&Node{}).GenerateRandomNumber(sig), proof.Randomness())
+
+	// a signature over another input must not pass the precompile
+	_, other := recoverEVMSignature(t, scheme, []byte("block 2"))
+	copy(proof.Signature[:], words(other))
+	require.False(t, evmPairing(t, proof.PairingInput()))
+}
+
+func TestEVMProofRejectsOtherSchemes(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	_, err := ExportEVMProof(scheme, scheme.KeyGroup.Point().Base(), nil, nil)
+	require.ErrorIs(t, err, ErrSchemeNotEVMCompatible)
+}
+
+func TestEVMGenerator(t *testing.T) {
+	scheme, err := crypto.ParseScheme("bn254-g1")
+	require.NoError(t, err)
+
+	base, err := scheme.KeyGroup.Point().Base().MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, bn254G2[:], words(base))
+
+	gethBase := new(bn.G2).ScalarBaseMult(big.NewInt(1)).Marshal()
+	require.Equal(t, bn254G2[:], words(gethBase))
+}
+
+// TestEVMHashToPoint checks the reference contract hashing, mirrored below
+// step by step, against the hash used when signing.
+func TestEVMHashToPoint(t *testing.T) {
+	scheme, err := crypto.ParseScheme("bn254-g1")
+	require.NoError(t, err)
+
+	for _, msg := range [][]byte{nil, []byte("abc"), make([]byte, 200)} {
+		pub, sig := recoverEVMSignature(t, scheme, msg)
+		proof, err := ExportEVMProof(scheme, pub.Commit(), msg, sig)
+		require.NoError(t, err)
+
+		x, y := solidityHashToPoint(scheme.DST, msg)
+		require.Equal(t, proof.Hash[0], x)
+		require.Equal(t, proof.Hash[1], y)
+	}
+}
+
+func solidityHashToPoint(domain, msg []byte) (*big.Int, *big.Int) {
+	u := solidityExpandMsg(domain, msg)
+	u0 := new(big.Int).Mod(new(big.Int).SetBytes(u[:48]), bn254P)
+	u1 := new(big.Int).Mod(new(big.Int).SetBytes(u[48:]), bn254P)
+
+	g1 := new(bn.G1)
+	g2 := new(bn.G1)
+	_, _ = g1.Unmarshal(solidityMapToPoint(u0))
+	_, _ = g2.Unmarshal(solidityMapToPoint(u1))
+
+	out := words(new(bn.G1).Add(g1, g2).Marshal())
+	return out[0], out[1]
+}
+
+func solidityExpandMsg(domain, msg []byte) []byte {
+	keccak := func(parts ...[]byte) []byte {
+		h := sha3.NewLegacyKeccak256()
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+
+	dstPrime := append(append([]byte{}, domain...), byte(len(domain)))
+	b0 := keccak(make([]byte, 136), msg, []byte{0, 96, 0}, dstPrime)
+	b1 := keccak(b0, []byte{1}, dstPrime)
+
+	xor := func(a, b []byte) []byte {
+		out := make([]byte, len(a))
+		for i := range a {
+			out[i] = a[i] ^ b[i]
+		}
+		return out
+	}
+	b2 := keccak(xor(b0, b1), []byte{2}, dstPrime)
+	b3 := keccak(xor(b0, b2), []byte{3}, dstPrime)
+
+	return append(append(b1, b2...), b3...)
+}
+
+func solidityMapToPoint(u *big.Int) []byte {
+	p := bn254P
+	c1 := big.NewInt(4)
+	c2 := bigFromDecimal("10944121435919637611123202872628637544348155578648911831344518947322613104291")
+	c3 := bigFromDecimal("8815841940592487685674414971303048083897117035520822607866")
+	c4 := bigFromDecimal("7296080957279758407415468581752425029565437052432607887563012631548408736189")
+
+	mul := func(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Mul(a, b), p) }
+	add := func(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Add(a, b), p) }
+	sub := func(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Sub(a, b), p) }
+	exp := func(a *big.Int, e *big.Int) *big.Int { return new(big.Int).Exp(a, e, p) }
+	g := func(x *big.Int) *big.Int { return add(mul(mul(x, x), x), big.NewInt(3)) }
+	isSquare := func(x *big.Int) bool {
+		e := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+		return exp(x, e).Cmp(big.NewInt(1)) == 0
+	}
+	sqrt := func(x *big.Int) *big.Int {
+		return exp(x, new(big.Int).Rsh(new(big.Int).Add(p, big.NewInt(1)), 2))
+	}
+
+	tv1 := mul(mul(u, u), c1)
+	tv2 := add(big.NewInt(1), tv1)
+	tv1 = sub(big.NewInt(1), tv1)
+	tv3 := exp(mul(tv1, tv2), new(big.Int).Sub(p, big.NewInt(2)))
+	tv5 := mul(mul(mul(u, tv1), tv3), c3)
+	x1 := sub(c2, tv5)
+	x2 := add(c2, tv5)
+	tv8 := mul(mul(tv2, tv2), tv3)
+	x3 := add(big.NewInt(1), mul(c4, mul(tv8, tv8)))
+
+	var x *big.Int
+	switch {
+	case isSquare(g(x1)):
+		x = x1
+	case isSquare(g(x2)):
+		x = x2
+	default:
+		x = x3
+	}
+	y := sqrt(g(x))
+	if u.Bit(0) != y.Bit(0) {
+		y = sub(big.NewInt(0), y)
+	}
+
+	return append(word(x), word(y)...)
+}
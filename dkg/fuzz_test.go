@@ -0,0 +1,174 @@
+package dkg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"random-network-poc/crypto"
+	"random-network-poc/wire"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// The decoders below read untrusted network input. Every target checks that
+// what decodes re-encodes to the same bundle, and that points and scalars are
+// only read from their canonical encoding.
+
+// requireCanonical fails unless the hex fields decode to values that marshal
+// back to them.
+func requireCanonical[T interface{ MarshalBinary() ([]byte, error) }](t *testing.T, fields []string, values []T) {
+	require.Len(t, values, len(fields))
+	for i, field := range fields {
+		b, err := values[i].MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, strings.ToLower(field), hex.EncodeToString(b))
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	deal, resp, just := testBundles(crypto.DefaultScheme(), 2)
+	for _, bundle := range []any{deal, resp, just} {
+		data, err := EncodeBoardMessage(wire.FormatJSON, bundle)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+	f.Add([]byte(`{"type":1,"data":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := ParseMessage(data)
+		if err != nil {
+			return
+		}
+		again, err := json.Marshal(msg)
+		require.NoError(t, err)
+		got, err := ParseMessage(again)
+		require.NoError(t, err)
+		require.Equal(t, msg, got)
+	})
+}
+
+func FuzzDealBundleFromJSON(f *testing.F) {
+	scheme := crypto.DefaultScheme()
+	deal, _, _ := testBundles(scheme, 2)
+	data, err := DealBundleToJSON(deal)
+	require.NoError(f, err)
+	f.Add(data)
+	f.Add([]byte(`{"dealerIndex":1,"deals":[{"shareIndex":0,"encryptedShare":"00"}],"public":["00"]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bundle, err := DealBundleFromJSON(scheme, data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(bundle.Deals), MaxCommittee)
+		require.LessOrEqual(t, len(bundle.Public), MaxCommittee)
+
+		var dto DealBundleDTO
+		require.NoError(t, json.Unmarshal(data, &dto))
+		requireCanonical(t, dto.Public, bundle.Public)
+
+		again, err := DealBundleToJSON(bundle)
+		require.NoError(t, err)
+		got, err := DealBundleFromJSON(scheme, again)
+		require.NoError(t, err)
+		requireSameHash(t, bundle, got)
+	})
+}
+
+func FuzzResponseBundleFromJSON(f *testing.F) {
+	_, resp, _ := testBundles(crypto.DefaultScheme(), 2)
+	data, err := ResponseBundleToJSON(resp)
+	require.NoError(f, err)
+	f.Add(data)
+	f.Add([]byte(`{"shareIndex":1,"responses":[{"dealerIndex":0,"status":1}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bundle, err := ResponseBundleFromJSON(data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(bundle.Responses), MaxCommittee)
+		for _, resp := range bundle.Responses {
+			require.Contains(t, []pedersen_dkg.Status{pedersen_dkg.Success, pedersen_dkg.Complaint}, resp.Status)
+		}
+
+		again, err := ResponseBundleToJSON(bundle)
+		require.NoError(t, err)
+		got, err := ResponseBundleFromJSON(again)
+		require.NoError(t, err)
+		require.Equal(t, bundle, got)
+	})
+}
+
+func FuzzJustificationBundleFromJSON(f *testing.F) {
+	scheme := crypto.DefaultScheme()
+	_, _, just := testBundles(scheme, 2)
+	data, err := JustificationBundleToJSON(just)
+	require.NoError(f, err)
+	f.Add(data)
+	f.Add([]byte(`{"dealerIndex":1,"justifications":[{"shareIndex":0,"share":"00"}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bundle, err := JustificationBundleFromJSON(scheme, data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(bundle.Justifications), MaxCommittee)
+
+		var dto JustificationBundleDTO
+		require.NoError(t, json.Unmarshal(data, &dto))
+		shares := make([]string, len(dto.Justifications))
+		values := make([]kyber.Scalar, len(bundle.Justifications))
+		for i := range dto.Justifications {
+			shares[i] = dto.Justifications[i].Share
+			values[i] = bundle.Justifications[i].Share
+		}
+		requireCanonical(t, shares, values)
+
+		again, err := JustificationBundleToJSON(bundle)
+		require.NoError(t, err)
+		got, err := JustificationBundleFromJSON(scheme, again)
+		require.NoError(t, err)
+		requireSameHash(t, bundle, got)
+	})
+}
+
+func FuzzDecodeBoardMessage(f *testing.F) {
+	scheme := crypto.DefaultScheme()
+	deal, resp, just := testBundles(scheme, 2)
+	for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+		for _, bundle := range []any{deal, resp, just} {
+			data, err := EncodeBoardMessage(format, bundle)
+			require.NoError(f, err)
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bundle, err := DecodeBoardMessage(scheme, data)
+		if err != nil {
+			return
+		}
+
+		again, err := EncodeBoardMessage(wire.Detect(data), bundle)
+		require.NoError(t, err)
+		got, err := DecodeBoardMessage(scheme, again)
+		require.NoError(t, err)
+
+		switch b := bundle.(type) {
+		case *pedersen_dkg.DealBundle:
+			require.LessOrEqual(t, len(b.Deals), MaxCommittee)
+			requireSameHash(t, b, got.(*pedersen_dkg.DealBundle))
+		case *pedersen_dkg.ResponseBundle:
+			require.LessOrEqual(t, len(b.Responses), MaxCommittee)
+			requireSameHash(t, b, got.(*pedersen_dkg.ResponseBundle))
+		case *pedersen_dkg.JustificationBundle:
+			require.LessOrEqual(t, len(b.Justifications), MaxCommittee)
+			requireSameHash(t, b, got.(*pedersen_dkg.JustificationBundle))
+		}
+	})
+}
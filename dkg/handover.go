@@ -0,0 +1,225 @@
+package dkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/metrics"
+	"random-network-poc/signer"
+
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var (
+	ErrHandoverChangedKey = errors.New("handover produced a different group public key")
+	ErrHandoverNotDealt   = errors.New("leaving validator did not deal")
+)
+
+// HandoverNonce derives the session nonce of the handover into a committee
+// epoch from the nonce of the initial DKG, as RefreshNonce.
+func HandoverNonce(nonce []byte, epoch uint64) []byte {
+	h := sha256.New()
+	h.Write([]byte("handover"))
+	h.Write(nonce)
+	binary.Write(h, binary.BigEndian, epoch)
+	return h.Sum(nil)
+}
+
+// HandoverConfig is the resharing of the group key from a committee to the
+// next one.
+type HandoverConfig struct {
+	// Scheme defaults to crypto.DefaultScheme.
+	Scheme   *crypto.Scheme
+	Longterm kyber.Scalar
+	// Signer signs the bundles with Longterm, defaults to signing in
+	// process.
+	Signer signer.Signer
+	// Old is the committee holding the key, New the one taking it over. A
+	// validator may be in both, with its own index in each.
+	Old          []pedersen_dkg.Node
+	OldThreshold int
+	New          []pedersen_dkg.Node
+	Threshold    int
+	// Share is the share of a member of Old. Commits, the public polynomial
+	// of the key, is only needed by the members of New outside Old.
+	Share   *pedersen_dkg.DistKeyShare
+	Commits []kyber.Point
+	Nonce   []byte
+	// Clock times the phases, defaults to the wall clock.
+	Clock   clock.Clock
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+}
+
+// Handover runs the resharing over board, which carries the bundles of both
+// committees, and returns the share of the validator in New: nil for a
+// validator leaving. As a refresh it keeps the group public key. It returns
+// once the last phase is over, or as soon as ctx is done.
+func Handover(ctx context.Context, c *HandoverConfig, board pedersen_dkg.Board) (*pedersen_dkg.Result, error) {
+	scheme := c.Scheme
+	if scheme == nil {
+		scheme = crypto.DefaultScheme()
+	}
+	clk := c.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	sgn := c.Signer
+	if sgn == nil {
+		sgn = signer.NewLocal(scheme, c.Longterm)
+	}
+
+	var public kyber.Point
+	switch {
+	case c.Share != nil:
+		public = c.Share.Public()
+	case len(c.Commits) > 0:
+		public = c.Commits[0]
+	default:
+		return nil, errors.New("handover needs a share or the commits of the key")
+	}
+
+	conf := &pedersen_dkg.Config{
+		Suite:        scheme.KeyGroup,
+		Longterm:     c.Longterm,
+		OldNodes:     c.Old,
+		NewNodes:     c.New,
+		Share:        c.Share,
+		Threshold:    c.Threshold,
+		OldThreshold: c.OldThreshold,
+		Nonce:        c.Nonce,
+		Auth:         signer.AuthScheme(sgn, scheme.AuthScheme),
+		Log:          NewLogger(logger.With("session", hex.EncodeToString(c.Nonce))),
+	}
+	if c.Share == nil {
+		conf.PublicCoeffs = c.Commits
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	phaser := newBoardPhaser(ctx, clk, board, metrics.KindHandover, c.Metrics, done)
+	defer phaser.release()
+
+	dealer := &dealerBoard{Board: board}
+	protocol, err := pedersen_dkg.NewProtocol(conf, dealer, phaser, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create handover protocol: %w", err)
+	}
+
+	start := clk.Now()
+	phaser.Start()
+
+	result := <-protocol.WaitEnd()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	err = result.Error
+	// a validator leaving gets no share, and the protocol fails it as
+	// responses come in: its part is done once it dealt
+	if leaving(scheme, c.Longterm, c.New) {
+		switch {
+		case dealer.dealt.Load():
+			err = nil
+		case err == nil:
+			err = ErrHandoverNotDealt
+		}
+		result.Result = nil
+	}
+	if err == nil && result.Result != nil && !result.Result.Key.Public().Equal(public) {
+		err = ErrHandoverChangedKey
+	}
+	c.Metrics.DKGRun(metrics.KindHandover, err, clk.Now().Sub(start))
+	if err != nil {
+		return nil, fmt.Errorf("handover failed: %w", err)
+	}
+	return result.Result, nil
+}
+
+// dealerBoard records whether the validator pushed its deals.
+type dealerBoard struct {
+	pedersen_dkg.Board
+	dealt atomic.Bool
+}
+
+func (b *dealerBoard) PushDeals(bundle *pedersen_dkg.DealBundle) {
+	b.Board.PushDeals(bundle)
+	b.dealt.Store(true)
+}
+
+// leaving reports whether the validator of longterm is outside nodes.
+func leaving(s *crypto.Scheme, longterm kyber.Scalar, nodes []pedersen_dkg.Node) bool {
+	public := s.KeyGroup.Point().Mul(longterm, nil)
+	return !slices.ContainsFunc(nodes, func(node pedersen_dkg.Node) bool {
+		return node.Public.Equal(public)
+	})
+}
+
+// Handover hands the share of the node over to the committee c.New, see
+// Handover: the node fills in the keys, the old committee and the share.
+// Refreshes wait for the handover to end, so every dealer deals from the
+// share the others expect. The handover stops once the node is closed.
+func (n *Node) Handover(ctx context.Context, c *HandoverConfig, board pedersen_dkg.Board) (*pedersen_dkg.Result, error) {
+	n.refreshMu.Lock()
+	defer n.refreshMu.Unlock()
+
+	if err := n.canSign(); err != nil {
+		return nil, err
+	}
+	if n.forgetShare {
+		return nil, ErrShareForgotten
+	}
+
+	conf := *c
+	conf.Scheme = n.scheme
+	conf.Longterm = n.privateKey
+	conf.Signer = n.signer
+	conf.Old = n.nodes
+	conf.OldThreshold = n.threshold
+	conf.Share = n.key()
+	conf.Commits = nil
+	conf.Clock = n.clock
+	if conf.Logger == nil {
+		conf.Logger = n.log
+	}
+	if conf.Metrics == nil {
+		conf.Metrics = n.metrics
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(n.ctx, cancel)
+	defer stop()
+
+	result, err := Handover(ctx, &conf, board)
+	if n.ctx.Err() != nil {
+		return nil, ErrNodeClosed
+	}
+	return result, err
+}
+
+// Commits returns the public polynomial of the group key, nil before the DKG
+// ends. The members of the next committee outside this one need it, see
+// HandoverConfig.Commits.
+func (n *Node) Commits() []kyber.Point {
+	key := n.key()
+	if key == nil {
+		return nil
+	}
+	return slices.Clone(key.Commits)
+}
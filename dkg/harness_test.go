@@ -0,0 +1,260 @@
+package dkg
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"random-network-poc/byzantine"
+	"random-network-poc/crypto"
+	"random-network-poc/envelope"
+	"random-network-poc/rng"
+	"random-network-poc/signer"
+	"random-network-poc/timelock"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/share"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// cluster is a committee of full validators, each running its node, board and
+// RNG protocol, over an in-memory libp2p network.
+type cluster struct {
+	scheme    *crypto.Scheme
+	threshold int
+	nodes     []*Node
+	boards    []*BoardP2P
+	// baseline is the number of goroutines running before the nodes were
+	// created, see requireNoLeak.
+	baseline int
+
+	// faulty are the byzantine boards and signers of the faulty nodes, by
+	// index.
+	faultyBoards  map[int]*byzantine.Board
+	faultySigners map[int]*byzantine.Signer
+}
+
+// newCluster runs n validators with the given threshold over an in-memory
+// network whose pubsub outlives them, and returns once every topic is meshed.
+func newCluster(t *testing.T, ctx context.Context, n, threshold int) *cluster {
+	return newFaultyCluster(t, ctx, n, threshold, nil)
+}
+
+// newFaultyCluster is newCluster with the nodes in faulty misbehaving by their
+// rules, through a byzantine board and signer.
+func newFaultyCluster(t *testing.T, ctx context.Context, n, threshold int, faulty map[int][]byzantine.Rule) *cluster {
+	psCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mn, err := mocknet.FullMeshLinked(n)
+	require.NoError(t, err)
+	t.Cleanup(func() { mn.Close() })
+
+	var pss []*pubsub.PubSub
+	for _, h := range mn.Hosts() {
+		ps, err := pubsub.NewGossipSub(psCtx, h)
+		require.NoError(t, err)
+		pss = append(pss, ps)
+	}
+	require.NoError(t, mn.ConnectAllButSelf())
+	// lets the connections and pubsub streams settle
+	time.Sleep(time.Second)
+
+	c := &cluster{
+		scheme:    crypto.DefaultScheme(),
+		threshold: threshold,
+		baseline:  runtime.NumGoroutine(),
+
+		faultyBoards:  make(map[int]*byzantine.Board),
+		faultySigners: make(map[int]*byzantine.Signer),
+	}
+
+	tns := GenerateTestNodes(c.scheme.KeyGroup, n)
+	nonce := pedersen_dkg.GetNonce()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// round 0 is due, round 1 not before the test ends
+	schedule := &timelock.Schedule{Genesis: time.Now(), Period: time.Hour}
+
+	for i, h := range mn.Hosts() {
+		longterm, err := tns[i].Private.MarshalBinary()
+		require.NoError(t, err)
+
+		codec := envelope.New(&envelope.Config{Scheme: c.scheme, Index: uint32(i), Longterm: tns[i].Private, Nodes: NodesFromTest(tns)})
+
+		board, err := NewBoardP2P(ctx, pss[i], h.ID(), c.scheme, nil, codec, logger, nil)
+		require.NoError(t, err)
+
+		var dkgBoard pedersen_dkg.Board = board
+		var sgn signer.Signer
+		if rules, ok := faulty[i]; ok {
+			local := signer.NewLocal(c.scheme, tns[i].Private)
+			c.faultyBoards[i] = byzantine.NewBoard(board, local, rules...)
+			c.faultySigners[i], err = byzantine.NewSigner(local, rules...)
+			require.NoError(t, err)
+			dkgBoard, sgn = c.faultyBoards[i], c.faultySigners[i]
+		}
+
+		node, err := NewNode(ctx, &Config{
+			Index:     uint32(i),
+			Longterm:  longterm,
+			Nonce:     nonce,
+			Scheme:    c.scheme,
+			Nodes:     NodesFromTest(tns),
+			Threshold: threshold,
+			Envelope:  codec,
+			Logger:    logger,
+			Signer:    sgn,
+			Schedule:  schedule,
+		}, dkgBoard, pss[i], h)
+		require.NoError(t, err)
+
+		c.nodes = append(c.nodes, node)
+		c.boards = append(c.boards, board)
+	}
+
+	require.Eventually(t, func() bool {
+		for _, node := range c.nodes {
+			for _, peers := range node.topicPeers() {
+				if peers != n-1 {
+					return false
+				}
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	// lets gossipsub build its mesh before the first publish
+	time.Sleep(time.Second)
+
+	return c
+}
+
+// runDKG runs the DKG on every node and returns the group public key, failing
+// unless every node agrees on it and holds a distinct share.
+func (c *cluster) runDKG(t *testing.T) kyber.Point {
+	for _, node := range c.nodes {
+		node.StartDKG()
+	}
+
+	var public kyber.Point
+	indices := make(map[uint32]bool)
+	for i, node := range c.nodes {
+		result, err := node.WaitDKG()
+		require.NoError(t, err, "node %d", i)
+		require.Len(t, result.QUAL, len(c.nodes), "node %d", i)
+
+		if public == nil {
+			public = result.Key.Public()
+		}
+		require.True(t, public.Equal(result.Key.Public()), "node %d", i)
+
+		require.False(t, indices[result.Key.Share.I], "node %d", i)
+		indices[result.Key.Share.I] = true
+	}
+
+	return public
+}
+
+// generate runs a round started by node i and returns its output, failing
+// unless every node accepts it and the partial signatures of any threshold of
+// nodes recover the same signature.
+func (c *cluster) generate(t *testing.T, ctx context.Context, i int, input []byte) *Output {
+	out, err := c.nodes[i].Generate(ctx, "harness", input)
+	require.NoError(t, err)
+
+	sig, err := hex.DecodeString(out.Signature)
+	require.NoError(t, err)
+	for j, node := range c.nodes {
+		require.NoError(t, node.VerifyBLSSignature(input, sig), "node %d", j)
+	}
+
+	// threshold signatures are unique, so the last threshold nodes recover
+	// the output of the round whoever took part in it
+	var partials [][]byte
+	for _, node := range c.nodes[len(c.nodes)-c.threshold:] {
+		partial, err := node.Sign(input)
+		require.NoError(t, err)
+		partials = append(partials, partial)
+	}
+	key := c.nodes[0].key()
+	poly := share.NewPubPoly(c.scheme.KeyGroup, c.scheme.KeyGroup.Point().Base(), key.Commits)
+	recovered, err := c.nodes[0].schemeOf(input).ThresholdScheme.Recover(poly, input, partials, c.threshold, len(c.nodes))
+	require.NoError(t, err)
+	require.Equal(t, out.Signature, hex.EncodeToString(recovered))
+	require.Equal(t, Randomness(recovered).String(), out.Randomness)
+
+	return out
+}
+
+func (c *cluster) close(t *testing.T) {
+	for i := range c.nodes {
+		require.NoError(t, c.nodes[i].Close())
+		require.NoError(t, c.boards[i].Close())
+	}
+}
+
+func TestCluster(t *testing.T) {
+	for _, tc := range []struct {
+		n, threshold int
+	}{
+		{3, 2},
+		{4, 3},
+		{5, 3},
+		{7, 5},
+	} {
+		t.Run(fmt.Sprintf("%d-of-%d", tc.threshold, tc.n), func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := newCluster(t, ctx, tc.n, tc.threshold)
+			defer c.close(t)
+
+			public := c.runDKG(t)
+
+			outputs := make(map[string]bool)
+			for round := range 4 {
+				// every round is started by another node
+				out := c.generate(t, ctx, round%tc.n, []byte(fmt.Sprintf("round-%d", round)))
+				require.False(t, outputs[out.Randomness])
+				outputs[out.Randomness] = true
+
+				sig, err := hex.DecodeString(out.Signature)
+				require.NoError(t, err)
+				require.NoError(t, c.scheme.SigScheme.Verify(public, []byte(fmt.Sprintf("round-%d", round)), sig))
+			}
+
+			// beacon rounds run through GenerateRound only, once due, and
+			// are signed for their own purpose only
+			input := timelock.RoundMessage(0)
+			_, err := c.nodes[0].Generate(ctx, "harness", input)
+			require.ErrorIs(t, err, ErrRoundInput)
+			_, err = c.nodes[0].GenerateRound(ctx, "harness", 1)
+			require.ErrorIs(t, err, timelock.ErrTooEarly)
+			early, err := c.nodes[1].SignVRF(rng.SignVRF{RequestID: "early", Data: hex.EncodeToString(timelock.RoundMessage(1))})
+			require.NoError(t, err)
+			require.Contains(t, early.Rejected, timelock.ErrTooEarly.Error())
+
+			out, err := c.nodes[0].GenerateRound(ctx, "harness", 0)
+			require.NoError(t, err)
+			sig, err := hex.DecodeString(out.Signature)
+			require.NoError(t, err)
+			round, err := c.scheme.WithPurpose(crypto.PurposeRound)
+			require.NoError(t, err)
+			require.NoError(t, round.SigScheme.Verify(public, input, sig))
+			require.Error(t, c.scheme.SigScheme.Verify(public, input, sig))
+
+			for i, node := range c.nodes {
+				require.Zero(t, node.Status().PendingRequests, "node %d", i)
+			}
+		})
+	}
+}
@@ -2,8 +2,9 @@ package dkg
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 
 	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
@@ -15,17 +16,25 @@ type HttpBoard struct {
 	index  uint32
 	client *http.Client
 	peers  map[int]string
+	log    *slog.Logger
 
 	deals chan pedersen_dkg.DealBundle
 	resps chan pedersen_dkg.ResponseBundle
 	justs chan pedersen_dkg.JustificationBundle
 }
 
-func NewHttpBoard(index uint32, client *http.Client, peers map[int]string) *HttpBoard {
+// NewHttpBoard returns a board posting bundles to peers. A nil logger logs to
+// slog.Default.
+func NewHttpBoard(index uint32, client *http.Client, peers map[int]string, logger *slog.Logger) *HttpBoard {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &HttpBoard{
 		index:  index,
 		client: client,
 		peers:  peers,
+		log:    logger.With("node", index),
 		deals:  make(chan pedersen_dkg.DealBundle, 3),
 		resps:  make(chan pedersen_dkg.ResponseBundle, 3),
 		justs:  make(chan pedersen_dkg.JustificationBundle, 3),
@@ -33,7 +42,7 @@ func NewHttpBoard(index uint32, client *http.Client, peers map[int]string) *Http
 }
 
 func (b *HttpBoard) PushDeals(deal *pedersen_dkg.DealBundle) {
-	log.Printf("Pushing deal to peers\n")
+	b.log.Info("Pushing deal to peers", "session", hex.EncodeToString(deal.SessionID))
 
 	for index, peer := range b.peers {
 		if index == int(b.index) {
@@ -50,7 +59,7 @@ func (b *HttpBoard) pushDeal(peer string, bundle *pedersen_dkg.DealBundle) {
 	// Convert deal bundle to JSON
 	dealBytes, err := DealBundleToJSON(bundle)
 	if err != nil {
-		log.Printf("failed to encode deal bundle: %s\n", err)
+		b.log.Error("Failed to encode deal bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
@@ -58,7 +67,7 @@ func (b *HttpBoard) pushDeal(peer string, bundle *pedersen_dkg.DealBundle) {
 
 	resp, err := http.Post(url, "application/json", buf)
 	if err != nil {
-		log.Printf("failed to send HTTP request: %s\n", err)
+		b.log.Error("Failed to send HTTP request", "url", url, "err", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -66,10 +75,10 @@ func (b *HttpBoard) pushDeal(peer string, bundle *pedersen_dkg.DealBundle) {
 	if resp.StatusCode != http.StatusOK {
 		buf.Reset()
 		if _, err := io.Copy(buf, resp.Body); err != nil {
-			log.Printf("failed to read response body: %s\n", err)
+			b.log.Error("Failed to read response body", "url", url, "err", err)
 			return
 		}
-		log.Printf("received non-OK response: %s | %d\n", buf.String(), resp.StatusCode)
+		b.log.Error("Received non-OK response", "url", url, "status", resp.StatusCode, "body", buf.String())
 		return
 	}
 }
@@ -79,7 +88,7 @@ func (b *HttpBoard) IncomingDeal() <-chan pedersen_dkg.DealBundle {
 }
 
 func (b *HttpBoard) PushResponses(resp *pedersen_dkg.ResponseBundle) {
-	log.Printf("Pushing response to peers\n")
+	b.log.Info("Pushing response to peers", "session", hex.EncodeToString(resp.SessionID))
 
 	for index, peer := range b.peers {
 		if index == int(b.index) {
@@ -96,7 +105,7 @@ func (b *HttpBoard) pushResponse(peer string, bundle *pedersen_dkg.ResponseBundl
 	// Convert response bundle to JSON
 	respBytes, err := ResponseBundleToJSON(bundle)
 	if err != nil {
-		log.Printf("failed to encode response bundle: %s\n", err)
+		b.log.Error("Failed to encode response bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
@@ -104,7 +113,7 @@ func (b *HttpBoard) pushResponse(peer string, bundle *pedersen_dkg.ResponseBundl
 
 	resp, err := http.Post(url, "application/json", buf)
 	if err != nil {
-		log.Printf("failed to send HTTP request: %s\n", err)
+		b.log.Error("Failed to send HTTP request", "url", url, "err", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -112,10 +121,10 @@ func (b *HttpBoard) pushResponse(peer string, bundle *pedersen_dkg.ResponseBundl
 	if resp.StatusCode != http.StatusOK {
 		buf.Reset()
 		if _, err := io.Copy(buf, resp.Body); err != nil {
-			log.Printf("failed to read response body: %s\n", err)
+			b.log.Error("Failed to read response body", "url", url, "err", err)
 			return
 		}
-		log.Printf("received non-OK response: %s | %d\n", buf.String(), resp.StatusCode)
+		b.log.Error("Received non-OK response", "url", url, "status", resp.StatusCode, "body", buf.String())
 		return
 	}
 }
@@ -125,7 +134,7 @@ func (b *HttpBoard) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
 }
 
 func (b *HttpBoard) PushJustifications(bundle *pedersen_dkg.JustificationBundle) {
-	log.Printf("Pushing justification to peers\n")
+	b.log.Info("Pushing justification to peers", "session", hex.EncodeToString(bundle.SessionID))
 
 	for index, peer := range b.peers {
 		if index == int(b.index) {
@@ -142,7 +151,7 @@ func (b *HttpBoard) pushJustification(peer string, bundle *pedersen_dkg.Justific
 	// Convert justification bundle to JSON
 	justBytes, err := JustificationBundleToJSON(bundle)
 	if err != nil {
-		log.Printf("failed to encode justification bundle: %s\n", err)
+		b.log.Error("Failed to encode justification bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
@@ -150,7 +159,7 @@ func (b *HttpBoard) pushJustification(peer string, bundle *pedersen_dkg.Justific
 
 	resp, err := http.Post(url, "application/json", buf)
 	if err != nil {
-		log.Printf("failed to send HTTP request: %s\n", err)
+		b.log.Error("Failed to send HTTP request", "url", url, "err", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -158,10 +167,10 @@ func (b *HttpBoard) pushJustification(peer string, bundle *pedersen_dkg.Justific
 	if resp.StatusCode != http.StatusOK {
 		buf.Reset()
 		if _, err := io.Copy(buf, resp.Body); err != nil {
-			log.Printf("failed to read response body: %s\n", err)
+			b.log.Error("Failed to read response body", "url", url, "err", err)
 			return
 		}
-		log.Printf("received non-OK response: %s | %d\n", buf.String(), resp.StatusCode)
+		b.log.Error("Received non-OK response", "url", url, "status", resp.StatusCode, "body", buf.String())
 		return
 	}
 }
@@ -0,0 +1,24 @@
+package dkg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// BlockInput returns the VRF input for the block following prevBlockHash.
+// Every field has a fixed size or a length prefix, so no two distinct
+// (hash, number, seed) triples encode to the same bytes.
+func BlockInput(prevBlockHash []byte, blockNumber uint64, seed []byte) ([]byte, error) {
+	if len(prevBlockHash) != sha256.Size {
+		return nil, fmt.Errorf("previous block hash must be %d bytes, got %d", sha256.Size, len(prevBlockHash))
+	}
+
+	h := sha256.New()
+	h.Write(prevBlockHash)
+	binary.Write(h, binary.BigEndian, blockNumber)
+	binary.Write(h, binary.BigEndian, uint32(len(seed)))
+	h.Write(seed)
+
+	return h.Sum(nil), nil
+}
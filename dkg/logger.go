@@ -1,9 +1,81 @@
 package dkg
 
-import "log"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
 
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var _ pedersen_dkg.Logger = (*Logger)(nil)
+
+// Logger forwards the logs of kyber's DKG to a slog.Logger.
+//
+// Kyber nests its key values, for example
+// ("dkg-log", ["dkg-step", ["phaser", "msg", "moving to response phase"]]).
+// They are flattened: the wrapping tags become the scope and the first value
+// the event, the rest makes up the message.
 type Logger struct {
+	log *slog.Logger
+}
+
+// NewLogger returns a Logger writing to l.
+func NewLogger(l *slog.Logger) *Logger {
+	return &Logger{log: l}
 }
 
-func (l *Logger) Info(keyvals ...interface{})  { log.Println(keyvals...) }
-func (l *Logger) Error(keyvals ...interface{}) { log.Printf("ERROR: %v\n", keyvals) }
+func (l *Logger) Info(keyvals ...interface{})  { l.write(slog.LevelInfo, keyvals) }
+func (l *Logger) Error(keyvals ...interface{}) { l.write(slog.LevelError, keyvals) }
+
+func (l *Logger) write(level slog.Level, keyvals []interface{}) {
+	values := flatten(nil, keyvals)
+
+	scope := ""
+	for len(values) > 0 {
+		tag, ok := values[0].(string)
+		if !ok || (tag != "dkg-log" && tag != "dkg-step" && tag != "generator") {
+			break
+		}
+		if tag != "dkg-log" {
+			scope = strings.TrimPrefix(tag, "dkg-")
+		}
+		values = values[1:]
+	}
+
+	event := ""
+	if len(values) > 1 {
+		event = fmt.Sprint(values[0])
+		values = values[1:]
+	}
+	if len(values) > 1 && values[0] == "msg" {
+		values = values[1:]
+	}
+
+	words := make([]string, len(values))
+	for i, v := range values {
+		words[i] = strings.TrimSpace(fmt.Sprint(v))
+	}
+
+	attrs := []any{}
+	if scope != "" {
+		attrs = append(attrs, "scope", scope)
+	}
+	if event != "" {
+		attrs = append(attrs, "event", event)
+	}
+
+	l.log.Log(context.Background(), level, strings.Join(words, " "), attrs...)
+}
+
+func flatten(dst []interface{}, keyvals []interface{}) []interface{} {
+	for _, v := range keyvals {
+		if nested, ok := v.([]interface{}); ok {
+			dst = flatten(dst, nested)
+			continue
+		}
+		dst = append(dst, v)
+	}
+	return dst
+}
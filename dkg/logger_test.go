@@ -0,0 +1,36 @@
+package dkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(slog.New(slog.NewJSONHandler(&buf, nil)).With("session", "ab"))
+
+	// as kyber's Protocol and Config nest them
+	l.Info("dkg-log", []interface{}{"dkg-step", []interface{}{"phaser", "msg", "moving to response phase"}})
+	l.Error("dkg-log", []interface{}{"dkg-step", []interface{}{"newDeal", "invalid deal signature:", errors.New("bad")}})
+	l.Error("dkg-log", []interface{}{"Deal bundle already seen"})
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]any
+		require.NoError(t, dec.Decode(&r))
+		delete(r, "time")
+		records = append(records, r)
+	}
+
+	require.Equal(t, []map[string]any{
+		{"level": "INFO", "msg": "moving to response phase", "session": "ab", "scope": "step", "event": "phaser"},
+		{"level": "ERROR", "msg": "invalid deal signature: bad", "session": "ab", "scope": "step", "event": "newDeal"},
+		{"level": "ERROR", "msg": "Deal bundle already seen", "session": "ab"},
+	}, records)
+}
@@ -2,6 +2,9 @@ package dkg
 
 import (
 	"encoding/hex"
+	"fmt"
+
+	"random-network-poc/crypto"
 
 	"go.dedis.ch/kyber/v4"
 	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
@@ -9,6 +12,8 @@ import (
 
 const Threshold = 3
 
+// Nodes is the built-in committee, its keys live on the G2 group of the
+// default BN256 scheme.
 var Nodes = []pedersen_dkg.Node{
 	{
 		Index:  0,
@@ -24,13 +29,34 @@ var Nodes = []pedersen_dkg.Node{
 	},
 }
 
+// ParseNodes builds a committee from hex encoded public keys of the given
+// scheme, indexed in the order they are listed.
+func ParseNodes(s *crypto.Scheme, keys []string) ([]pedersen_dkg.Node, error) {
+	nodes := make([]pedersen_dkg.Node, len(keys))
+	for i, key := range keys {
+		pubBytes, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode public key %d: %w", i, err)
+		}
+		point, err := s.PointFromBytes(pubBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal public key %d: %w", i, err)
+		}
+		nodes[i] = pedersen_dkg.Node{
+			Index:  uint32(i),
+			Public: point,
+		}
+	}
+	return nodes, nil
+}
+
 func mustPubKeyFromHex(hexStr string) kyber.Point {
 	pubBytes, err := hex.DecodeString(hexStr)
 	if err != nil {
 		panic(err)
 	}
-	point := Suite.Point()
-	if err := point.UnmarshalBinary(pubBytes); err != nil {
+	point, err := crypto.DefaultScheme().PointFromBytes(pubBytes)
+	if err != nil {
 		panic(err)
 	}
 	return point
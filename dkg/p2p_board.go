@@ -2,9 +2,16 @@ package dkg
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+
+	"random-network-poc/crypto"
+	"random-network-poc/envelope"
+	"random-network-poc/metrics"
+	"random-network-poc/rng"
+	"random-network-poc/wire"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -18,9 +25,16 @@ const (
 var _ pedersen_dkg.Board = (*BoardP2P)(nil)
 
 type BoardP2P struct {
-	self peer.ID
+	self     peer.ID
+	scheme   *crypto.Scheme
+	wire     *wire.Negotiator
+	envelope *envelope.Codec
+	log      *slog.Logger
+	metrics  *metrics.Metrics
 
 	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
 	pubsub *pubsub.PubSub
 	topic  *pubsub.Topic
 	sub    *pubsub.Subscription
@@ -28,53 +42,143 @@ type BoardP2P struct {
 	deals chan pedersen_dkg.DealBundle
 	resps chan pedersen_dkg.ResponseBundle
 	justs chan pedersen_dkg.JustificationBundle
+
+	// dealers and holders map the indices of the dealers and share holders
+	// of a handover to their index in the envelope committee, nil for the
+	// same index.
+	dealers map[uint32]uint32
+	holders map[uint32]uint32
+}
+
+// NewBoardP2P joins the DKG topic. The negotiator picks the wire format of
+// published bundles, a nil negotiator publishes JSON. Every bundle is sealed
+// in an envelope signed by the node and incoming envelopes are verified
+// against the committee. A nil logger logs to slog.Default, nil metrics
+// record nothing. The board stops once ctx is done or Close is called.
+func NewBoardP2P(ctx context.Context, ps *pubsub.PubSub, self peer.ID, scheme *crypto.Scheme, negotiator *wire.Negotiator, codec *envelope.Codec, logger *slog.Logger, m *metrics.Metrics) (*BoardP2P, error) {
+	return NewGroupBoardP2P(ctx, ps, self, "", scheme, negotiator, codec, logger, m)
+}
+
+// NewGroupBoardP2P is NewBoardP2P on the DKG topic of group, see
+// rng.GroupTopic.
+func NewGroupBoardP2P(ctx context.Context, ps *pubsub.PubSub, self peer.ID, group string, scheme *crypto.Scheme, negotiator *wire.Negotiator, codec *envelope.Codec, logger *slog.Logger, m *metrics.Metrics) (*BoardP2P, error) {
+	return newBoardP2P(ctx, ps, self, group, scheme, negotiator, codec, nil, nil, logger, m)
+}
+
+// NewHandoverBoardP2P is NewGroupBoardP2P for the handover from the committee
+// oldNodes to newNodes, see Handover. The envelopes of codec are signed by the
+// members of both, and each bundle has to come from the member it names.
+func NewHandoverBoardP2P(ctx context.Context, ps *pubsub.PubSub, self peer.ID, group string, scheme *crypto.Scheme, negotiator *wire.Negotiator, codec *envelope.Codec, oldNodes, newNodes []pedersen_dkg.Node, logger *slog.Logger, m *metrics.Metrics) (*BoardP2P, error) {
+	indices := func(nodes []pedersen_dkg.Node) (map[uint32]uint32, error) {
+		senders := make(map[uint32]uint32, len(nodes))
+		for _, node := range nodes {
+			sender, ok := codec.IndexOf(node.Public)
+			if !ok {
+				return nil, fmt.Errorf("%w: node %d of the handover", envelope.ErrUnknownSender, node.Index)
+			}
+			senders[node.Index] = sender
+		}
+		return senders, nil
+	}
+	dealers, err := indices(oldNodes)
+	if err != nil {
+		return nil, err
+	}
+	holders, err := indices(newNodes)
+	if err != nil {
+		return nil, err
+	}
+	return newBoardP2P(ctx, ps, self, group, scheme, negotiator, codec, dealers, holders, logger, m)
 }
 
-func NewBoardP2P(ctx context.Context, ps *pubsub.PubSub, self peer.ID) (*BoardP2P, error) {
-	topic, err := ps.Join(Topic)
+func newBoardP2P(ctx context.Context, ps *pubsub.PubSub, self peer.ID, group string, scheme *crypto.Scheme, negotiator *wire.Negotiator, codec *envelope.Codec, dealers, holders map[uint32]uint32, logger *slog.Logger, m *metrics.Metrics) (*BoardP2P, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if group != "" {
+		logger = logger.With("group", group)
+	}
+
+	name := rng.GroupTopic(Topic, group)
+	topic, err := ps.Join(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join topic %s: %w", Topic, err)
+		return nil, fmt.Errorf("failed to join topic %s: %w", name, err)
 	}
 
 	sub, err := topic.Subscribe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", Topic, err)
+		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", name, err)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
+	// the protocol pushes its own bundles from the goroutine reading them,
+	// so the bundles of the whole committee have to fit
+	size := codec.Size()
+
 	b := &BoardP2P{
-		self:   self,
-		ctx:    ctx,
-		pubsub: ps,
-		topic:  topic,
-		sub:    sub,
-		deals:  make(chan pedersen_dkg.DealBundle, 3),
-		resps:  make(chan pedersen_dkg.ResponseBundle, 3),
-		justs:  make(chan pedersen_dkg.JustificationBundle, 3),
+		self:     self,
+		scheme:   scheme,
+		wire:     negotiator,
+		envelope: codec,
+		log:      logger.With("node", codec.Index(), "peer", self),
+		metrics:  m,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		pubsub:   ps,
+		topic:    topic,
+		sub:      sub,
+		deals:    make(chan pedersen_dkg.DealBundle, size),
+		resps:    make(chan pedersen_dkg.ResponseBundle, size),
+		justs:    make(chan pedersen_dkg.JustificationBundle, size),
+		dealers:  dealers,
+		holders:  holders,
 	}
 
+	m.WatchTopics(ps, name)
+
 	go b.readLoop()
 
 	return b, nil
 }
 
-func (b *BoardP2P) PushDeals(bundle *pedersen_dkg.DealBundle) {
-	msg, err := NewDealBundleMessage(bundle)
-	if err != nil {
-		log.Printf("Error marshalling deal bundle: %s\n", err)
-		return
+// Close stops the board: it waits for the read loop to return and leaves the
+// DKG topic. Leaving is skipped once the pubsub itself is stopped.
+func (b *BoardP2P) Close() error {
+	b.cancel()
+	<-b.done
+
+	b.sub.Cancel()
+	if err := b.topic.Close(); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to leave topic %s: %w", b.topic, err)
 	}
+	return nil
+}
 
-	data, err := json.Marshal(msg)
+// push hands a bundle to the DKG protocol, giving up once the board is
+// closed: no protocol may be left to read it.
+func push[T any](ctx context.Context, ch chan<- T, bundle T) {
+	select {
+	case ch <- bundle:
+	case <-ctx.Done():
+	}
+}
+
+func (b *BoardP2P) PushDeals(bundle *pedersen_dkg.DealBundle) {
+	data, err := b.encode(bundle)
 	if err != nil {
-		log.Printf("Error marshalling message: %s\n", err)
+		b.log.Error("Failed to encode deal bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
 	if err := b.topic.Publish(b.ctx, data); err != nil {
-		log.Printf("Error publishing deal bundle: %s\n", err)
+		b.log.Error("Failed to publish deal bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
+	} else {
+		b.metrics.Bundle(metrics.Sent, "deal")
 	}
 
-	b.deals <- *bundle
+	push(b.ctx, b.deals, *bundle)
 }
 
 func (b *BoardP2P) IncomingDeal() <-chan pedersen_dkg.DealBundle {
@@ -82,23 +186,19 @@ func (b *BoardP2P) IncomingDeal() <-chan pedersen_dkg.DealBundle {
 }
 
 func (b *BoardP2P) PushResponses(bundle *pedersen_dkg.ResponseBundle) {
-	msg, err := NewResponseBundleMessage(bundle)
+	data, err := b.encode(bundle)
 	if err != nil {
-		log.Printf("Error marshalling response bundle: %s\n", err)
-		return
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Error marshalling message: %s\n", err)
+		b.log.Error("Failed to encode response bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
 	if err := b.topic.Publish(b.ctx, data); err != nil {
-		log.Printf("Error publishing response bundle: %s\n", err)
+		b.log.Error("Failed to publish response bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
+	} else {
+		b.metrics.Bundle(metrics.Sent, "response")
 	}
 
-	b.resps <- *bundle
+	push(b.ctx, b.resps, *bundle)
 }
 
 func (b *BoardP2P) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
@@ -106,34 +206,43 @@ func (b *BoardP2P) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
 }
 
 func (b *BoardP2P) PushJustifications(bundle *pedersen_dkg.JustificationBundle) {
-	msg, err := NewJustificationBundleMessage(bundle)
-	if err != nil {
-		log.Printf("Error marshalling justification bundle: %s\n", err)
-		return
-	}
-
-	data, err := json.Marshal(msg)
+	data, err := b.encode(bundle)
 	if err != nil {
-		log.Printf("Error marshalling message: %s\n", err)
+		b.log.Error("Failed to encode justification bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
 		return
 	}
 
 	if err := b.topic.Publish(b.ctx, data); err != nil {
-		log.Printf("Error publishing justification bundle: %s\n", err)
+		b.log.Error("Failed to publish justification bundle", "session", hex.EncodeToString(bundle.SessionID), "err", err)
+	} else {
+		b.metrics.Bundle(metrics.Sent, "justification")
 	}
 
-	b.justs <- *bundle
+	push(b.ctx, b.justs, *bundle)
 }
 
 func (b *BoardP2P) IncomingJustification() <-chan pedersen_dkg.JustificationBundle {
 	return b.justs
 }
 
+// encode encodes a bundle in the negotiated format and seals it.
+func (b *BoardP2P) encode(bundle any) ([]byte, error) {
+	data, err := EncodeBoardMessage(b.wire.Format(b.topic.ListPeers()), bundle)
+	if err != nil {
+		return nil, err
+	}
+	return b.envelope.Seal(b.topic.String(), data)
+}
+
 func (b *BoardP2P) readLoop() {
+	defer close(b.done)
+
 	for {
 		msg, err := b.sub.Next(b.ctx)
 		if err != nil {
-			log.Printf("Error reading message: %s\n", err)
+			if b.ctx.Err() == nil {
+				b.log.Error("Failed to read message", "topic", b.topic.String(), "err", err)
+			}
 			return
 		}
 
@@ -142,40 +251,55 @@ func (b *BoardP2P) readLoop() {
 			continue
 		}
 
-		m := new(Message)
-
-		if err = json.Unmarshal(msg.Data, m); err != nil {
-			log.Printf("Error unmarshalling message: %s\n", err)
+		e, err := b.envelope.Open(b.topic.String(), msg.Data)
+		if err != nil {
+			b.log.Warn("Failed to open envelope", "topic", b.topic.String(), "from", msg.ReceivedFrom, "err", err)
 			continue
 		}
 
-		switch m.Type {
-		case MessageDealBundle:
-			bundle, err := DealBundleFromJSON(m.Data)
-			if err != nil {
-				log.Printf("Error unmarshalling deal bundle: %s\n", err)
-				continue
-			}
-
-			b.deals <- *bundle
-		case MessageResponseBundle:
-			bundle, err := ResponseBundleFromJSON(m.Data)
-			if err != nil {
-				log.Printf("Error unmarshalling response bundle: %s\n", err)
-				continue
-			}
+		bundle, err := DecodeBoardMessage(b.scheme, e.Payload)
+		if err != nil {
+			b.log.Warn("Failed to decode bundle", "sender", e.SenderIndex, "err", err)
+			continue
+		}
 
-			b.resps <- *bundle
-		case MessageJustificationBundle:
-			bundle, err := JustificationBundleFromJSON(m.Data)
-			if err != nil {
-				log.Printf("Error unmarshalling justification bundle: %s\n", err)
-				continue
-			}
+		// a member only publishes its own bundles, not those of others
+		if sender, ok := b.sender(bundle); !ok || sender != e.SenderIndex {
+			b.log.Warn("Dropped bundle of another member", "sender", e.SenderIndex)
+			continue
+		}
 
-			b.justs <- *bundle
-		default:
-			log.Printf("Unknown message type: %d\n", m.Type)
+		switch bundle := bundle.(type) {
+		case *pedersen_dkg.DealBundle:
+			b.metrics.Bundle(metrics.Received, "deal")
+			push(b.ctx, b.deals, *bundle)
+		case *pedersen_dkg.ResponseBundle:
+			b.metrics.Bundle(metrics.Received, "response")
+			push(b.ctx, b.resps, *bundle)
+		case *pedersen_dkg.JustificationBundle:
+			b.metrics.Bundle(metrics.Received, "justification")
+			push(b.ctx, b.justs, *bundle)
 		}
 	}
 }
+
+// sender returns the index in the envelope committee of the member a bundle
+// comes from: the dealer of deals and justifications, the share holder of
+// responses.
+func (b *BoardP2P) sender(bundle any) (uint32, bool) {
+	var index uint32
+	senders := b.dealers
+	switch bundle := bundle.(type) {
+	case *pedersen_dkg.DealBundle:
+		index = bundle.DealerIndex
+	case *pedersen_dkg.ResponseBundle:
+		index, senders = bundle.ShareIndex, b.holders
+	case *pedersen_dkg.JustificationBundle:
+		index = bundle.DealerIndex
+	}
+	if senders == nil {
+		return index, true
+	}
+	sender, ok := senders[index]
+	return sender, ok
+}
@@ -0,0 +1,276 @@
+package dkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"random-network-poc/crypto"
+	"random-network-poc/pb"
+	"random-network-poc/wire"
+
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"google.golang.org/protobuf/proto"
+)
+
+// DealBundleToProto converts a pedersen_dkg.DealBundle to its protobuf form
+func DealBundleToProto(bundle *pedersen_dkg.DealBundle) (*pb.DealBundle, error) {
+	p := &pb.DealBundle{
+		DealerIndex: bundle.DealerIndex,
+		SessionId:   bundle.SessionID,
+		Signature:   bundle.Signature,
+	}
+
+	for _, deal := range bundle.Deals {
+		p.Deals = append(p.Deals, &pb.Deal{
+			ShareIndex:     deal.ShareIndex,
+			EncryptedShare: deal.EncryptedShare,
+		})
+	}
+
+	for _, pub := range bundle.Public {
+		pubBytes, err := pub.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal public point: %w", err)
+		}
+		p.Public = append(p.Public, pubBytes)
+	}
+
+	return p, nil
+}
+
+// DealBundleFromProto converts a protobuf deal bundle to a pedersen_dkg.DealBundle
+func DealBundleFromProto(s *crypto.Scheme, p *pb.DealBundle) (*pedersen_dkg.DealBundle, error) {
+	if err := checkEntries("deals", len(p.Deals)); err != nil {
+		return nil, err
+	}
+	if err := checkEntries("public points", len(p.Public)); err != nil {
+		return nil, err
+	}
+	if err := checkFields(p.SessionId, p.Signature); err != nil {
+		return nil, err
+	}
+
+	bundle := &pedersen_dkg.DealBundle{
+		DealerIndex: p.DealerIndex,
+		SessionID:   p.SessionId,
+		Signature:   p.Signature,
+	}
+
+	for _, deal := range p.Deals {
+		if err := checkField("encrypted share", deal.EncryptedShare); err != nil {
+			return nil, err
+		}
+		bundle.Deals = append(bundle.Deals, pedersen_dkg.Deal{
+			ShareIndex:     deal.ShareIndex,
+			EncryptedShare: deal.EncryptedShare,
+		})
+	}
+
+	for _, pubBytes := range p.Public {
+		point, err := s.PointFromBytes(pubBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal public point: %w", err)
+		}
+		bundle.Public = append(bundle.Public, point)
+	}
+
+	return bundle, nil
+}
+
+// ResponseBundleToProto converts a pedersen_dkg.ResponseBundle to its protobuf form
+func ResponseBundleToProto(bundle *pedersen_dkg.ResponseBundle) *pb.ResponseBundle {
+	p := &pb.ResponseBundle{
+		ShareIndex: bundle.ShareIndex,
+		SessionId:  bundle.SessionID,
+		Signature:  bundle.Signature,
+	}
+
+	for _, resp := range bundle.Responses {
+		p.Responses = append(p.Responses, &pb.Response{
+			DealerIndex: resp.DealerIndex,
+			Status:      int32(resp.Status),
+		})
+	}
+
+	return p
+}
+
+// ResponseBundleFromProto converts a protobuf response bundle to a pedersen_dkg.ResponseBundle
+func ResponseBundleFromProto(p *pb.ResponseBundle) (*pedersen_dkg.ResponseBundle, error) {
+	if err := checkEntries("responses", len(p.Responses)); err != nil {
+		return nil, err
+	}
+	if err := checkFields(p.SessionId, p.Signature); err != nil {
+		return nil, err
+	}
+
+	bundle := &pedersen_dkg.ResponseBundle{
+		ShareIndex: p.ShareIndex,
+		SessionID:  p.SessionId,
+		Signature:  p.Signature,
+	}
+
+	for _, resp := range p.Responses {
+		status, err := decodeStatus(resp.Status)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Responses = append(bundle.Responses, pedersen_dkg.Response{
+			DealerIndex: resp.DealerIndex,
+			Status:      status,
+		})
+	}
+
+	return bundle, nil
+}
+
+// JustificationBundleToProto converts a pedersen_dkg.JustificationBundle to its protobuf form
+func JustificationBundleToProto(bundle *pedersen_dkg.JustificationBundle) (*pb.JustificationBundle, error) {
+	p := &pb.JustificationBundle{
+		DealerIndex: bundle.DealerIndex,
+		SessionId:   bundle.SessionID,
+		Signature:   bundle.Signature,
+	}
+
+	for _, justification := range bundle.Justifications {
+		share, err := justification.Share.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal share: %w", err)
+		}
+		p.Justifications = append(p.Justifications, &pb.Justification{
+			ShareIndex: justification.ShareIndex,
+			Share:      share,
+		})
+	}
+
+	return p, nil
+}
+
+// JustificationBundleFromProto converts a protobuf justification bundle to a pedersen_dkg.JustificationBundle
+func JustificationBundleFromProto(s *crypto.Scheme, p *pb.JustificationBundle) (*pedersen_dkg.JustificationBundle, error) {
+	if err := checkEntries("justifications", len(p.Justifications)); err != nil {
+		return nil, err
+	}
+	if err := checkFields(p.SessionId, p.Signature); err != nil {
+		return nil, err
+	}
+
+	bundle := &pedersen_dkg.JustificationBundle{
+		DealerIndex:    p.DealerIndex,
+		Justifications: make([]pedersen_dkg.Justification, len(p.Justifications)),
+		SessionID:      p.SessionId,
+		Signature:      p.Signature,
+	}
+
+	for i, justification := range p.Justifications {
+		scalar, err := s.ScalarFromBytes(justification.Share)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+		}
+		bundle.Justifications[i] = pedersen_dkg.Justification{
+			ShareIndex: justification.ShareIndex,
+			Share:      scalar,
+		}
+	}
+
+	return bundle, nil
+}
+
+// checkFields checks the session ID and signature of a protobuf bundle.
+func checkFields(sessionID, signature []byte) error {
+	if err := checkField("session ID", sessionID); err != nil {
+		return err
+	}
+	return checkField("signature", signature)
+}
+
+// EncodeBoardMessage encodes a deal, response or justification bundle for
+// the board topic in the given format.
+func EncodeBoardMessage(f wire.Format, bundle any) ([]byte, error) {
+	if f == wire.FormatJSON {
+		var msg *Message
+		var err error
+		switch b := bundle.(type) {
+		case *pedersen_dkg.DealBundle:
+			msg, err = NewDealBundleMessage(b)
+		case *pedersen_dkg.ResponseBundle:
+			msg, err = NewResponseBundleMessage(b)
+		case *pedersen_dkg.JustificationBundle:
+			msg, err = NewJustificationBundleMessage(b)
+		default:
+			return nil, fmt.Errorf("unknown bundle type %T", bundle)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(msg)
+	}
+
+	msg := &pb.BoardMessage{Version: wire.Version}
+	switch b := bundle.(type) {
+	case *pedersen_dkg.DealBundle:
+		deal, err := DealBundleToProto(b)
+		if err != nil {
+			return nil, err
+		}
+		msg.Bundle = &pb.BoardMessage_Deal{Deal: deal}
+	case *pedersen_dkg.ResponseBundle:
+		msg.Bundle = &pb.BoardMessage_Response{Response: ResponseBundleToProto(b)}
+	case *pedersen_dkg.JustificationBundle:
+		just, err := JustificationBundleToProto(b)
+		if err != nil {
+			return nil, err
+		}
+		msg.Bundle = &pb.BoardMessage_Justification{Justification: just}
+	default:
+		return nil, fmt.Errorf("unknown bundle type %T", bundle)
+	}
+	return proto.Marshal(msg)
+}
+
+// DecodeBoardMessage decodes a board message in either format into a
+// *pedersen_dkg.DealBundle, *pedersen_dkg.ResponseBundle or
+// *pedersen_dkg.JustificationBundle.
+func DecodeBoardMessage(s *crypto.Scheme, data []byte) (any, error) {
+	if len(data) > MaxMessageSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, len(data))
+	}
+
+	if wire.Detect(data) == wire.FormatJSON {
+		m, err := ParseMessage(data)
+		if err != nil {
+			return nil, err
+		}
+
+		switch m.Type {
+		case MessageDealBundle:
+			return DealBundleFromJSON(s, m.Data)
+		case MessageResponseBundle:
+			return ResponseBundleFromJSON(m.Data)
+		case MessageJustificationBundle:
+			return JustificationBundleFromJSON(s, m.Data)
+		default:
+			return nil, fmt.Errorf("unknown message type: %d", m.Type)
+		}
+	}
+
+	msg := new(pb.BoardMessage)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	if msg.Version != wire.Version {
+		return nil, fmt.Errorf("unsupported wire version %d", msg.Version)
+	}
+
+	switch b := msg.Bundle.(type) {
+	case *pb.BoardMessage_Deal:
+		return DealBundleFromProto(s, b.Deal)
+	case *pb.BoardMessage_Response:
+		return ResponseBundleFromProto(b.Response)
+	case *pb.BoardMessage_Justification:
+		return JustificationBundleFromProto(s, b.Justification)
+	default:
+		return nil, errors.New("empty board message")
+	}
+}
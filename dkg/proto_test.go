@@ -0,0 +1,145 @@
+package dkg
+
+import (
+	"testing"
+
+	"random-network-poc/crypto"
+	"random-network-poc/wire"
+
+	"github.com/stretchr/testify/require"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func testBundles(s *crypto.Scheme, n int) (*pedersen_dkg.DealBundle, *pedersen_dkg.ResponseBundle, *pedersen_dkg.JustificationBundle) {
+	session := random.Bits(256, true, random.New())
+	sig := random.Bits(512, true, random.New())
+
+	deal := &pedersen_dkg.DealBundle{DealerIndex: 1, SessionID: session, Signature: sig}
+	resp := &pedersen_dkg.ResponseBundle{ShareIndex: 2, SessionID: session, Signature: sig}
+	just := &pedersen_dkg.JustificationBundle{DealerIndex: 1, SessionID: session, Signature: sig}
+
+	for i := 0; i < n; i++ {
+		deal.Public = append(deal.Public, s.KeyGroup.Point().Pick(random.New()))
+		deal.Deals = append(deal.Deals, pedersen_dkg.Deal{
+			ShareIndex:     uint32(i),
+			EncryptedShare: random.Bits(8*96, true, random.New()),
+		})
+		resp.Responses = append(resp.Responses, pedersen_dkg.Response{
+			DealerIndex: uint32(i),
+			Status:      pedersen_dkg.Status(i % 2),
+		})
+		just.Justifications = append(just.Justifications, pedersen_dkg.Justification{
+			ShareIndex: uint32(i),
+			Share:      s.KeyGroup.Scalar().Pick(random.New()),
+		})
+	}
+
+	return deal, resp, just
+}
+
+// requireSameHash compares bundles through the hash their signature covers,
+// which includes every point and scalar.
+func requireSameHash(t *testing.T, want, got pedersen_dkg.Packet) {
+	wantHash, err := want.Hash()
+	require.NoError(t, err)
+	gotHash, err := got.Hash()
+	require.NoError(t, err)
+	require.Equal(t, wantHash, gotHash)
+	require.Equal(t, want.Sig(), got.Sig())
+}
+
+func TestBoardMessageRoundTrip(t *testing.T) {
+	for _, name := range []string{"bn256-g1", "bls12381-g1", "bls12381-g2"} {
+		scheme, err := crypto.ParseScheme(name)
+		require.NoError(t, err)
+		deal, resp, just := testBundles(scheme, 16)
+
+		for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+			t.Run(name+"/"+format.String(), func(t *testing.T) {
+				data, err := EncodeBoardMessage(format, deal)
+				require.NoError(t, err)
+				require.Equal(t, format, wire.Detect(data))
+				got, err := DecodeBoardMessage(scheme, data)
+				require.NoError(t, err)
+				requireSameHash(t, deal, got.(*pedersen_dkg.DealBundle))
+
+				data, err = EncodeBoardMessage(format, resp)
+				require.NoError(t, err)
+				got, err = DecodeBoardMessage(scheme, data)
+				require.NoError(t, err)
+				require.Equal(t, resp, got)
+
+				data, err = EncodeBoardMessage(format, just)
+				require.NoError(t, err)
+				got, err = DecodeBoardMessage(scheme, data)
+				require.NoError(t, err)
+				requireSameHash(t, just, got.(*pedersen_dkg.JustificationBundle))
+			})
+		}
+	}
+}
+
+func TestBoardMessageSize(t *testing.T) {
+	deal, resp, just := testBundles(crypto.DefaultScheme(), 16)
+
+	for _, bundle := range []any{deal, resp, just} {
+		jsonData, err := EncodeBoardMessage(wire.FormatJSON, bundle)
+		require.NoError(t, err)
+		protoData, err := EncodeBoardMessage(wire.FormatProtobuf, bundle)
+		require.NoError(t, err)
+
+		t.Logf("%T: json %d bytes, protobuf %d bytes", bundle, len(jsonData), len(protoData))
+		// JSON carries hex inside base64, more than twice the raw bytes
+		require.Less(t, 2*len(protoData), len(jsonData))
+	}
+}
+
+func TestDecodeBoardMessageErrors(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+
+	for _, data := range [][]byte{
+		{0x08, 0x02},             // unsupported version
+		{0x08, 0x01},             // no bundle
+		{0xff, 0xff, 0xff, 0xff}, // not protobuf
+		[]byte(`{"type":9}`),     // unknown JSON type
+	} {
+		_, err := DecodeBoardMessage(scheme, data)
+		require.Error(t, err, "%x", data)
+	}
+}
+
+func TestDecodeBoardMessageLimits(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+
+	encode := func(bundle any) []byte {
+		data, err := EncodeBoardMessage(wire.FormatProtobuf, bundle)
+		require.NoError(t, err)
+		return data
+	}
+
+	// a public point with a trailing byte
+	deal, resp, just := testBundles(scheme, 2)
+	p, err := DealBundleToProto(deal)
+	require.NoError(t, err)
+	p.Public[0] = append(p.Public[0], 0)
+	_, err = DealBundleFromProto(scheme, p)
+	require.ErrorIs(t, err, crypto.ErrNonCanonical)
+
+	resp.Responses[0].Status = 2
+	_, err = DecodeBoardMessage(scheme, encode(resp))
+	require.ErrorContains(t, err, "unknown response status 2")
+
+	_, _, large := testBundles(scheme, MaxCommittee+1)
+	_, err = DecodeBoardMessage(scheme, encode(large))
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	just.Signature = make([]byte, MaxFieldSize+1)
+	_, err = DecodeBoardMessage(scheme, encode(just))
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = DecodeBoardMessage(scheme, make([]byte, MaxMessageSize+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+	_, err = ParseMessage(make([]byte, MaxMessageSize+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+}
@@ -0,0 +1,250 @@
+package dkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/metrics"
+	"random-network-poc/signer"
+
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var (
+	ErrRefreshChangedKey = errors.New("refresh produced a different group public key")
+	ErrRefreshPending    = errors.New("share of a previous refresh not switched yet")
+	ErrNoPendingRefresh  = errors.New("no pending refresh for this epoch")
+)
+
+// RefreshNonce derives the session nonce of a refresh epoch from the nonce of
+// the initial DKG, so every validator of the committee agrees on it without
+// exchanging messages.
+func RefreshNonce(nonce []byte, epoch uint64) []byte {
+	h := sha256.New()
+	h.Write([]byte("refresh"))
+	h.Write(nonce)
+	binary.Write(h, binary.BigEndian, epoch)
+	return h.Sum(nil)
+}
+
+// RefreshConfig returns the DKG configuration resharing key to the same
+// committee and threshold. The dealt polynomials all have the current shares
+// as constant terms, so the group secret and public key are unchanged while
+// every share is replaced.
+func RefreshConfig(s *crypto.Scheme, longterm kyber.Scalar, nodes []pedersen_dkg.Node, threshold int, key *pedersen_dkg.DistKeyShare, nonce []byte) *pedersen_dkg.Config {
+	return &pedersen_dkg.Config{
+		Suite:        s.KeyGroup,
+		Longterm:     longterm,
+		OldNodes:     nodes,
+		NewNodes:     nodes,
+		Share:        key,
+		Threshold:    threshold,
+		OldThreshold: threshold,
+		Nonce:        nonce,
+		Auth:         s.AuthScheme,
+	}
+}
+
+// RefreshState is the state of the last refresh of a node.
+type RefreshState string
+
+const (
+	// RefreshPending is set once the resharing completed, until the node
+	// switches to the new share.
+	RefreshPending RefreshState = "pending"
+	RefreshDone    RefreshState = "done"
+	// RefreshFailed is set once the resharing failed on this node. Its
+	// share is then likely stale: the rest of the committee may have moved
+	// on to new shares.
+	RefreshFailed RefreshState = "failed"
+)
+
+// RefreshStatus describes the last refresh of a node.
+type RefreshStatus struct {
+	Epoch uint64       `json:"epoch"`
+	State RefreshState `json:"state"`
+	Error string       `json:"error,omitempty"`
+	// SwitchAt is when the node switches to the new share while the
+	// refresh is pending.
+	SwitchAt *time.Time `json:"switch_at,omitempty"`
+}
+
+// StartRefresh refreshes the share of the node every RefreshInterval until ctx
+// is done or the node is closed. Refreshes are aligned to multiples of the
+// interval since the Unix epoch, so validators with synchronised clocks start
+// the same epoch together, and switch to the new shares together at
+// RefreshSwitch, long after the resharing ended on every validator. Until
+// then the node keeps signing with its current share, so a round never mixes
+// partials of both.
+func (n *Node) StartRefresh(ctx context.Context) {
+	if n.refreshInterval <= 0 {
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		for {
+			now := n.clock.Now()
+			next := now.Truncate(n.refreshInterval).Add(n.refreshInterval)
+			if !n.sleep(ctx, next.Sub(now)) {
+				return
+			}
+
+			epoch := uint64(next.UnixNano() / n.refreshInterval.Nanoseconds())
+			if err := n.Refresh(epoch); err != nil {
+				if n.ctx.Err() != nil {
+					return
+				}
+				n.log.Error("Failed to refresh share", "epoch", epoch, "err", err)
+				continue
+			}
+
+			at := RefreshSwitch(epoch, n.refreshInterval)
+			n.log.Info("Refreshed share", "epoch", epoch, "switch_at", at)
+			if !n.sleep(ctx, at.Sub(n.clock.Now())) {
+				return
+			}
+			if err := n.SwitchShare(epoch); err != nil {
+				n.log.Error("Failed to switch share", "epoch", epoch, "err", err)
+				continue
+			}
+			n.log.Info("Switched to refreshed share", "epoch", epoch)
+		}
+	}()
+}
+
+// sleep waits for d on the clock of the node, and returns false once ctx is
+// done or the node is closed first.
+func (n *Node) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-n.ctx.Done():
+		return false
+	case <-n.clock.After(d):
+		return true
+	}
+}
+
+// RefreshSwitch returns when the validators switch to the shares of the
+// refresh of epoch started every interval: half an interval after it
+// started.
+func RefreshSwitch(epoch uint64, interval time.Duration) time.Time {
+	return time.Unix(0, int64(epoch)*interval.Nanoseconds()).Add(interval / 2)
+}
+
+// Refresh runs one resharing round with the committee. Once the round
+// completes with the same group public key, the new share is held pending
+// while the node keeps signing with the current one, until SwitchShare. A
+// node the refresh evicts stops signing, see Qualified, and any other failure
+// is reported by Status.
+func (n *Node) Refresh(epoch uint64) error {
+	n.refreshMu.Lock()
+	defer n.refreshMu.Unlock()
+
+	if err := n.canSign(); err != nil {
+		return err
+	}
+	if n.forgetShare {
+		return ErrShareForgotten
+	}
+	n.mu.Lock()
+	pending := n.pending
+	n.mu.Unlock()
+	if pending != nil {
+		return ErrRefreshPending
+	}
+	old := n.key()
+
+	nonce := RefreshNonce(n.nonce, epoch)
+	conf := RefreshConfig(n.scheme, n.privateKey, n.nodes, n.threshold, old, nonce)
+	conf.Auth = signer.AuthScheme(n.signer, conf.Auth)
+	conf.Log = NewLogger(n.log.With("session", hex.EncodeToString(nonce), "epoch", epoch))
+	done := make(chan struct{})
+	defer close(done)
+	phaser := n.newPhaser(metrics.KindRefresh, done)
+	defer phaser.release()
+
+	protocol, err := pedersen_dkg.NewProtocol(conf, n.board, phaser, false)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh protocol: %w", err)
+	}
+
+	start := n.clock.Now()
+	phaser.Start()
+
+	result := <-protocol.WaitEnd()
+	if n.ctx.Err() != nil {
+		return ErrNodeClosed
+	}
+	err = result.Error
+	if err == nil && !result.Result.Key.Public().Equal(old.Public()) {
+		err = ErrRefreshChangedKey
+	}
+	n.metrics.DKGRun(metrics.KindRefresh, err, n.clock.Now().Sub(start))
+	if err != nil {
+		err = fmt.Errorf("refresh failed: %w", err)
+		n.mu.Lock()
+		// the rest of the committee moved on to new shares without this node
+		if errors.Is(err, pedersen_dkg.ErrEvicted) {
+			n.evicted = true
+		}
+		n.refresh = &RefreshStatus{Epoch: epoch, State: RefreshFailed, Error: err.Error()}
+		n.mu.Unlock()
+		return err
+	}
+
+	n.mu.Lock()
+	n.pending = result.Result
+	n.refresh = &RefreshStatus{Epoch: epoch, State: RefreshPending}
+	if n.refreshInterval > 0 {
+		at := RefreshSwitch(epoch, n.refreshInterval)
+		n.refresh.SwitchAt = &at
+	}
+	n.mu.Unlock()
+
+	return nil
+}
+
+// SwitchShare replaces the share of the node with the one of the pending
+// refresh of epoch, and zeroes the old one.
+func (n *Node) SwitchShare(epoch uint64) error {
+	n.refreshMu.Lock()
+	defer n.refreshMu.Unlock()
+
+	n.mu.Lock()
+	result := n.pending
+	if result == nil || n.refresh.Epoch != epoch {
+		n.mu.Unlock()
+		return fmt.Errorf("%w: %d", ErrNoPendingRefresh, epoch)
+	}
+	n.pending = nil
+	n.refresh = &RefreshStatus{Epoch: epoch, State: RefreshDone}
+	previous := n.setResultLocked(result)
+	n.mu.Unlock()
+
+	n.setShare(result.Key)
+	zeroDistKeyShare(previous.Key)
+	n.save(result)
+
+	return nil
+}
+
+// zeroDistKeyShare overwrites the private share in place. Go gives no
+// guarantee that no other copy of the scalar survives in memory, this only
+// clears the one the node held.
+func zeroDistKeyShare(key *pedersen_dkg.DistKeyShare) {
+	if key == nil || key.Share == nil {
+		return
+	}
+	key.Share.V.Zero()
+}
@@ -0,0 +1,121 @@
+package dkg
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/crypto"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// runRefresh reshares the keys of results to the same committee, each node
+// with its own share as RefreshConfig does.
+func runRefresh(t *testing.T, s *crypto.Scheme, tns []*TestNode, thr int, results []*pedersen_dkg.Result, epoch uint64) []*pedersen_dkg.Result {
+	list := NodesFromTest(tns)
+	nonce := RefreshNonce([]byte("nonce"), epoch)
+
+	var dkgs []*pedersen_dkg.DistKeyGenerator
+	for i, tn := range tns {
+		d, err := pedersen_dkg.NewDistKeyHandler(RefreshConfig(s, tn.Private, list, thr, results[i].Key, nonce))
+		require.NoError(t, err)
+		dkgs = append(dkgs, d)
+	}
+
+	var deals []*pedersen_dkg.DealBundle
+	for _, d := range dkgs {
+		deal, err := d.Deals()
+		require.NoError(t, err)
+		deals = append(deals, deal)
+	}
+
+	var resps []*pedersen_dkg.ResponseBundle
+	for _, d := range dkgs {
+		resp, err := d.ProcessDeals(deals)
+		require.NoError(t, err)
+		if resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	var refreshed []*pedersen_dkg.Result
+	for _, d := range dkgs {
+		res, just, err := d.ProcessResponses(resps)
+		require.NoError(t, err)
+		require.Nil(t, just)
+		refreshed = append(refreshed, res)
+	}
+	return refreshed
+}
+
+func TestRefreshKeepsGroupKey(t *testing.T) {
+	n := 3
+	threshold := 2
+	scheme := crypto.DefaultScheme()
+
+	tns := GenerateTestNodes(scheme.KeyGroup, n)
+	conf := pedersen_dkg.Config{
+		Suite:     scheme.KeyGroup,
+		NewNodes:  NodesFromTest(tns),
+		Threshold: threshold,
+		Auth:      scheme.AuthScheme,
+	}
+	results := RunDKG(t, tns, conf, nil, nil, nil)
+
+	refreshed := runRefresh(t, scheme, tns, threshold, results, 1)
+	testResults(t, scheme.KeyGroup, threshold, n, refreshed)
+
+	msg := []byte("Hello World")
+	oldPartial, err := scheme.ThresholdScheme.Sign(results[0].Key.PriShare(), msg)
+	require.NoError(t, err)
+
+	for i := range results {
+		require.True(t, results[i].Key.Public().Equal(refreshed[i].Key.Public()))
+		require.False(t, results[i].Key.Share.V.Equal(refreshed[i].Key.Share.V))
+	}
+
+	// old partials no longer match the refreshed commitments
+	poly := share.NewPubPoly(scheme.KeyGroup, scheme.KeyGroup.Point().Base(), refreshed[0].Key.Commits)
+	require.Error(t, scheme.ThresholdScheme.VerifyPartial(poly, msg, oldPartial))
+
+	var partials [][]byte
+	for _, res := range refreshed[1:] {
+		sig, err := scheme.ThresholdScheme.Sign(res.Key.PriShare(), msg)
+		require.NoError(t, err)
+		partials = append(partials, sig)
+	}
+	sig, err := scheme.ThresholdScheme.Recover(poly, msg, partials, threshold, n)
+	require.NoError(t, err)
+	require.NoError(t, scheme.SigScheme.Verify(results[0].Key.Public(), msg, sig))
+
+	// refreshing again from the refreshed shares keeps the key as well
+	again := runRefresh(t, scheme, tns, threshold, refreshed, 2)
+	require.True(t, again[0].Key.Public().Equal(results[0].Key.Public()))
+}
+
+func TestZeroDistKeyShare(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	key := &pedersen_dkg.DistKeyShare{
+		Share: &share.PriShare{I: 1, V: scheme.KeyGroup.Scalar().SetInt64(42)},
+	}
+
+	zeroDistKeyShare(key)
+	require.True(t, key.Share.V.Equal(scheme.KeyGroup.Scalar().Zero()))
+
+	zeroDistKeyShare(nil)
+}
+
+func TestRefreshNonce(t *testing.T) {
+	nonce := []byte("nonce")
+	require.Equal(t, RefreshNonce(nonce, 1), RefreshNonce(nonce, 1))
+	require.NotEqual(t, RefreshNonce(nonce, 1), RefreshNonce(nonce, 2))
+	require.NotEqual(t, nonce, RefreshNonce(nonce, 0))
+}
+
+// Shares switch half an interval after their refresh started.
+func TestRefreshSwitch(t *testing.T) {
+	at := RefreshSwitch(3, time.Minute)
+	require.Equal(t, time.Unix(210, 0), at)
+}
@@ -0,0 +1,62 @@
+package dkg
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireNoLeak fails unless the goroutines started since baseline return.
+// Pubsub is still running, so goroutines of the nodes blocked on it show up.
+func requireNoLeak(t *testing.T, baseline int) {
+	ok := false
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(50 * time.Millisecond) {
+		if runtime.NumGoroutine() <= baseline {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		buf := make([]byte, 1<<20)
+		t.Fatalf("%d goroutines left, %d before:\n%s", runtime.NumGoroutine(), baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestShutdownBeforeDKG(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newCluster(t, ctx, 3, 2)
+
+	cancel()
+	c.close(t)
+	requireNoLeak(t, c.baseline)
+
+	_, err := c.nodes[0].WaitDKG()
+	require.ErrorIs(t, err, ErrNodeClosed)
+	require.Equal(t, DKGNotStarted, c.nodes[0].Status().DKG.State)
+}
+
+func TestShutdownWithPendingRound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newCluster(t, ctx, 3, 2)
+	c.runDKG(t)
+
+	data := []byte("round")
+	require.NoError(t, c.nodes[0].StartRandomNumberGeneration("round", data))
+
+	cancel()
+	c.close(t)
+	requireNoLeak(t, c.baseline)
+
+	// closing wakes up the round, which then fails
+	<-c.nodes[0].WaitRNGRound("round")
+	_, err := c.nodes[0].RecoverBLSSignature("round", data)
+	require.ErrorIs(t, err, ErrNodeClosed)
+	require.ErrorIs(t, c.nodes[0].StartRandomNumberGeneration("other", data), ErrNodeClosed)
+}
@@ -0,0 +1,306 @@
+package dkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/rng"
+	"random-network-poc/sim"
+
+	"github.com/stretchr/testify/require"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// simRoundTimeout is the RoundTimeout of simulated nodes.
+const simRoundTimeout = 5 * time.Second
+
+// simCluster is a committee of validators over a simulated network, on
+// virtual time.
+type simCluster struct {
+	net   *sim.Network
+	nodes []*Node
+}
+
+func newSimCluster(t testing.TB, n, threshold int, seed uint64, link sim.Link) *simCluster {
+	return newWrappedSimCluster(t, n, threshold, seed, link, nil)
+}
+
+// newWrappedSimCluster is newSimCluster with the boards of the nodes wrapped
+// by wrap, unless nil.
+func newWrappedSimCluster(t testing.TB, n, threshold int, seed uint64, link sim.Link, wrap func(pedersen_dkg.Board) pedersen_dkg.Board) *simCluster {
+	c := &simCluster{net: sim.New(clock.NewVirtual(time.Unix(0, 0)), n, seed, link)}
+
+	scheme := crypto.DefaultScheme()
+	tns := GenerateTestNodes(scheme.KeyGroup, n)
+	nonce := pedersen_dkg.GetNonce()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for i := range n {
+		var board pedersen_dkg.Board = c.net.Board(i)
+		if wrap != nil {
+			board = wrap(board)
+		}
+
+		node, err := NewNode(t.Context(), &Config{
+			Index:        uint32(i),
+			LongtermKey:  tns[i].Private,
+			Nonce:        nonce,
+			Scheme:       scheme,
+			Nodes:        NodesFromTest(tns),
+			Threshold:    threshold,
+			Logger:       logger,
+			Clock:        c.net.Clock(),
+			RoundTimeout: simRoundTimeout,
+			Transport:    c.net.Transport(i),
+		}, board, nil, nil)
+		require.NoError(t, err)
+		c.nodes = append(c.nodes, node)
+	}
+
+	t.Cleanup(func() { c.close(t) })
+
+	return c
+}
+
+func (c *simCluster) close(t testing.TB) {
+	for _, node := range c.nodes {
+		require.NoError(t, node.Close())
+	}
+}
+
+// runDKG runs the DKG through its phases on virtual time and returns the
+// outcome of every node: its QUAL, or its error.
+func (c *simCluster) runDKG() []string {
+	for _, node := range c.nodes {
+		node.StartDKG()
+	}
+	c.net.Run(3 * phaseDuration)
+
+	var outcomes []string
+	for _, node := range c.nodes {
+		result, err := node.WaitDKG()
+		if err != nil {
+			outcomes = append(outcomes, err.Error())
+			continue
+		}
+		var qual []uint32
+		for _, n := range result.QUAL {
+			qual = append(qual, n.Index)
+		}
+		slices.Sort(qual)
+		outcomes = append(outcomes, fmt.Sprint(qual))
+	}
+	return outcomes
+}
+
+// A dealer is kept as long as its deals arrive before the response phase, a
+// boundary a wall clock cannot hold to the millisecond.
+func TestSimLateDeals(t *testing.T) {
+	for _, tc := range []struct {
+		latency time.Duration
+		qual    string
+	}{
+		{999 * time.Millisecond, "[0 1 2 3 4]"},
+		{1001 * time.Millisecond, "[0 1 2 4]"},
+	} {
+		t.Run(tc.latency.String(), func(t *testing.T) {
+			c := newSimCluster(t, 5, 3, 1, sim.Link{Latency: 50 * time.Millisecond})
+			for to := range 5 {
+				c.net.SetLink(3, to, sim.Link{Latency: tc.latency})
+			}
+
+			outcomes := c.runDKG()
+			for i, outcome := range outcomes {
+				if i == 3 && tc.qual != "[0 1 2 3 4]" {
+					require.Equal(t, pedersen_dkg.ErrEvicted.Error(), outcome)
+					continue
+				}
+				require.Equal(t, tc.qual, outcome, "node %d", i)
+			}
+		})
+	}
+}
+
+// An evicted node refuses to sign, and the others only count on QUAL.
+func TestSimEviction(t *testing.T) {
+	c := newSimCluster(t, 5, 3, 1, sim.Link{Latency: 50 * time.Millisecond})
+	for to := range 5 {
+		c.net.SetLink(3, to, sim.Link{Latency: 1001 * time.Millisecond})
+	}
+	c.runDKG()
+
+	evicted := c.nodes[3]
+	require.False(t, evicted.Qualified())
+	require.Empty(t, evicted.QUAL())
+	require.False(t, evicted.Status().Qualified)
+	require.ErrorIs(t, evicted.Ready(), ErrNotReady)
+	_, err := evicted.SignVRF(rng.SignVRF{RequestID: "round-0", Data: "00"})
+	require.ErrorIs(t, err, ErrNotQualified)
+	require.ErrorIs(t, evicted.StartRandomNumberGeneration("round-0", []byte("round-0")), ErrNotQualified)
+
+	aggregator := c.nodes[0]
+	require.True(t, aggregator.Qualified())
+	require.Equal(t, []uint32{0, 1, 2, 4}, aggregator.QUAL())
+	require.Equal(t, []uint32{0, 1, 2, 4}, aggregator.Status().QUAL)
+
+	input := []byte("round-1")
+	require.NoError(t, aggregator.StartRandomNumberGeneration("round-1", input))
+	partial, err := aggregator.Sign(input)
+	require.NoError(t, err)
+	err = aggregator.HandleSignature(rng.Signature{RequestID: "round-1", Signature: hex.EncodeToString(partial), SenderIndex: 3})
+	require.ErrorIs(t, err, ErrNotQualified)
+	c.net.Run(200 * time.Millisecond)
+	sig, err := aggregator.RecoverBLSSignature("round-1", input)
+	require.NoError(t, err)
+	require.NoError(t, c.nodes[4].VerifyBLSSignature(input, sig))
+
+	// two refusals out of four qualified signers leave the round short
+	input = []byte("round-2")
+	require.NoError(t, aggregator.StartRandomNumberGeneration("round-2", input))
+	for _, from := range []uint32{3, 1, 2} {
+		err := aggregator.HandleSignature(rng.Signature{RequestID: "round-2", Rejected: "busy", SenderIndex: from})
+		if from == 3 {
+			require.ErrorIs(t, err, ErrNotQualified)
+			continue
+		}
+		require.NoError(t, err)
+	}
+	require.Len(t, aggregator.WaitRNGRound("round-2"), 1)
+	_, err = aggregator.RecoverBLSSignature("round-2", input)
+	require.ErrorIs(t, err, ErrRequestRejected)
+}
+
+func TestSimPartitionedDealer(t *testing.T) {
+	c := newSimCluster(t, 5, 3, 1, sim.Link{Latency: 50 * time.Millisecond})
+	c.net.Partition([]int{0, 1, 2, 3})
+
+	outcomes := c.runDKG()
+	for i := range 4 {
+		require.Equal(t, "[0 1 2 3]", outcomes[i], "node %d", i)
+	}
+	require.Contains(t, outcomes[4], "valid deals")
+	require.NotZero(t, c.net.Stats().Cut)
+}
+
+// lossyLink delays messages by up to 1.5s, across phase boundaries, and
+// loses some.
+var lossyLink = sim.Link{Latency: 10 * time.Millisecond, Jitter: 1500 * time.Millisecond, Loss: 0.1}
+
+// Lossy runs replay from their seed: the same seed gives the same outcome on
+// every node and the same messages.
+func TestSimSeeds(t *testing.T) {
+	for seed := range uint64(4) {
+		run := func() ([]string, sim.Stats) {
+			c := newSimCluster(t, 5, 3, seed, lossyLink)
+			return c.runDKG(), c.net.Stats()
+		}
+
+		outcomes, stats := run()
+		again, againStats := run()
+		require.Equal(t, outcomes, again, "seed %d", seed)
+		require.Equal(t, stats, againStats, "seed %d", seed)
+	}
+}
+
+// The DKG relies on a board delivering every bundle in time: otherwise nodes
+// may finish with different QUALs, and so different group keys. Seed 1
+// reproduces it.
+func TestSimLossSplitsQUAL(t *testing.T) {
+	c := newSimCluster(t, 5, 3, 1, lossyLink)
+	require.Equal(t, []string{"[0 1 2]", "[0 1 3]", "[1 2 3]", "[0 1 2 3]", "[1 3 4]"}, c.runDKG())
+}
+
+func TestSimRounds(t *testing.T) {
+	c := newSimCluster(t, 5, 3, 1, sim.Link{Latency: 50 * time.Millisecond, Jitter: 50 * time.Millisecond})
+	for _, outcome := range c.runDKG() {
+		require.Equal(t, "[0 1 2 3 4]", outcome)
+	}
+
+	input := []byte("round-1")
+	require.NoError(t, c.nodes[0].StartRandomNumberGeneration("round-1", input))
+	c.net.Run(200 * time.Millisecond)
+	require.Len(t, c.nodes[0].WaitRNGRound("round-1"), 1)
+	sig, err := c.nodes[0].RecoverBLSSignature("round-1", input)
+	require.NoError(t, err)
+	require.NoError(t, c.nodes[4].VerifyBLSSignature(input, sig))
+
+	// cut off from enough signers, the round expires on virtual time
+	c.net.Partition([]int{0, 1}, []int{2, 3, 4})
+	input = []byte("round-2")
+	require.NoError(t, c.nodes[0].StartRandomNumberGeneration("round-2", input))
+	c.net.Run(simRoundTimeout - time.Millisecond)
+	require.Empty(t, c.nodes[0].WaitRNGRound("round-2"))
+
+	c.net.Run(time.Millisecond)
+	require.Len(t, c.nodes[0].WaitRNGRound("round-2"), 1)
+	_, err = c.nodes[0].RecoverBLSSignature("round-2", input)
+	require.ErrorIs(t, err, ErrRoundExpired)
+	require.ErrorContains(t, err, "has 2 of 3 partials")
+	require.Zero(t, c.nodes[0].Status().PendingRequests)
+}
+
+// A refreshed share is held pending, the committee signing with the old ones
+// until every validator switches, while a validator the refresh missed
+// reports it.
+func TestSimRefreshSwitch(t *testing.T) {
+	c := newSimCluster(t, 5, 3, 1, sim.Link{Latency: 50 * time.Millisecond})
+	for _, outcome := range c.runDKG() {
+		require.Equal(t, "[0 1 2 3 4]", outcome)
+	}
+	old := c.nodes[0].key().Share.V.Clone()
+
+	c.net.Partition([]int{0, 1, 2, 3}, []int{4})
+	errs := make(chan error, len(c.nodes))
+	for _, node := range c.nodes {
+		go func() { errs <- node.Refresh(1) }()
+	}
+	// every refresh is timing its phases
+	require.Eventually(t, func() bool { return c.net.Clock().Pending() >= len(c.nodes) }, time.Second, time.Millisecond)
+	c.net.Run(3 * phaseDuration)
+	for range c.nodes {
+		<-errs
+	}
+	c.net.Heal()
+
+	for _, node := range c.nodes[:4] {
+		require.Equal(t, &RefreshStatus{Epoch: 1, State: RefreshPending}, node.Status().Refresh)
+	}
+	refresh := c.nodes[4].Status().Refresh
+	require.Equal(t, RefreshFailed, refresh.State)
+	require.NotEmpty(t, refresh.Error)
+	require.Error(t, c.nodes[4].Ready())
+	require.ErrorIs(t, c.nodes[0].Refresh(2), ErrRefreshPending)
+
+	round := func(id string) error {
+		input := []byte(id)
+		if err := c.nodes[0].StartRandomNumberGeneration(id, input); err != nil {
+			return err
+		}
+		c.net.Run(200 * time.Millisecond)
+		require.Len(t, c.nodes[0].WaitRNGRound(id), 1)
+		sig, err := c.nodes[0].RecoverBLSSignature(id, input)
+		if err != nil {
+			return err
+		}
+		return c.nodes[1].VerifyBLSSignature(input, sig)
+	}
+
+	// still on the old shares
+	require.True(t, c.nodes[0].key().Share.V.Equal(old))
+	require.NoError(t, round("before"))
+
+	for _, node := range c.nodes[:4] {
+		require.NoError(t, node.SwitchShare(1))
+		require.Equal(t, &RefreshStatus{Epoch: 1, State: RefreshDone}, node.Status().Refresh)
+	}
+	require.False(t, c.nodes[0].key().Share.V.Equal(old))
+	require.NoError(t, round("after"))
+	require.ErrorIs(t, c.nodes[0].SwitchShare(1), ErrNoPendingRefresh)
+}
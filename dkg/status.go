@@ -0,0 +1,154 @@
+package dkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"random-network-poc/rng"
+)
+
+// DKGState is the state of the initial DKG of a node.
+type DKGState string
+
+const (
+	DKGNotStarted DKGState = "not_started"
+	DKGRunning    DKGState = "running"
+	DKGDone       DKGState = "done"
+	DKGFailed     DKGState = "failed"
+)
+
+// Status is a snapshot of a node, reported by the admin server.
+type Status struct {
+	Index uint32 `json:"index"`
+	// Group is the committee group of the node, empty for the default one.
+	Group string `json:"group,omitempty"`
+	// Epoch is the committee epoch of the node, see Config.Epoch.
+	Epoch uint64    `json:"epoch,omitempty"`
+	DKG   DKGStatus `json:"dkg"`
+	// PublicKey is the hex group public key once the DKG is done.
+	PublicKey string `json:"public_key,omitempty"`
+	// ShareIndex is the index of the share of the node once the DKG is
+	// done.
+	ShareIndex *uint32 `json:"share_index,omitempty"`
+	// QUAL is the share indices of the nodes qualified by the DKG or
+	// refresh the share comes from.
+	QUAL []uint32 `json:"qual,omitempty"`
+	// Qualified is set while the node holds a share it signs with, see
+	// Node.Qualified.
+	Qualified bool `json:"qualified"`
+	// Peers is the number of peers subscribed to each topic of the node.
+	Peers map[string]int `json:"peers"`
+	// LastRound is the last round recovered by the node.
+	LastRound *RoundStatus `json:"last_round,omitempty"`
+	// PendingRequests is the number of rounds started by the node and not
+	// yet recovered.
+	PendingRequests int `json:"pending_requests"`
+	// Refresh describes the last share refresh, if any.
+	Refresh *RefreshStatus `json:"refresh,omitempty"`
+}
+
+// DKGStatus describes the initial DKG of a node.
+type DKGStatus struct {
+	State DKGState `json:"state"`
+	// Phase is the current phase while the DKG runs, and the last one once
+	// it failed.
+	Phase string `json:"phase,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RoundStatus describes a recovered round.
+type RoundStatus struct {
+	RequestID string    `json:"request_id"`
+	Signature string    `json:"signature"`
+	Partials  int       `json:"partials"`
+	Time      time.Time `json:"time"`
+}
+
+// ErrNotReady is returned by Ready while the node cannot serve rounds.
+var ErrNotReady = errors.New("node not ready")
+
+// Status returns a snapshot of the node.
+func (n *Node) Status() Status {
+	var s Status
+	s.Index = n.index
+	s.Group = n.group
+	s.Epoch = n.epoch
+	s.Peers = n.topicPeers()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s.DKG.State = n.dkgState
+	s.DKG.Phase = n.dkgPhase
+	if n.dkgErr != nil {
+		s.DKG.Error = n.dkgErr.Error()
+	}
+
+	if n.Result != nil {
+		if pub, err := n.Result.Key.Public().MarshalBinary(); err == nil {
+			s.PublicKey = hex.EncodeToString(pub)
+		}
+		i := n.Result.Key.Share.I
+		s.ShareIndex = &i
+	}
+	s.QUAL = slices.Clone(n.qual)
+	s.Qualified = n.qualifiedLocked()
+
+	if n.lastRound != nil {
+		last := *n.lastRound
+		s.LastRound = &last
+	}
+	s.PendingRequests = len(n.rounds)
+	if n.refresh != nil {
+		refresh := *n.refresh
+		s.Refresh = &refresh
+	}
+
+	return s
+}
+
+// Ready returns nil once the DKG is done, the node is qualified, its last
+// refresh did not fail and every topic has enough peers for rounds to reach
+// the threshold.
+func (n *Node) Ready() error {
+	n.mu.Lock()
+	state := n.dkgState
+	qualified := n.qualifiedLocked()
+	refresh := n.refresh
+	n.mu.Unlock()
+
+	if state != DKGDone {
+		return fmt.Errorf("%w: DKG %s", ErrNotReady, state)
+	}
+	if !qualified {
+		return fmt.Errorf("%w: %w", ErrNotReady, ErrNotQualified)
+	}
+	if refresh != nil && refresh.State == RefreshFailed {
+		return fmt.Errorf("%w: refresh of epoch %d failed", ErrNotReady, refresh.Epoch)
+	}
+
+	for topic, peers := range n.topicPeers() {
+		if peers < n.threshold-1 {
+			return fmt.Errorf("%w: %d peers on topic %s, %d needed", ErrNotReady, peers, topic, n.threshold-1)
+		}
+	}
+
+	return nil
+}
+
+// topicPeers returns the number of peers subscribed to each topic of the
+// node.
+func (n *Node) topicPeers() map[string]int {
+	peers := make(map[string]int)
+	if n.ps == nil {
+		return peers
+	}
+	for _, topic := range []string{Topic, rng.SignVrfInput, rng.SignVrfOutput} {
+		topic = rng.GroupTopic(topic, n.group)
+		peers[topic] = len(n.ps.ListPeers(topic))
+	}
+	return peers
+}
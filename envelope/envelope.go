@@ -0,0 +1,195 @@
+// Package envelope authenticates every message published on the network.
+// Messages are wrapped with the protocol version, the network, the topic, the
+// committee index of the sender and a sequence number, and signed with the
+// longterm key of the sender. Envelopes sealed too far from now are rejected,
+// so a recorded message cannot be replayed later on.
+package envelope
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sync/atomic"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/pb"
+	"random-network-poc/signer"
+
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"google.golang.org/protobuf/proto"
+)
+
+// Version is the envelope version this node seals and opens.
+const Version = 1
+
+// DefaultMaxAge is how far from now an envelope may have been sealed.
+const DefaultMaxAge = 10 * time.Minute
+
+var (
+	ErrVersion       = errors.New("unsupported envelope version")
+	ErrNetwork       = errors.New("envelope from another network")
+	ErrTopic         = errors.New("envelope for another topic")
+	ErrUnknownSender = errors.New("sender is not in the committee")
+	ErrSignature     = errors.New("invalid envelope signature")
+	ErrStale         = errors.New("envelope sealed too far from now")
+)
+
+// Config holds the identity of the node and the committee it talks to.
+type Config struct {
+	Scheme   *crypto.Scheme
+	Index    uint32
+	Longterm kyber.Scalar
+	Nodes    []pedersen_dkg.Node
+	// Signer signs envelopes in place of Longterm, e.g. in a separate
+	// process.
+	Signer signer.Signer
+	// Clock timestamps sealed envelopes, defaults to the wall clock.
+	Clock clock.Clock
+	// MaxAge rejects envelopes sealed further than it from now, either way,
+	// defaults to DefaultMaxAge.
+	MaxAge time.Duration
+}
+
+// Codec seals outgoing messages and opens incoming ones.
+type Codec struct {
+	scheme   *crypto.Scheme
+	network  string
+	index    uint32
+	signer   signer.Signer
+	clock    clock.Clock
+	maxAge   time.Duration
+	nodes    map[uint32]kyber.Point
+	sequence atomic.Uint64
+}
+
+func New(c *Config) *Codec {
+	nodes := make(map[uint32]kyber.Point, len(c.Nodes))
+	for _, n := range c.Nodes {
+		nodes[n.Index] = n.Public
+	}
+
+	s := c.Signer
+	if s == nil {
+		s = signer.NewLocal(c.Scheme, c.Longterm)
+	}
+
+	clk := c.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+
+	maxAge := c.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	return &Codec{
+		scheme:  c.Scheme,
+		network: c.Scheme.Domain.Network,
+		index:   c.Index,
+		signer:  s,
+		clock:   clk,
+		maxAge:  maxAge,
+		nodes:   nodes,
+	}
+}
+
+// Seal wraps payload for topic and signs it.
+func (c *Codec) Seal(topic string, payload []byte) ([]byte, error) {
+	e := &pb.Envelope{
+		Version:     Version,
+		Network:     c.network,
+		Topic:       topic,
+		SenderIndex: c.index,
+		Sequence:    c.sequence.Add(1),
+		Timestamp:   c.clock.Now().UnixNano(),
+		Payload:     payload,
+	}
+
+	sig, err := c.signer.SignLongterm(digest(e))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign envelope: %w", err)
+	}
+	e.Signature = sig
+
+	return proto.Marshal(e)
+}
+
+// Open decodes an envelope received on topic and verifies it was signed by
+// the committee member it names, within the age window.
+func (c *Codec) Open(topic string, data []byte) (*pb.Envelope, error) {
+	e := new(pb.Envelope)
+	if err := proto.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	if e.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, e.Version)
+	}
+	if e.Network != c.network {
+		return nil, fmt.Errorf("%w: %q", ErrNetwork, e.Network)
+	}
+	if e.Topic != topic {
+		return nil, fmt.Errorf("%w: %q", ErrTopic, e.Topic)
+	}
+
+	public, ok := c.nodes[e.SenderIndex]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSender, e.SenderIndex)
+	}
+
+	if err := c.scheme.AuthScheme.Verify(public, digest(e), e.Signature); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSignature, err)
+	}
+
+	sealed := time.Unix(0, e.Timestamp)
+	if age := c.clock.Now().Sub(sealed); age >= c.maxAge || age <= -c.maxAge {
+		return nil, fmt.Errorf("%w: %s", ErrStale, sealed)
+	}
+
+	return e, nil
+}
+
+// Index returns the committee index of the node.
+func (c *Codec) Index() uint32 {
+	return c.index
+}
+
+// IndexOf returns the committee index of the member with the public key.
+func (c *Codec) IndexOf(public kyber.Point) (uint32, bool) {
+	for index, p := range c.nodes {
+		if p.Equal(public) {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// Size returns the number of members of the committee.
+func (c *Codec) Size() int {
+	return len(c.nodes)
+}
+
+// digest hashes every field but the signature, each with a fixed size or a
+// length prefix.
+func digest(e *pb.Envelope) []byte {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, e.Version)
+	writeBytes(h, []byte(e.Network))
+	writeBytes(h, []byte(e.Topic))
+	binary.Write(h, binary.BigEndian, e.SenderIndex)
+	binary.Write(h, binary.BigEndian, e.Sequence)
+	binary.Write(h, binary.BigEndian, e.Timestamp)
+	writeBytes(h, e.Payload)
+	return h.Sum(nil)
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	binary.Write(h, binary.BigEndian, uint32(len(b)))
+	h.Write(b)
+}
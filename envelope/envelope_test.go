@@ -0,0 +1,125 @@
+package envelope
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/pb"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+	"google.golang.org/protobuf/proto"
+)
+
+func newCommittee(s *crypto.Scheme, n int) ([]kyber.Scalar, []pedersen_dkg.Node) {
+	var keys []kyber.Scalar
+	var nodes []pedersen_dkg.Node
+	for i := 0; i < n; i++ {
+		key := s.KeyGroup.Scalar().Pick(random.New())
+		keys = append(keys, key)
+		nodes = append(nodes, pedersen_dkg.Node{
+			Index:  uint32(i),
+			Public: s.KeyGroup.Point().Mul(key, nil),
+		})
+	}
+	return keys, nodes
+}
+
+func newCodec(s *crypto.Scheme, keys []kyber.Scalar, nodes []pedersen_dkg.Node, index uint32) *Codec {
+	return New(&Config{Scheme: s, Index: index, Longterm: keys[index], Nodes: nodes})
+}
+
+func TestSealOpen(t *testing.T) {
+	s := crypto.DefaultScheme()
+	keys, nodes := newCommittee(s, 3)
+	sender := newCodec(s, keys, nodes, 1)
+	receiver := newCodec(s, keys, nodes, 0)
+
+	for seq := uint64(1); seq <= 2; seq++ {
+		data, err := sender.Seal("dkg", []byte("payload"))
+		require.NoError(t, err)
+
+		e, err := receiver.Open("dkg", data)
+		require.NoError(t, err)
+		require.Equal(t, uint32(1), e.SenderIndex)
+		require.Equal(t, seq, e.Sequence)
+		require.Equal(t, crypto.DefaultNetwork, e.Network)
+		require.Equal(t, []byte("payload"), e.Payload)
+		require.NotZero(t, e.Timestamp)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	s := crypto.DefaultScheme()
+	keys, nodes := newCommittee(s, 3)
+	sender := newCodec(s, keys, nodes, 1)
+	receiver := newCodec(s, keys, nodes, 0)
+
+	reseal := func(t *testing.T, f func(e *pb.Envelope)) []byte {
+		data, err := sender.Seal("dkg", []byte("payload"))
+		require.NoError(t, err)
+		e := new(pb.Envelope)
+		require.NoError(t, proto.Unmarshal(data, e))
+		f(e)
+		data, err = proto.Marshal(e)
+		require.NoError(t, err)
+		return data
+	}
+
+	_, err := receiver.Open("sign_vrf_input", reseal(t, func(*pb.Envelope) {}))
+	require.ErrorIs(t, err, ErrTopic)
+
+	_, err = receiver.Open("dkg", reseal(t, func(e *pb.Envelope) { e.Payload = []byte("forged") }))
+	require.ErrorIs(t, err, ErrSignature)
+
+	// a member cannot speak for another one
+	_, err = receiver.Open("dkg", reseal(t, func(e *pb.Envelope) { e.SenderIndex = 2 }))
+	require.ErrorIs(t, err, ErrSignature)
+
+	_, err = receiver.Open("dkg", reseal(t, func(e *pb.Envelope) { e.SenderIndex = 7 }))
+	require.ErrorIs(t, err, ErrUnknownSender)
+
+	_, err = receiver.Open("dkg", reseal(t, func(e *pb.Envelope) { e.Version = Version + 1 }))
+	require.ErrorIs(t, err, ErrVersion)
+
+	testnet, err := crypto.ParseSchemeWithDomain(crypto.DefaultSchemeName, crypto.Domain{Network: "testnet", Purpose: crypto.PurposeVRF})
+	require.NoError(t, err)
+	data, err := newCodec(testnet, keys, nodes, 1).Seal("dkg", []byte("payload"))
+	require.NoError(t, err)
+	_, err = receiver.Open("dkg", data)
+	require.ErrorIs(t, err, ErrNetwork)
+
+	_, err = receiver.Open("dkg", []byte("{}"))
+	require.Error(t, err)
+}
+
+func TestOpenStale(t *testing.T) {
+	s := crypto.DefaultScheme()
+	keys, nodes := newCommittee(s, 2)
+	clk := clock.NewVirtual(time.Unix(1700000000, 0))
+	sender := New(&Config{Scheme: s, Index: 1, Longterm: keys[1], Nodes: nodes, Clock: clk})
+	receiver := New(&Config{Scheme: s, Index: 0, Longterm: keys[0], Nodes: nodes, Clock: clk, MaxAge: time.Minute})
+
+	data, err := sender.Seal("dkg", []byte("payload"))
+	require.NoError(t, err)
+
+	clk.Advance(59 * time.Second)
+	_, err = receiver.Open("dkg", data)
+	require.NoError(t, err)
+
+	// a recorded envelope cannot be replayed later on
+	clk.Advance(time.Second)
+	_, err = receiver.Open("dkg", data)
+	require.ErrorIs(t, err, ErrStale)
+
+	// nor sealed ahead of time
+	data, err = sender.Seal("dkg", []byte("payload"))
+	require.NoError(t, err)
+	receiver = New(&Config{Scheme: s, Index: 0, Longterm: keys[0], Nodes: nodes, Clock: clock.NewVirtual(clk.Now().Add(-time.Minute)), MaxAge: time.Minute})
+	_, err = receiver.Open("dkg", data)
+	require.ErrorIs(t, err, ErrStale)
+}
@@ -0,0 +1,82 @@
+package epoch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+// approvalTag prefixes the message approving the announcement of a committee,
+// apart from any other message signed with the longterm key.
+const approvalTag = "RNN-EPOCH:"
+
+// ErrNotApproved is returned for an announcement short of the approvals of a
+// threshold of the committee before it.
+var ErrNotApproved = errors.New("announcement not approved")
+
+// Approval is the signature of the announcement of a committee by a member of
+// the committee before it, with its longterm key, see Approve.
+type Approval struct {
+	Public    kyber.Point
+	Signature []byte
+}
+
+// ApprovalMessage returns the message approving the announcement of next on
+// network: its epoch, first round, threshold and members in index order.
+func ApprovalMessage(network string, next *Committee) ([]byte, error) {
+	msg := append([]byte(approvalTag+network), 0)
+	msg = binary.BigEndian.AppendUint64(msg, next.Epoch)
+	msg = binary.BigEndian.AppendUint64(msg, next.FirstRound)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(next.Threshold))
+	for _, node := range next.Nodes {
+		public, err := node.Public.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal public key: %w", err)
+		}
+		msg = append(msg, public...)
+	}
+	return msg, nil
+}
+
+// Approve signs the announcement of next with the longterm key of a member of
+// the current committee, under the auth scheme and network of scheme.
+func Approve(scheme *crypto.Scheme, longterm kyber.Scalar, next *Committee) (Approval, error) {
+	msg, err := ApprovalMessage(scheme.Domain.Network, next)
+	if err != nil {
+		return Approval{}, err
+	}
+	sig, err := scheme.AuthScheme.Sign(longterm, msg)
+	if err != nil {
+		return Approval{}, fmt.Errorf("failed to sign announcement: %w", err)
+	}
+	return Approval{Public: scheme.KeyGroup.Point().Mul(longterm, nil), Signature: sig}, nil
+}
+
+// approved checks that a threshold of the members of prev approve next.
+// Approvals of other keys, invalid or repeated ones are not counted.
+func (m *Manager) approved(prev, next *Committee, approvals []Approval) error {
+	msg, err := ApprovalMessage(m.scheme.Domain.Network, next)
+	if err != nil {
+		return err
+	}
+
+	approved := make(map[uint32]bool)
+	for _, a := range approvals {
+		for _, node := range prev.Nodes {
+			if a.Public == nil || !node.Public.Equal(a.Public) || approved[node.Index] {
+				continue
+			}
+			if m.scheme.AuthScheme.Verify(node.Public, msg, a.Signature) == nil {
+				approved[node.Index] = true
+			}
+		}
+	}
+	if len(approved) < prev.Threshold {
+		return fmt.Errorf("%w: %d of %d members of epoch %d approve epoch %d", ErrNotApproved, len(approved), prev.Threshold, prev.Epoch, next.Epoch)
+	}
+	return nil
+}
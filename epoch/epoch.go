@@ -0,0 +1,597 @@
+// Package epoch moves the group key of a validator from one committee to the
+// next. An epoch is a committee and the first round it signs. The committee
+// of the next epoch is announced ahead of its first round: the current
+// committee hands the key over by resharing in the background while it keeps
+// signing, and rounds switch to the new shares at the boundary. Resharing
+// keeps the group public key, and outputs carry their epoch so a verifier
+// knows which committee signed them.
+//
+// Committee epochs are unrelated to the refresh epochs of dkg.Node, which
+// reshare within a committee.
+package epoch
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/metrics"
+	"random-network-poc/signer"
+
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var (
+	ErrInvalidCommittee = errors.New("invalid committee")
+	// ErrUnknownEpoch is returned for a round before the first epoch, or an
+	// epoch never announced.
+	ErrUnknownEpoch = errors.New("unknown epoch")
+	// ErrHandoverPending is returned for the rounds of an epoch whose
+	// handover has not ended yet.
+	ErrHandoverPending = errors.New("handover pending")
+	ErrHandoverFailed  = errors.New("handover failed")
+	// ErrNotMember is returned for the rounds of an epoch the validator
+	// takes no part in.
+	ErrNotMember = errors.New("not a member of the committee")
+	// ErrRetired is returned for the rounds of an epoch two announcements
+	// behind, whose node is closed.
+	ErrRetired = errors.New("epoch retired")
+	ErrClosed  = errors.New("epoch manager closed")
+)
+
+// States of an epoch.
+const (
+	StatePending = "pending"
+	StateActive  = "active"
+	StateFailed  = "failed"
+	StateRetired = "retired"
+)
+
+// Committee is the committee of an epoch.
+type Committee struct {
+	Epoch uint64
+	// FirstRound is the first round the committee signs, the committee of
+	// the previous epoch signs the rounds before it.
+	FirstRound uint64
+	Nodes      []pedersen_dkg.Node
+	Threshold  int
+}
+
+// Config holds the parameters of the validator shared by its epochs.
+type Config struct {
+	// Scheme defaults to crypto.DefaultScheme.
+	Scheme   *crypto.Scheme
+	Longterm kyber.Scalar
+	// Signer signs the handover bundles of a validator joining a committee,
+	// defaults to signing in process. Members of the previous committee
+	// sign with the signer of their node.
+	Signer signer.Signer
+	// Nonce is the nonce of the initial DKG, see dkg.HandoverNonce.
+	Nonce []byte
+	// GroupKey is the group public key, which the commits given to a
+	// validator joining a committee are checked against. It defaults to the
+	// key of the nodes the validator has run since it started, so only a
+	// validator without any needs it.
+	GroupKey kyber.Point
+	// NewNode creates the node of the validator in the committee of an
+	// epoch, at index. The manager installs its share: the node never runs
+	// the DKG.
+	NewNode func(c *Committee, index uint32) (*dkg.Node, error)
+	// Board returns the board of the handover from the committee prev to
+	// next, carrying the bundles of the members of both, see Union. It is
+	// closed after the handover if it is an io.Closer.
+	Board func(prev, next *Committee) (pedersen_dkg.Board, error)
+	// Clock times the handover phases of a validator joining a committee,
+	// defaults to the wall clock. Members of the previous committee use the
+	// clock of their node.
+	Clock clock.Clock
+	// Logger defaults to slog.Default.
+	Logger *slog.Logger
+	// Metrics records the handovers, nil records nothing.
+	Metrics *metrics.Metrics
+	// Save persists the committees of the epochs the validator signs or may
+	// still sign, oldest first, whenever a handover ends or an epoch
+	// retires, for Restore. The node of each epoch saves its own share, see
+	// dkg.Config.SaveResult. Nil keeps the epochs in memory only.
+	Save func(epochs []Committee) error
+}
+
+// epoch is a committee of the manager.
+type epoch struct {
+	Committee
+	// node signs the rounds of the epoch, nil outside the committee or
+	// before the handover ended.
+	node  *dkg.Node
+	state string
+	err   error
+}
+
+// Manager runs the epochs of a validator.
+type Manager struct {
+	c      *Config
+	scheme *crypto.Scheme
+	public kyber.Point
+	log    *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wg tracks the handovers, waited for by Close.
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+	// epochs in order, from the first one
+	epochs []*epoch
+	// key is the group public key once seen on a node of the validator
+	key    kyber.Point
+	closed bool
+}
+
+// New returns a manager starting at the epoch of current. node is the node
+// of the validator in current, with its share, and nil outside of it. The
+// manager owns node from then on. Handovers stop once ctx is done.
+func New(ctx context.Context, c *Config, current Committee, node *dkg.Node) (*Manager, error) {
+	return Restore(ctx, c, []Committee{current}, []*dkg.Node{node})
+}
+
+// Restore returns a manager resuming the epochs saved by Config.Save, with
+// the node of the validator in each, holding its saved share, and nil
+// outside of it. The manager owns the nodes from then on.
+func Restore(ctx context.Context, c *Config, epochs []Committee, nodes []*dkg.Node) (*Manager, error) {
+	scheme := c.Scheme
+	if scheme == nil {
+		scheme = crypto.DefaultScheme()
+	}
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	m := &Manager{
+		c:      c,
+		scheme: scheme,
+		public: scheme.KeyGroup.Point().Mul(c.Longterm, nil),
+		log:    logger,
+	}
+
+	if len(epochs) == 0 || len(nodes) != len(epochs) {
+		return nil, fmt.Errorf("%w: %d epochs with %d nodes", ErrInvalidCommittee, len(epochs), len(nodes))
+	}
+	for i := range epochs {
+		current := &epochs[i]
+		if err := validate(current); err != nil {
+			return nil, err
+		}
+		if _, member := m.indexIn(current); member != (nodes[i] != nil) {
+			return nil, fmt.Errorf("%w: node given for membership %t in epoch %d", ErrInvalidCommittee, member, current.Epoch)
+		}
+		if i > 0 {
+			prev := &epochs[i-1]
+			if current.Epoch != prev.Epoch+1 || current.FirstRound <= prev.FirstRound {
+				return nil, fmt.Errorf("%w: epoch %d from round %d follows epoch %d from round %d", ErrInvalidCommittee, current.Epoch, current.FirstRound, prev.Epoch, prev.FirstRound)
+			}
+		}
+		m.epochs = append(m.epochs, &epoch{Committee: *current, node: nodes[i], state: StateActive})
+	}
+
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	return m, nil
+}
+
+// validate checks that c can sign rounds.
+func validate(c *Committee) error {
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("%w: epoch %d has no node", ErrInvalidCommittee, c.Epoch)
+	}
+	if c.Threshold < 1 || c.Threshold > len(c.Nodes) {
+		return fmt.Errorf("%w: epoch %d has threshold %d of %d nodes", ErrInvalidCommittee, c.Epoch, c.Threshold, len(c.Nodes))
+	}
+	for i, node := range c.Nodes {
+		if node.Index != uint32(i) {
+			return fmt.Errorf("%w: epoch %d has node %d at index %d", ErrInvalidCommittee, c.Epoch, node.Index, i)
+		}
+	}
+	return nil
+}
+
+// indexIn returns the index of the validator in c, if a member.
+func (m *Manager) indexIn(c *Committee) (uint32, bool) {
+	for _, node := range c.Nodes {
+		if node.Public.Equal(m.public) {
+			return node.Index, true
+		}
+	}
+	return 0, false
+}
+
+// Union returns the members of both committees, those of prev first, indexed
+// in that order. It is the committee of the envelopes of a handover board.
+func Union(prev, next *Committee) []pedersen_dkg.Node {
+	var nodes []pedersen_dkg.Node
+	add := func(node pedersen_dkg.Node) {
+		for _, n := range nodes {
+			if n.Public.Equal(node.Public) {
+				return
+			}
+		}
+		nodes = append(nodes, pedersen_dkg.Node{Index: uint32(len(nodes)), Public: node.Public})
+	}
+	for _, node := range prev.Nodes {
+		add(node)
+	}
+	for _, node := range next.Nodes {
+		add(node)
+	}
+	return nodes
+}
+
+// Announce starts the handover to the committee of the next epoch, which
+// signs from next.FirstRound on. Every validator of both committees announces
+// the same committee at about the same time, as they start a DKG, with the
+// approvals of a threshold of the current committee. commits is the public
+// polynomial of the current epoch, see dkg.Node.Commits, only needed by
+// validators joining the group, which check it against the group key. A
+// failed handover is retried by announcing its epoch again on every
+// validator, including those leaving the group, which cannot tell a failure.
+//
+// The epoch before the current one is retired: its node is closed.
+func (m *Manager) Announce(next Committee, commits []kyber.Point, approvals []Approval) error {
+	if err := validate(&next); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	last := m.epochs[len(m.epochs)-1]
+	// a validator leaving the group cannot tell whether the handover failed
+	// for the others
+	retry := last.state == StateFailed || last.state == StateActive && last.node == nil
+	retry = retry && next.Epoch == last.Epoch && len(m.epochs) > 1
+	if retry {
+		last = m.epochs[len(m.epochs)-2]
+	}
+	switch {
+	case last.state == StatePending:
+		return fmt.Errorf("%w: epoch %d", ErrHandoverPending, last.Epoch)
+	case last.state == StateFailed:
+		return fmt.Errorf("%w: epoch %d, announce it again", ErrHandoverFailed, last.Epoch)
+	case next.Epoch != last.Epoch+1:
+		return fmt.Errorf("%w: epoch %d follows epoch %d", ErrInvalidCommittee, next.Epoch, last.Epoch)
+	case next.FirstRound <= last.FirstRound:
+		return fmt.Errorf("%w: epoch %d starts at round %d, epoch %d at round %d", ErrInvalidCommittee, next.Epoch, next.FirstRound, last.Epoch, last.FirstRound)
+	}
+	if err := m.approved(&last.Committee, &next, approvals); err != nil {
+		return err
+	}
+
+	index, member := m.indexIn(&next)
+	key := m.groupKeyLocked()
+	if member && last.node == nil {
+		switch {
+		case len(commits) == 0:
+			return fmt.Errorf("%w: joining epoch %d needs the commits of epoch %d", ErrInvalidCommittee, next.Epoch, last.Epoch)
+		case key == nil:
+			return fmt.Errorf("%w: joining epoch %d needs the group key to check the commits against", ErrInvalidCommittee, next.Epoch)
+		case !commits[0].Equal(key):
+			return fmt.Errorf("%w: commits of epoch %d are not those of the group key", ErrInvalidCommittee, last.Epoch)
+		}
+	}
+	if retry {
+		m.epochs = m.epochs[:len(m.epochs)-1]
+	}
+
+	for _, e := range m.epochs[:len(m.epochs)-1] {
+		m.retireLocked(e)
+	}
+	m.saveLocked()
+
+	e := &epoch{Committee: next, state: StatePending}
+	m.epochs = append(m.epochs, e)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		err := m.handover(last, e, commits, index, member)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if err != nil {
+			e.state = StateFailed
+			e.err = err
+			if m.ctx.Err() != nil {
+				return
+			}
+			m.log.Error("Failed to hand over", "epoch", next.Epoch, "err", err)
+			return
+		}
+		e.state = StateActive
+		m.log.Info("Handed over", "epoch", next.Epoch, "member", member, "first_round", next.FirstRound)
+		m.saveLocked()
+	}()
+	return nil
+}
+
+// groupKeyLocked returns the group public key from the nodes the validator
+// has or had, or Config.GroupKey without any. m.mu must be held.
+func (m *Manager) groupKeyLocked() kyber.Point {
+	for _, e := range m.epochs {
+		if m.key != nil || e.node == nil {
+			continue
+		}
+		if commits := e.node.Commits(); len(commits) > 0 {
+			m.key = commits[0]
+		}
+	}
+	if m.key == nil {
+		return m.c.GroupKey
+	}
+	return m.key
+}
+
+// saveLocked persists the active epochs, see Config.Save. m.mu must be held.
+func (m *Manager) saveLocked() {
+	if m.c.Save == nil {
+		return
+	}
+
+	var committees []Committee
+	for _, e := range m.epochs {
+		if e.state == StateActive {
+			committees = append(committees, e.Committee)
+		}
+	}
+	if err := m.c.Save(committees); err != nil {
+		m.log.Error("Failed to save epochs", "err", err)
+	}
+}
+
+// retireLocked closes the node of e. m.mu must be held.
+func (m *Manager) retireLocked(e *epoch) {
+	if e.state == StateRetired {
+		return
+	}
+	e.state = StateRetired
+	if e.node == nil {
+		return
+	}
+	if err := e.node.Close(); err != nil {
+		m.log.Error("Failed to close node", "epoch", e.Epoch, "err", err)
+	}
+	e.node = nil
+}
+
+// handover reshares the key of prev to next, and creates the node of the
+// validator in next if a member, at index.
+func (m *Manager) handover(prev, next *epoch, commits []kyber.Point, index uint32, member bool) error {
+	if prev.node == nil && !member {
+		return nil
+	}
+
+	board, err := m.c.Board(&prev.Committee, &next.Committee)
+	if err != nil {
+		return fmt.Errorf("failed to create handover board: %w", err)
+	}
+	if closer, ok := board.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	conf := &dkg.HandoverConfig{
+		New:       next.Nodes,
+		Threshold: next.Threshold,
+		Nonce:     dkg.HandoverNonce(m.c.Nonce, next.Epoch),
+		Logger:    m.log.With("epoch", next.Epoch),
+		Metrics:   m.c.Metrics,
+	}
+
+	var result *pedersen_dkg.Result
+	if prev.node != nil {
+		result, err = prev.node.Handover(m.ctx, conf, board)
+	} else {
+		conf.Scheme = m.scheme
+		conf.Longterm = m.c.Longterm
+		conf.Signer = m.c.Signer
+		conf.Old = prev.Nodes
+		conf.OldThreshold = prev.Threshold
+		conf.Commits = commits
+		conf.Clock = m.c.Clock
+		result, err = dkg.Handover(m.ctx, conf, board)
+	}
+	if err != nil {
+		return err
+	}
+	if !member {
+		return nil
+	}
+
+	node, err := m.c.NewNode(&next.Committee, index)
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	node.SetResult(result)
+	// a validator restarted without the share could not resume the epoch
+	if err := node.SaveResult(); err != nil {
+		node.Close()
+		return fmt.Errorf("failed to save share: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		node.Close()
+		return ErrClosed
+	}
+	next.node = node
+	return nil
+}
+
+// Generate runs the round on behalf of client with the committee of its
+// epoch, see dkg.Node.GenerateRound. The output carries the epoch.
+func (m *Manager) Generate(ctx context.Context, client string, round uint64) (*dkg.Output, error) {
+	epoch, node, err := m.Node(round)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := node.GenerateRound(ctx, client, round)
+	if err != nil {
+		return nil, fmt.Errorf("epoch %d: %w", epoch, err)
+	}
+	out.Epoch = epoch
+	return out, nil
+}
+
+// Node returns the epoch of round and the node of the validator signing it.
+func (m *Manager) Node(round uint64) (uint64, *dkg.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, nil, ErrClosed
+	}
+
+	var e *epoch
+	for _, candidate := range m.epochs {
+		if candidate.FirstRound <= round {
+			e = candidate
+		}
+	}
+	if e == nil {
+		return 0, nil, fmt.Errorf("%w: round %d", ErrUnknownEpoch, round)
+	}
+	if err := e.signing(); err != nil {
+		return e.Epoch, nil, err
+	}
+	return e.Epoch, e.node, nil
+}
+
+// EpochNode returns the node of the validator signing the rounds of epoch.
+func (m *Manager) EpochNode(epoch uint64) (*dkg.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	for _, e := range m.epochs {
+		if e.Epoch != epoch {
+			continue
+		}
+		if err := e.signing(); err != nil {
+			return nil, err
+		}
+		return e.node, nil
+	}
+	return nil, fmt.Errorf("%w: epoch %d", ErrUnknownEpoch, epoch)
+}
+
+// signing returns nil if the validator signs the rounds of e. The manager
+// lock must be held.
+func (e *epoch) signing() error {
+	switch {
+	case e.state == StatePending:
+		return fmt.Errorf("%w: epoch %d", ErrHandoverPending, e.Epoch)
+	case e.state == StateFailed:
+		return fmt.Errorf("%w: epoch %d: %w", ErrHandoverFailed, e.Epoch, e.err)
+	case e.state == StateRetired:
+		return fmt.Errorf("%w: epoch %d", ErrRetired, e.Epoch)
+	case e.node == nil:
+		return fmt.Errorf("%w: epoch %d", ErrNotMember, e.Epoch)
+	}
+	return nil
+}
+
+// Status of an epoch, as reported by Statuses.
+type Status struct {
+	Epoch      uint64 `json:"epoch"`
+	FirstRound uint64 `json:"first_round"`
+	Size       int    `json:"size"`
+	Threshold  int    `json:"threshold"`
+	State      string `json:"state"`
+	// Member is set while the validator signs the rounds of the epoch.
+	Member bool `json:"member"`
+	// PublicKey is the hex group public key, known to members.
+	PublicKey string `json:"public_key,omitempty"`
+	// Commits is the hex public polynomial of the key in the epoch, known
+	// to members, which validators joining the next committee need.
+	Commits []string `json:"commits,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Statuses returns the epochs in order, for verifiers to pick the committee
+// of a round.
+func (m *Manager) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]Status, 0, len(m.epochs))
+	for _, e := range m.epochs {
+		s := Status{
+			Epoch:      e.Epoch,
+			FirstRound: e.FirstRound,
+			Size:       len(e.Nodes),
+			Threshold:  e.Threshold,
+			State:      e.state,
+			Member:     e.node != nil,
+		}
+		if e.node != nil {
+			for _, commit := range e.node.Commits() {
+				if data, err := commit.MarshalBinary(); err == nil {
+					s.Commits = append(s.Commits, hex.EncodeToString(data))
+				}
+			}
+			if len(s.Commits) > 0 {
+				s.PublicKey = s.Commits[0]
+			}
+		}
+		if e.err != nil {
+			s.Error = e.err.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// Close stops the handovers and closes the nodes of every epoch.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, e := range m.epochs {
+		if e.node == nil {
+			continue
+		}
+		if err := e.node.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("epoch %d: %w", e.Epoch, err))
+		}
+		e.node = nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,534 @@
+package epoch
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/sim"
+	"random-network-poc/timelock"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+var link = sim.Link{Latency: 50 * time.Millisecond}
+
+// cluster is a set of validators moving from committee to committee over
+// simulated networks, on virtual time: one per epoch for the rounds, and one
+// of every validator for the handovers.
+type cluster struct {
+	t         *testing.T
+	scheme    *crypto.Scheme
+	clock     *clock.Virtual
+	longterms []kyber.Scalar
+	nonce     []byte
+	// handover connects every validator, indexed as longterms
+	handover *sim.Network
+	managers []*Manager
+	// schedule has every round of the tests due once the first DKG ran
+	schedule *timelock.Schedule
+	// key is the group key of the first DKG
+	key kyber.Point
+
+	mu sync.Mutex
+	// nets run the rounds of each epoch
+	nets map[uint64]*sim.Network
+	// saved holds the epochs saved by each validator, and shares the
+	// results saved by its node of each epoch
+	saved  map[int][]Committee
+	shares map[int]map[uint64]*pedersen_dkg.Result
+}
+
+func newCluster(t *testing.T, size int) *cluster {
+	c := &cluster{
+		t:      t,
+		scheme: crypto.DefaultScheme(),
+		clock:  clock.NewVirtual(time.Unix(0, 0)),
+		nonce:  pedersen_dkg.GetNonce(),
+		nets:   make(map[uint64]*sim.Network),
+		saved:  make(map[int][]Committee),
+		shares: make(map[int]map[uint64]*pedersen_dkg.Result),
+	}
+	c.schedule = &timelock.Schedule{Genesis: c.clock.Now(), Period: time.Millisecond}
+	c.handover = sim.New(c.clock, size, 1, link)
+	for v := range size {
+		c.longterms = append(c.longterms, c.scheme.KeyGroup.Scalar().Pick(random.New()))
+		c.shares[v] = make(map[uint64]*pedersen_dkg.Result)
+	}
+	return c
+}
+
+// committee returns the committee of the validators, in index order.
+func (c *cluster) committee(epoch, firstRound uint64, threshold int, validators ...int) Committee {
+	committee := Committee{Epoch: epoch, FirstRound: firstRound, Threshold: threshold}
+	for index, v := range validators {
+		committee.Nodes = append(committee.Nodes, pedersen_dkg.Node{
+			Index:  uint32(index),
+			Public: c.scheme.KeyGroup.Point().Mul(c.longterms[v], nil),
+		})
+	}
+	return committee
+}
+
+// newNode returns the node of validator v at index of a committee, over net.
+func (c *cluster) newNode(net *sim.Network, committee *Committee, v int, index uint32, nonce []byte) *dkg.Node {
+	node, err := dkg.NewNode(c.t.Context(), &dkg.Config{
+		Index:        index,
+		LongtermKey:  c.longterms[v],
+		Nonce:        nonce,
+		Scheme:       c.scheme,
+		Nodes:        committee.Nodes,
+		Threshold:    committee.Threshold,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Clock:        c.clock,
+		RoundTimeout: 5 * time.Second,
+		Transport:    net.Transport(int(index)),
+		Epoch:        committee.Epoch,
+		Schedule:     c.schedule,
+		SaveResult: func(result *pedersen_dkg.Result, heldBySigner bool) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.shares[v][committee.Epoch] = result
+			return nil
+		},
+	}, net.Board(int(index)), nil, nil)
+	require.NoError(c.t, err)
+	return node
+}
+
+// start runs the DKG of the first committee and the managers of every
+// validator.
+func (c *cluster) start(first Committee) {
+	net := sim.New(c.clock, len(first.Nodes), 1, link)
+	c.nets[first.Epoch] = net
+	nodes := make(map[int]*dkg.Node)
+	for index, node := range first.Nodes {
+		for v, longterm := range c.longterms {
+			if node.Public.Equal(c.scheme.KeyGroup.Point().Mul(longterm, nil)) {
+				nodes[v] = c.newNode(net, &first, v, uint32(index), c.nonce)
+				nodes[v].StartDKG()
+			}
+		}
+	}
+	net.Run(3 * time.Second)
+
+	for _, n := range nodes {
+		result, err := n.WaitDKG()
+		require.NoError(c.t, err)
+		c.key = result.Key.Commits[0]
+	}
+
+	for v := range c.longterms {
+		m, err := New(c.t.Context(), c.config(v), first, nodes[v])
+		require.NoError(c.t, err)
+		c.managers = append(c.managers, m)
+	}
+	c.t.Cleanup(func() {
+		for _, m := range c.managers {
+			require.NoError(c.t, m.Close())
+		}
+	})
+}
+
+// config returns the manager configuration of validator v. Every epoch runs
+// its rounds over its own network, and validators outside the first
+// committee know the group key it generated.
+func (c *cluster) config(v int) *Config {
+	return &Config{
+		Scheme:   c.scheme,
+		Longterm: c.longterms[v],
+		Nonce:    c.nonce,
+		Clock:    c.clock,
+		GroupKey: c.key,
+		NewNode: func(committee *Committee, index uint32) (*dkg.Node, error) {
+			return c.newNode(c.net(committee), committee, v, index, dkg.HandoverNonce(c.nonce, committee.Epoch)), nil
+		},
+		Board: func(prev, next *Committee) (pedersen_dkg.Board, error) {
+			return c.handover.Board(v), nil
+		},
+		Save: func(epochs []Committee) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.saved[v] = epochs
+			return nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// net returns the network of the rounds of committee.
+func (c *cluster) net(committee *Committee) *sim.Network {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	net, ok := c.nets[committee.Epoch]
+	if !ok {
+		net = sim.New(c.clock, len(committee.Nodes), 1, link)
+		c.nets[committee.Epoch] = net
+	}
+	return net
+}
+
+// savedOf returns the epochs validator v saved last.
+func (c *cluster) savedOf(v int) []Committee {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.saved[v]
+}
+
+// restart closes the manager of validator v and restores it from the epochs
+// it saved, with a new node holding the saved share in each it is a member
+// of.
+func (c *cluster) restart(v int) {
+	require.NoError(c.t, c.managers[v].Close())
+
+	saved := c.savedOf(v)
+	c.mu.Lock()
+	shares := maps.Clone(c.shares[v])
+	c.mu.Unlock()
+
+	public := c.scheme.KeyGroup.Point().Mul(c.longterms[v], nil)
+	nodes := make([]*dkg.Node, len(saved))
+	for i := range saved {
+		committee := &saved[i]
+		for _, node := range committee.Nodes {
+			if !node.Public.Equal(public) {
+				continue
+			}
+			nonce := c.nonce
+			if committee.Epoch > 0 {
+				nonce = dkg.HandoverNonce(c.nonce, committee.Epoch)
+			}
+			nodes[i] = c.newNode(c.net(committee), committee, v, node.Index, nonce)
+			nodes[i].SetResult(shares[committee.Epoch])
+		}
+	}
+
+	m, err := Restore(c.t.Context(), c.config(v), saved, nodes)
+	require.NoError(c.t, err)
+	c.managers[v] = m
+}
+
+// approve returns the approvals of next by every validator, of which the
+// manager counts those of the current committee.
+func (c *cluster) approve(next Committee) []Approval {
+	var approvals []Approval
+	for _, longterm := range c.longterms {
+		a, err := Approve(c.scheme, longterm, &next)
+		require.NoError(c.t, err)
+		approvals = append(approvals, a)
+	}
+	return approvals
+}
+
+// announce announces next to every validator and waits for the handovers to
+// start.
+func (c *cluster) announce(next Committee, commits []kyber.Point) {
+	// lets the rounds in flight end, so only the handovers schedule timers
+	c.clock.Advance(time.Second)
+	pending := c.clock.Pending()
+	var handovers int
+	for _, m := range c.managers {
+		m.mu.Lock()
+		prev := m.epochs[len(m.epochs)-1]
+		if prev.Epoch == next.Epoch {
+			prev = m.epochs[len(m.epochs)-2]
+		}
+		if _, member := m.indexIn(&next); member || prev.node != nil {
+			handovers++
+		}
+		m.mu.Unlock()
+		require.NoError(c.t, m.Announce(next, commits, c.approve(next)))
+	}
+	// the phases of every handover are scheduled before the clock moves
+	require.Eventually(c.t, func() bool {
+		return c.clock.Pending() >= pending+handovers
+	}, 10*time.Second, time.Millisecond)
+}
+
+// finish runs the handover to its end and waits for every manager to install
+// the outcome.
+func (c *cluster) finish() {
+	c.handover.Run(3 * time.Second)
+	require.Eventually(c.t, func() bool {
+		for _, m := range c.managers {
+			if m.Statuses()[len(m.Statuses())-1].State == StatePending {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, time.Millisecond)
+}
+
+// generate runs a round on m, moving the clock until it ends.
+func (c *cluster) generate(m *Manager, round uint64) (*dkg.Output, error) {
+	type outcome struct {
+		out *dkg.Output
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		out, err := m.Generate(context.Background(), "test", round)
+		done <- outcome{out, err}
+	}()
+	for {
+		select {
+		case o := <-done:
+			return o.out, o.err
+		case <-time.After(time.Millisecond):
+			c.clock.Advance(10 * time.Millisecond)
+		}
+	}
+}
+
+// verify checks the signature of out over its round with public.
+func (c *cluster) verify(public kyber.Point, out *dkg.Output, round uint64) {
+	sig, err := hex.DecodeString(out.Signature)
+	require.NoError(c.t, err)
+	scheme, err := c.scheme.WithPurpose(crypto.PurposeRound)
+	require.NoError(c.t, err)
+	require.NoError(c.t, scheme.SigScheme.Verify(public, timelock.RoundMessage(round), sig))
+}
+
+// Validator 0 leaves and validator 4 joins while rounds go on, and the group
+// key survives the handover.
+func TestHandover(t *testing.T) {
+	c := newCluster(t, 5)
+	c.start(c.committee(0, 1, 3, 0, 1, 2, 3))
+
+	out, err := c.generate(c.managers[0], 1)
+	require.NoError(t, err)
+	require.Zero(t, out.Epoch)
+	_, node, err := c.managers[0].Node(1)
+	require.NoError(t, err)
+	public := node.Commits()[0]
+	c.verify(public, out, 1)
+
+	_, err = c.managers[4].Generate(t.Context(), "test", 1)
+	require.ErrorIs(t, err, ErrNotMember)
+	_, err = c.managers[0].Generate(t.Context(), "test", 0)
+	require.ErrorIs(t, err, ErrUnknownEpoch)
+
+	c.announce(c.committee(1, 10, 3, 1, 2, 3, 4), node.Commits())
+
+	// the old committee keeps signing during the handover, the new one only
+	// once it ended
+	_, _, err = c.managers[4].Node(10)
+	require.ErrorIs(t, err, ErrHandoverPending)
+	out, err = c.generate(c.managers[1], 2)
+	require.NoError(t, err)
+	require.Zero(t, out.Epoch)
+	require.Equal(t, StatePending, c.managers[1].Statuses()[1].State)
+
+	c.finish()
+	for v, m := range c.managers {
+		require.Equal(t, StateActive, m.Statuses()[1].State, "validator %d: %s", v, m.Statuses()[1].Error)
+		require.Equal(t, v != 0, m.Statuses()[1].Member, "validator %d", v)
+	}
+
+	// the boundary: round 9 is the last of epoch 0, round 10 the first of
+	// epoch 1, both under the same key
+	out, err = c.generate(c.managers[0], 9)
+	require.NoError(t, err)
+	require.Zero(t, out.Epoch)
+	c.verify(public, out, 9)
+
+	out, err = c.generate(c.managers[4], 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), out.Epoch)
+	c.verify(public, out, 10)
+
+	_, err = c.managers[0].Generate(t.Context(), "test", 10)
+	require.ErrorIs(t, err, ErrNotMember)
+
+	statuses := c.managers[4].Statuses()
+	require.Equal(t, []Status{
+		{Epoch: 0, FirstRound: 1, Size: 4, Threshold: 3, State: StateActive},
+		{Epoch: 1, FirstRound: 10, Size: 4, Threshold: 3, State: StateActive, Member: true, PublicKey: statuses[1].PublicKey, Commits: statuses[1].Commits},
+	}, statuses)
+	pub, err := public.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(pub), statuses[1].PublicKey)
+	require.Len(t, statuses[1].Commits, 3)
+	require.Equal(t, statuses[1].PublicKey, statuses[1].Commits[0])
+
+	node, err = c.managers[4].EpochNode(1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), node.Status().Epoch)
+	_, err = c.managers[4].EpochNode(0)
+	require.ErrorIs(t, err, ErrNotMember)
+	_, err = c.managers[4].EpochNode(2)
+	require.ErrorIs(t, err, ErrUnknownEpoch)
+
+	// the next announcement retires epoch 0
+	_, node, err = c.managers[1].Node(10)
+	require.NoError(t, err)
+	c.announce(c.committee(2, 20, 2, 4, 3, 2), node.Commits())
+	_, _, err = c.managers[1].Node(9)
+	require.ErrorIs(t, err, ErrRetired)
+	require.Equal(t, StateRetired, c.managers[1].Statuses()[0].State)
+}
+
+// A handover cut off from the network fails, rounds of its epoch with it, and
+// succeeds once announced again.
+func TestHandoverRetry(t *testing.T) {
+	c := newCluster(t, 4)
+	c.start(c.committee(0, 1, 2, 0, 1, 2))
+	_, node, err := c.managers[0].Node(1)
+	require.NoError(t, err)
+	next := c.committee(1, 5, 2, 1, 2, 3)
+
+	c.handover.Partition()
+	c.announce(next, node.Commits())
+	c.finish()
+	_, err = c.managers[3].Generate(t.Context(), "test", 5)
+	require.ErrorIs(t, err, ErrHandoverFailed)
+	// a validator leaving cannot tell
+	require.Equal(t, StateActive, c.managers[0].Statuses()[1].State)
+
+	require.ErrorIs(t, c.managers[3].Announce(c.committee(2, 8, 2, 1, 2, 3), nil, nil), ErrHandoverFailed)
+
+	c.handover.Heal()
+	c.announce(next, node.Commits())
+	c.finish()
+	out, err := c.generate(c.managers[3], 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), out.Epoch)
+	c.verify(node.Commits()[0], out, 5)
+}
+
+// Validators restarted from the epochs and shares they saved sign the rounds
+// of their epochs again, and hand their share over to the next committee.
+func TestRestart(t *testing.T) {
+	c := newCluster(t, 4)
+	c.start(c.committee(0, 1, 2, 0, 1, 2))
+	_, node, err := c.managers[0].Node(1)
+	require.NoError(t, err)
+	commits := node.Commits()
+
+	c.announce(c.committee(1, 10, 2, 1, 2, 3), commits)
+	c.finish()
+
+	// validator 0 left, validator 3 joined
+	before := make([][]Status, len(c.managers))
+	for v, m := range c.managers {
+		before[v] = m.Statuses()
+		require.Len(t, c.savedOf(v), 2, "validator %d", v)
+	}
+	c.restart(0)
+	c.restart(3)
+	for v, m := range c.managers {
+		require.Equal(t, before[v], m.Statuses(), "validator %d", v)
+	}
+
+	out, err := c.generate(c.managers[0], 9)
+	require.NoError(t, err)
+	c.verify(c.key, out, 9)
+	out, err = c.generate(c.managers[3], 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), out.Epoch)
+	c.verify(c.key, out, 10)
+
+	// the next announcement retires epoch 0, and its handover starts from
+	// the restored share
+	c.announce(c.committee(2, 20, 2, 3, 2), commits)
+	require.Equal(t, []Committee{c.committee(1, 10, 2, 1, 2, 3)}, c.savedOf(3))
+	c.finish()
+	require.Len(t, c.savedOf(3), 2)
+	c.restart(3)
+	out, err = c.generate(c.managers[3], 20)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), out.Epoch)
+	c.verify(c.key, out, 20)
+
+	// the saved epochs follow one another
+	first, second := c.committee(0, 1, 2, 0, 1, 2), c.committee(1, 10, 2, 1, 2, 3)
+	for name, epochs := range map[string][]Committee{
+		"none":         nil,
+		"gap":          {first, c.committee(2, 10, 2, 1, 2, 3)},
+		"round before": {first, c.committee(1, 1, 2, 1, 2, 3)},
+		"nodes":        {first, second},
+	} {
+		_, err := Restore(t.Context(), c.config(3), epochs, make([]*dkg.Node, len(epochs)))
+		require.ErrorIs(t, err, ErrInvalidCommittee, name)
+	}
+}
+
+func TestAnnounce(t *testing.T) {
+	c := newCluster(t, 3)
+	first := c.committee(3, 100, 2, 0, 1)
+	m, err := New(t.Context(), &Config{Scheme: c.scheme, Longterm: c.longterms[2]}, first, nil)
+	require.NoError(t, err)
+	defer m.Close()
+
+	_, err = New(t.Context(), &Config{Scheme: c.scheme, Longterm: c.longterms[0]}, first, nil)
+	require.ErrorIs(t, err, ErrInvalidCommittee)
+
+	for name, next := range map[string]Committee{
+		"no node":         {Epoch: 4, FirstRound: 200, Threshold: 1},
+		"threshold":       c.committee(4, 200, 3, 0, 1),
+		"epoch gap":       c.committee(5, 200, 2, 0, 1),
+		"round before":    c.committee(4, 100, 2, 0, 1),
+		"joining without": c.committee(4, 200, 2, 0, 2),
+	} {
+		require.ErrorIs(t, m.Announce(next, nil, c.approve(next)), ErrInvalidCommittee, name)
+	}
+	require.Empty(t, c.clock.Pending())
+
+	// a threshold of epoch 3 approves the very committee announced
+	next := c.committee(4, 200, 2, 0, 2)
+	other := c.committee(4, 201, 2, 0, 2)
+	approvals := c.approve(next)
+	for name, approvals := range map[string][]Approval{
+		"none":           nil,
+		"one member":     approvals[:1],
+		"repeated":       {approvals[0], approvals[0]},
+		"outsider":       {approvals[0], approvals[2]},
+		"other":          {approvals[0], c.approve(other)[1]},
+		"no public":      {approvals[0], {Signature: approvals[1].Signature}},
+		"other approver": {approvals[0], {Public: approvals[0].Public, Signature: approvals[1].Signature}},
+	} {
+		require.ErrorIs(t, m.Announce(next, nil, approvals), ErrNotApproved, name)
+	}
+
+	// a validator joining checks the commits against the group key
+	key := c.scheme.KeyGroup.Point().Pick(random.New())
+	commits := []kyber.Point{c.scheme.KeyGroup.Point().Pick(random.New())}
+	require.ErrorContains(t, m.Announce(next, commits, approvals), "needs the group key")
+	joining, err := New(t.Context(), &Config{Scheme: c.scheme, Longterm: c.longterms[2], GroupKey: key}, first, nil)
+	require.NoError(t, err)
+	defer joining.Close()
+	require.ErrorIs(t, joining.Announce(next, commits, approvals), ErrInvalidCommittee)
+	require.Empty(t, c.clock.Pending())
+
+	_, _, err = m.Node(99)
+	require.ErrorIs(t, err, ErrUnknownEpoch)
+	_, _, err = m.Node(100)
+	require.ErrorIs(t, err, ErrNotMember)
+
+	require.NoError(t, m.Close())
+	require.ErrorIs(t, m.Announce(c.committee(4, 200, 2, 0, 1), nil, nil), ErrClosed)
+}
+
+func TestUnion(t *testing.T) {
+	c := newCluster(t, 4)
+	prev, next := c.committee(0, 1, 2, 0, 1, 2), c.committee(1, 2, 2, 3, 1)
+	union := Union(&prev, &next)
+	require.Len(t, union, 4)
+	for i, v := range []int{0, 1, 2, 3} {
+		require.Equal(t, uint32(i), union[i].Index)
+		require.True(t, union[i].Public.Equal(c.scheme.KeyGroup.Point().Mul(c.longterms[v], nil)))
+	}
+}
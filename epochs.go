@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/envelope"
+	"random-network-poc/epoch"
+	"random-network-poc/p2p"
+	"random-network-poc/rng"
+
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// handoverDiscovery bounds the wait for the members of both committees to
+// join the topic of a handover. The handover starts regardless after it.
+const handoverDiscovery = time.Minute
+
+// epochNet creates the nodes and handover boards of the epochs of -epochs
+// over the libp2p host of the validator. Each epoch runs its rounds on the
+// topics of group epoch-<n>, and its handover on the DKG topic of group
+// handover-<n>, see rng.GroupTopic.
+//
+// The epochs and their shares are saved next to the share of epoch 0, see
+// epochPath, and resumed by a restarted validator.
+type epochNet struct {
+	ctx      context.Context
+	scheme   *crypto.Scheme
+	longterm kyber.Scalar
+	p2p      *p2p.NodeP2P
+	// conf holds the settings shared by the nodes of every epoch.
+	conf dkg.Config
+	// share is the share file of epoch 0, empty keeps the epochs in memory
+	share string
+	log   *slog.Logger
+
+	mu sync.Mutex
+	// boards of the epoch nodes, left once the validator stops
+	boards map[uint64]*dkg.BoardP2P
+	// saved are the epochs saved last, whose shares are removed once they
+	// retire
+	saved []uint64
+}
+
+// savedEpoch is an entry of the epochs file, see epoch.Config.Save.
+type savedEpoch struct {
+	Epoch      uint64 `json:"epoch"`
+	FirstRound uint64 `json:"first_round"`
+	// Committee is the hex public keys of the committee, in index order.
+	Committee []string `json:"committee"`
+	Threshold int      `json:"threshold"`
+}
+
+func newEpochNet(ctx context.Context, scheme *crypto.Scheme, longterm kyber.Scalar, p2pNode *p2p.NodeP2P, conf dkg.Config, share string, logger *slog.Logger) *epochNet {
+	return &epochNet{
+		ctx:      ctx,
+		scheme:   scheme,
+		longterm: longterm,
+		p2p:      p2pNode,
+		conf:     conf,
+		share:    share,
+		log:      logger,
+		boards:   make(map[uint64]*dkg.BoardP2P),
+	}
+}
+
+// epochPath returns the file of name next to the share file of epoch 0:
+// share-<name>.json for share.json.
+func epochPath(share, name string) string {
+	ext := filepath.Ext(share)
+	return strings.TrimSuffix(share, ext) + "-" + name + ext
+}
+
+// sharePath returns the share file of an epoch after the first.
+func (e *epochNet) sharePath(epoch uint64) string {
+	return epochPath(e.share, fmt.Sprintf("epoch-%d", epoch))
+}
+
+// config returns the epoch manager configuration of the validator.
+func (e *epochNet) config(nonce []byte) *epoch.Config {
+	conf := &epoch.Config{
+		Scheme:   e.scheme,
+		Longterm: e.longterm,
+		Nonce:    nonce,
+		NewNode:  e.newNode,
+		Board:    e.board,
+		Logger:   e.conf.Logger,
+		Metrics:  e.conf.Metrics,
+	}
+	if e.share != "" {
+		conf.Save = e.save
+	}
+	return conf
+}
+
+// save writes the epochs file and removes the shares of the epochs retired
+// since the last save, see epoch.Config.Save. The share of epoch 0 stays in
+// -share.
+func (e *epochNet) save(epochs []epoch.Committee) error {
+	saved := make([]savedEpoch, len(epochs))
+	numbers := make([]uint64, len(epochs))
+	for i, c := range epochs {
+		keys := make([]string, len(c.Nodes))
+		for j, node := range c.Nodes {
+			data, err := node.Public.MarshalBinary()
+			if err != nil {
+				return fmt.Errorf("failed to marshal public key: %w", err)
+			}
+			keys[j] = hex.EncodeToString(data)
+		}
+		saved[i] = savedEpoch{Epoch: c.Epoch, FirstRound: c.FirstRound, Committee: keys, Threshold: c.Threshold}
+		numbers[i] = c.Epoch
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// a crash leaves either the previous epochs or these
+	path := epochPath(e.share, "epochs")
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, n := range e.saved {
+		if n == 0 || slices.Contains(numbers, n) {
+			continue
+		}
+		if err := os.Remove(e.sharePath(n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			e.log.Warn("Failed to remove share of retired epoch", "epoch", n, "err", err)
+		}
+	}
+	e.saved = numbers
+	return nil
+}
+
+// loadEpochs returns the epochs saved next to the share file of epoch 0, nil
+// if none were.
+func loadEpochs(share string, scheme *crypto.Scheme) ([]epoch.Committee, error) {
+	if share == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(epochPath(share, "epochs"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read epochs: %w", err)
+	}
+	var saved []savedEpoch
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode epochs: %w", err)
+	}
+
+	epochs := make([]epoch.Committee, len(saved))
+	for i, s := range saved {
+		nodes, err := dkg.ParseNodes(scheme, s.Committee)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode committee of epoch %d: %w", s.Epoch, err)
+		}
+		epochs[i] = epoch.Committee{Epoch: s.Epoch, FirstRound: s.FirstRound, Nodes: nodes, Threshold: s.Threshold}
+	}
+	return epochs, nil
+}
+
+// restore resumes the saved epochs, with the node of epoch 0 created from the
+// flags, nil outside of it, and a node holding the saved share in every later
+// epoch the validator is a member of.
+func (e *epochNet) restore(conf *epoch.Config, epochs []epoch.Committee, first *dkg.Node) (*epoch.Manager, error) {
+	public := e.scheme.KeyGroup.Point().Mul(e.longterm, nil)
+	nodes := make([]*dkg.Node, len(epochs))
+	closeNodes := func() {
+		for _, node := range nodes {
+			if node != nil && node != first {
+				node.Close()
+			}
+		}
+	}
+
+	for i := range epochs {
+		c := &epochs[i]
+		index, member := indexOf(c.Nodes, public)
+		switch {
+		case !member:
+			continue
+		case c.Epoch == 0:
+			nodes[i] = first
+			continue
+		}
+
+		path := e.sharePath(c.Epoch)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			closeNodes()
+			return nil, fmt.Errorf("failed to read share of epoch %d: %w", c.Epoch, err)
+		}
+		result, _, err := dkg.ResultFromJSON(e.scheme, data)
+		if err != nil {
+			closeNodes()
+			return nil, fmt.Errorf("failed to decode share of epoch %d: %w", c.Epoch, err)
+		}
+		nodes[i], err = e.newNode(c, index)
+		if err != nil {
+			closeNodes()
+			return nil, err
+		}
+		nodes[i].SetResult(result)
+		e.log.Info("Loaded share", "epoch", c.Epoch, "file", path)
+	}
+
+	m, err := epoch.Restore(e.ctx, conf, epochs, nodes)
+	if err != nil {
+		closeNodes()
+		return nil, fmt.Errorf("failed to restore epochs: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, c := range epochs {
+		e.saved = append(e.saved, c.Epoch)
+	}
+	return m, nil
+}
+
+// newNode creates the node of the validator at index of the committee c, see
+// epoch.Config.NewNode.
+func (e *epochNet) newNode(c *epoch.Committee, index uint32) (*dkg.Node, error) {
+	id := fmt.Sprintf("epoch-%d", c.Epoch)
+
+	conf := e.conf
+	conf.Index = index
+	conf.Nonce = dkg.HandoverNonce(e.conf.Nonce, c.Epoch)
+	conf.Nodes = c.Nodes
+	conf.Threshold = c.Threshold
+	conf.Envelope = envelope.New(&envelope.Config{
+		Scheme:   e.scheme,
+		Index:    index,
+		Longterm: e.longterm,
+		Nodes:    c.Nodes,
+	})
+	conf.Group = id
+	conf.Epoch = c.Epoch
+	// the share of the epoch is saved next to that of epoch 0
+	conf.SaveResult = nil
+	if e.share != "" {
+		conf.SaveResult = saveResult(e.sharePath(c.Epoch))
+	}
+
+	board, err := dkg.NewGroupBoardP2P(e.ctx, e.p2p.PubSub(), e.p2p.ID(), id, e.scheme, conf.Wire, conf.Envelope, conf.Logger, conf.Metrics)
+	if err != nil {
+		return nil, err
+	}
+	node, err := dkg.NewNode(e.ctx, &conf, board, e.p2p.PubSub(), e.p2p.Host)
+	if err != nil {
+		board.Close()
+		return nil, fmt.Errorf("failed to create node of epoch %d: %w", c.Epoch, err)
+	}
+	node.StartRefresh(e.ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// a handover announced again replaces the node of its first attempt
+	if previous, ok := e.boards[c.Epoch]; ok {
+		previous.Close()
+	}
+	e.boards[c.Epoch] = board
+	return node, nil
+}
+
+// board joins the handover topic from prev to next and waits for the members
+// of both committees to join it, see epoch.Config.Board.
+func (e *epochNet) board(prev, next *epoch.Committee) (pedersen_dkg.Board, error) {
+	union := epoch.Union(prev, next)
+	public := e.scheme.KeyGroup.Point().Mul(e.longterm, nil)
+	index, ok := indexOf(union, public)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a member of epoch %d or %d", envelope.ErrUnknownSender, prev.Epoch, next.Epoch)
+	}
+
+	codec := envelope.New(&envelope.Config{
+		Scheme:   e.scheme,
+		Index:    index,
+		Longterm: e.longterm,
+		Nodes:    union,
+	})
+	id := fmt.Sprintf("handover-%d", next.Epoch)
+	board, err := dkg.NewHandoverBoardP2P(e.ctx, e.p2p.PubSub(), e.p2p.ID(), id, e.scheme, e.conf.Wire, codec, prev.Nodes, next.Nodes, e.conf.Logger, e.conf.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// deals are published once, at the start of the handover
+	topic := rng.GroupTopic(dkg.Topic, id)
+	deadline := time.Now().Add(handoverDiscovery)
+	for len(e.p2p.PubSub().ListPeers(topic)) < len(union)-1 && time.Now().Before(deadline) {
+		if !sleep(e.ctx, 100*time.Millisecond) {
+			break
+		}
+	}
+	if peers := len(e.p2p.PubSub().ListPeers(topic)); peers < len(union)-1 {
+		e.log.Warn("Starting handover without every member", "epoch", next.Epoch, "peers", peers, "members", len(union))
+	}
+	sleep(e.ctx, time.Second)
+
+	return board, nil
+}
+
+// Close leaves the topics of the epoch nodes.
+func (e *epochNet) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, board := range e.boards {
+		if err := board.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// indexOf returns the index of the node with the public key in nodes, if any.
+func indexOf(nodes []pedersen_dkg.Node, public kyber.Point) (uint32, bool) {
+	for _, node := range nodes {
+		if node.Public.Equal(public) {
+			return node.Index, true
+		}
+	}
+	return 0, false
+}
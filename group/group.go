@@ -0,0 +1,196 @@
+// Package group runs the committees a validator takes part in side by side,
+// e.g. a production and a staging committee. Each group has its own
+// committee, topics, share and beacon, and is picked by its ID.
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+// maxIDLength bounds a group ID, which ends up in topic names and URLs.
+const maxIDLength = 32
+
+var (
+	ErrInvalidID    = errors.New("invalid group ID")
+	ErrUnknownGroup = errors.New("unknown group")
+	ErrGroupExists  = errors.New("group already exists")
+	ErrClosed       = errors.New("group manager closed")
+)
+
+// ValidateID checks that id names a group: 1 to 32 lowercase letters, digits,
+// '-' or '_'.
+func ValidateID(id string) error {
+	if id == "" || len(id) > maxIDLength {
+		return fmt.Errorf("%w %q: 1 to %d characters", ErrInvalidID, id, maxIDLength)
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return fmt.Errorf("%w %q: only a-z, 0-9, '-' and '_'", ErrInvalidID, id)
+		}
+	}
+	return nil
+}
+
+// group is a committee of the validator, with the board its node runs the
+// DKG over.
+type group struct {
+	node  *dkg.Node
+	board io.Closer
+}
+
+// Manager holds the groups of a validator.
+type Manager struct {
+	mu     sync.Mutex
+	groups map[string]*group
+	closed bool
+}
+
+func NewManager() *Manager {
+	return &Manager{groups: make(map[string]*group)}
+}
+
+// Join runs the validator in the group c.Group over ps and h: it joins the
+// DKG topic of the group and creates the node of the group. c.Envelope is
+// required, the board seals with it too. The group stops once ctx is done or
+// it is removed.
+func (m *Manager) Join(ctx context.Context, c *dkg.Config, ps *pubsub.PubSub, h host.Host) (*dkg.Node, error) {
+	if err := m.check(c.Group); err != nil {
+		return nil, err
+	}
+	if c.Envelope == nil {
+		return nil, fmt.Errorf("group %s has no envelope codec", c.Group)
+	}
+
+	scheme := c.Scheme
+	if scheme == nil {
+		scheme = crypto.DefaultScheme()
+	}
+
+	board, err := dkg.NewGroupBoardP2P(ctx, ps, h.ID(), c.Group, scheme, c.Wire, c.Envelope, c.Logger, c.Metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create board of group %s: %w", c.Group, err)
+	}
+
+	node, err := dkg.NewNode(ctx, c, board, ps, h)
+	if err != nil {
+		board.Close()
+		return nil, fmt.Errorf("failed to create node of group %s: %w", c.Group, err)
+	}
+
+	if err := m.Add(node, board); err != nil {
+		node.Close()
+		board.Close()
+		return nil, err
+	}
+	return node, nil
+}
+
+// Add registers the node of a group, under node.Group. The board, nil if
+// owned elsewhere, is closed after the node.
+func (m *Manager) Add(node *dkg.Node, board io.Closer) error {
+	id := node.Group()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkLocked(id); err != nil {
+		return err
+	}
+	m.groups[id] = &group{node: node, board: board}
+	return nil
+}
+
+// check returns an error unless a group id can be added.
+func (m *Manager) check(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.checkLocked(id)
+}
+
+func (m *Manager) checkLocked(id string) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	if m.closed {
+		return ErrClosed
+	}
+	if _, ok := m.groups[id]; ok {
+		return fmt.Errorf("%w: %s", ErrGroupExists, id)
+	}
+	return nil
+}
+
+// Node returns the node of a group.
+func (m *Manager) Node(id string) (*dkg.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGroup, id)
+	}
+	return g.node, nil
+}
+
+// IDs returns the IDs of the groups, sorted.
+func (m *Manager) IDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.groups))
+	for id := range m.groups {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Remove closes a group and drops it. The other groups keep running.
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	g, ok := m.groups[id]
+	delete(m.groups, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownGroup, id)
+	}
+	return g.close()
+}
+
+// Close closes every group. Later Join and Add calls fail with ErrClosed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	groups := m.groups
+	m.groups = make(map[string]*group)
+	m.closed = true
+	m.mu.Unlock()
+
+	var errs []error
+	for id, g := range groups {
+		if err := g.close(); err != nil {
+			errs = append(errs, fmt.Errorf("group %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (g *group) close() error {
+	err := g.node.Close()
+	if g.board != nil {
+		err = errors.Join(err, g.board.Close())
+	}
+	return err
+}
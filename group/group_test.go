@@ -0,0 +1,188 @@
+package group
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/envelope"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"prod", "staging-2", "app_1", strings.Repeat("a", maxIDLength)} {
+		require.NoError(t, ValidateID(id), id)
+	}
+	for _, id := range []string{"", "Prod", "a/b", "a b", "é", strings.Repeat("a", maxIDLength+1)} {
+		require.ErrorIs(t, ValidateID(id), ErrInvalidID, id)
+	}
+}
+
+// committee is a group of the test: the hosts of its members in index order,
+// and its threshold.
+type committee struct {
+	id        string
+	hosts     []int
+	threshold int
+}
+
+// Two committees share hosts and run their DKG and rounds at the same time,
+// each with its own key.
+func TestManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const size = 3
+	mn, err := mocknet.FullMeshLinked(size)
+	require.NoError(t, err)
+	defer mn.Close()
+
+	var pss []*pubsub.PubSub
+	for _, h := range mn.Hosts() {
+		ps, err := pubsub.NewGossipSub(ctx, h)
+		require.NoError(t, err)
+		pss = append(pss, ps)
+	}
+	require.NoError(t, mn.ConnectAllButSelf())
+	// lets the connections and pubsub streams settle
+	time.Sleep(time.Second)
+
+	scheme := crypto.DefaultScheme()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// every host keeps its longterm key across committees
+	longterms := make([]kyber.Scalar, size)
+	for i := range longterms {
+		longterms[i] = scheme.KeyGroup.Scalar().Pick(random.New())
+	}
+
+	managers := make([]*Manager, size)
+	for i := range managers {
+		managers[i] = NewManager()
+		defer func() { require.NoError(t, managers[i].Close()) }()
+	}
+
+	committees := []committee{
+		{id: "prod", hosts: []int{0, 1, 2}, threshold: 2},
+		{id: "staging", hosts: []int{1, 0}, threshold: 2},
+	}
+	for _, c := range committees {
+		var nodes []pedersen_dkg.Node
+		for index, host := range c.hosts {
+			nodes = append(nodes, pedersen_dkg.Node{
+				Index:  uint32(index),
+				Public: scheme.KeyGroup.Point().Mul(longterms[host], nil),
+			})
+		}
+		nonce := pedersen_dkg.GetNonce()
+
+		for index, host := range c.hosts {
+			_, err := managers[host].Join(ctx, &dkg.Config{
+				Index:       uint32(index),
+				LongtermKey: longterms[host],
+				Nonce:       nonce,
+				Scheme:      scheme,
+				Nodes:       nodes,
+				Threshold:   c.threshold,
+				Envelope: envelope.New(&envelope.Config{
+					Scheme:   scheme,
+					Index:    uint32(index),
+					Longterm: longterms[host],
+					Nodes:    nodes,
+				}),
+				Logger: logger,
+				Group:  c.id,
+			}, pss[host], mn.Hosts()[host])
+			require.NoError(t, err)
+		}
+	}
+
+	require.Equal(t, []string{"prod", "staging"}, managers[0].IDs())
+	require.Equal(t, []string{"prod"}, managers[2].IDs())
+
+	node := func(host int, id string) *dkg.Node {
+		node, err := managers[host].Node(id)
+		require.NoError(t, err)
+		return node
+	}
+
+	require.Eventually(t, func() bool {
+		for _, c := range committees {
+			for _, host := range c.hosts {
+				for _, peers := range node(host, c.id).Status().Peers {
+					if peers != len(c.hosts)-1 {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	// lets gossipsub build its mesh before the first publish
+	time.Sleep(time.Second)
+
+	for _, c := range committees {
+		for _, host := range c.hosts {
+			node(host, c.id).StartDKG()
+		}
+	}
+	public := make(map[string]kyber.Point)
+	for _, c := range committees {
+		for _, host := range c.hosts {
+			result, err := node(host, c.id).WaitDKG()
+			require.NoError(t, err, "%s on host %d", c.id, host)
+			require.Len(t, result.QUAL, len(c.hosts))
+			public[c.id] = result.Key.Public()
+		}
+	}
+	require.False(t, public["prod"].Equal(public["staging"]))
+
+	generate := func(id string, round int) *dkg.Output {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		out, err := node(0, id).Generate(ctx, "test", []byte(fmt.Sprintf("round-%d", round)))
+		require.NoError(t, err, id)
+		return out
+	}
+
+	// the same input gives each group its own beacon, verified with the key
+	// of the group only
+	verify := func(id string, out *dkg.Output) error {
+		sig, err := hex.DecodeString(out.Signature)
+		require.NoError(t, err)
+		return scheme.SigScheme.Verify(public[id], []byte("round-1"), sig)
+	}
+	prod, staging := generate("prod", 1), generate("staging", 1)
+	require.NotEqual(t, prod.Randomness, staging.Randomness)
+	require.NoError(t, verify("prod", prod))
+	require.NoError(t, verify("staging", staging))
+	require.Error(t, verify("staging", prod))
+
+	_, err = managers[0].Join(ctx, &dkg.Config{Group: "prod"}, pss[0], mn.Hosts()[0])
+	require.ErrorIs(t, err, ErrGroupExists)
+	_, err = managers[0].Node("test")
+	require.ErrorIs(t, err, ErrUnknownGroup)
+
+	// a group leaves without the others noticing
+	require.NoError(t, managers[0].Remove("staging"))
+	require.ErrorIs(t, managers[0].Remove("staging"), ErrUnknownGroup)
+	require.Equal(t, []string{"prod"}, managers[0].IDs())
+	generate("prod", 2)
+
+	require.NoError(t, managers[2].Close())
+	_, err = managers[2].Join(ctx, &dkg.Config{Group: "staging"}, pss[2], mn.Hosts()[2])
+	require.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/group"
+
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// groupFile is an entry of the -groups file: a committee the validator takes
+// part in, with the flags of a single committee.
+type groupFile struct {
+	ID    string `json:"id"`
+	Index uint32 `json:"index"`
+	Nonce string `json:"nonce"`
+	// Committee or CommitteeFile, as -committee and -committee-file.
+	Committee     []string `json:"committee,omitempty"`
+	CommitteeFile string   `json:"committee_file,omitempty"`
+	// Threshold defaults to the package threshold of dkg.
+	Threshold int    `json:"threshold,omitempty"`
+	Share     string `json:"share,omitempty"`
+}
+
+// groupSpec is a committee the validator runs: the default group of the
+// flags, or a group of the -groups file.
+type groupSpec struct {
+	id        string
+	index     uint32
+	nonce     []byte
+	nodes     []pedersen_dkg.Node
+	threshold int
+	share     string
+	// loaded is the share of a previous DKG, see load, and loadedHeld is
+	// set once its share is held by the signer only.
+	loaded     *pedersen_dkg.Result
+	loadedHeld bool
+}
+
+// readGroups returns the groups of -groups, or the default group of the
+// flags without it.
+func readGroups(f *nodeFlags, scheme *crypto.Scheme) ([]*groupSpec, error) {
+	if *f.groups == "" {
+		nonce, err := dkg.HexToBytes(*f.nonce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode nonce: %w", err)
+		}
+
+		keys := strings.Split(*f.committee, ",")
+		if *f.committee == "" {
+			keys = nil
+		}
+		if *f.committeeFile != "" {
+			keys, err = readCommitteeFile(*f.committeeFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read committee: %w", err)
+			}
+		}
+		nodes, err := parseCommittee(scheme, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*groupSpec{{index: uint32(*f.index), nonce: nonce, nodes: nodes, share: *f.share}}, nil
+	}
+
+	data, err := os.ReadFile(*f.groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups: %w", err)
+	}
+	var files []groupFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("groups file lists no group")
+	}
+
+	var specs []*groupSpec
+	seen := make(map[string]bool)
+	for _, g := range files {
+		if err := group.ValidateID(g.ID); err != nil {
+			return nil, err
+		}
+		if seen[g.ID] {
+			return nil, fmt.Errorf("%w: %s", group.ErrGroupExists, g.ID)
+		}
+		seen[g.ID] = true
+
+		nonce, err := dkg.HexToBytes(g.Nonce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode nonce of group %s: %w", g.ID, err)
+		}
+
+		keys := g.Committee
+		if g.CommitteeFile != "" {
+			if keys != nil {
+				return nil, fmt.Errorf("group %s has both a committee and a committee file", g.ID)
+			}
+			keys, err = readCommitteeFile(g.CommitteeFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read committee of group %s: %w", g.ID, err)
+			}
+		}
+		nodes, err := parseCommittee(scheme, keys)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", g.ID, err)
+		}
+
+		specs = append(specs, &groupSpec{
+			id:        g.ID,
+			index:     g.Index,
+			nonce:     nonce,
+			nodes:     nodes,
+			threshold: g.Threshold,
+			share:     g.Share,
+		})
+	}
+	return specs, nil
+}
+
+// readCommitteeFile reads the committee public keys of a file, one per line
+// in index order. Blank lines and # comments are skipped.
+func readCommitteeFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+// parseCommittee parses the committee public keys, nil for the built-in
+// committee.
+func parseCommittee(scheme *crypto.Scheme, keys []string) ([]pedersen_dkg.Node, error) {
+	if keys == nil {
+		if scheme.Name != crypto.DefaultSchemeName {
+			return nil, fmt.Errorf("scheme %s has no built-in committee, -committee or -committee-file is required", scheme.Name)
+		}
+		return dkg.Nodes, nil
+	}
+
+	nodes, err := dkg.ParseNodes(scheme, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse committee: %w", err)
+	}
+	return nodes, nil
+}
+
+// load reads the share of a previous DKG, if any.
+func (s *groupSpec) load(scheme *crypto.Scheme) error {
+	if s.share == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.share)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read share: %w", err)
+	}
+
+	s.loaded, s.loadedHeld, err = dkg.ResultFromJSON(scheme, data)
+	if err != nil {
+		return fmt.Errorf("failed to decode share: %w", err)
+	}
+	return nil
+}
+
+// save writes the share after the DKG and every refresh, see
+// dkg.Config.SaveResult. Nil without a share file.
+func (s *groupSpec) save() func(*pedersen_dkg.Result, bool) error {
+	if s.share == "" {
+		return nil
+	}
+	return saveResult(s.share)
+}
+
+// saveResult returns the dkg.Config.SaveResult writing the share to path.
+func saveResult(path string) func(*pedersen_dkg.Result, bool) error {
+	return func(result *pedersen_dkg.Result, heldBySigner bool) error {
+		data, err := dkg.ResultToJSON(result, heldBySigner)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o600)
+	}
+}
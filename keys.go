@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/keystore"
+	"random-network-poc/signer"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// passwordEnv holds the keystore password when no password file is given.
+const passwordEnv = "RNN_KEYSTORE_PASSWORD"
+
+// readPassword returns the content of file without its trailing newline, or
+// the value of passwordEnv without a file.
+func readPassword(file string) ([]byte, error) {
+	var password []byte
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		password = bytes.TrimRight(data, "\r\n")
+	} else {
+		password = []byte(os.Getenv(passwordEnv))
+	}
+
+	if len(password) == 0 {
+		return nil, fmt.Errorf("keystore password is required, set -password-file or $%s", passwordEnv)
+	}
+	return password, nil
+}
+
+// keys runs the keystore subcommands.
+func keys(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+
+	switch args[0] {
+	case "create":
+		keystoreCreate("keystore create", args[1:])
+	case "import":
+		keystoreImport(args[1:])
+	case "export":
+		keystoreExport(args[1:])
+	default:
+		usage()
+	}
+}
+
+type keystoreFlags struct {
+	out          *string
+	scheme       *string
+	passwordFile *string
+	light        *bool
+}
+
+func newKeystoreFlags(fs *flag.FlagSet) *keystoreFlags {
+	return &keystoreFlags{
+		out:          fs.String("out", "keystore.json", "Keystore file to create"),
+		scheme:       fs.String("scheme", crypto.DefaultSchemeName, "Cryptographic scheme: bn256-g1, bn254-g1, bls12381-g1 or bls12381-g2"),
+		passwordFile: fs.String("password-file", "", "File holding the keystore password (defaults to $"+passwordEnv+")"),
+		light:        fs.Bool("light", false, "Use cheap scrypt parameters, for local networks only"),
+	}
+}
+
+// save seals key into the keystore of f and prints its public key and peer
+// ID.
+func (f *keystoreFlags) save(key *keystore.Key) {
+	password, err := readPassword(*f.passwordFile)
+	if err != nil {
+		fatal("Failed to read password", err)
+	}
+
+	params := keystore.DefaultParams
+	if *f.light {
+		params = keystore.LightParams
+	}
+	if err := keystore.Save(*f.out, key, password, params); err != nil {
+		fatal("Failed to write keystore", err)
+	}
+
+	printKey(key, false)
+}
+
+// keystoreCreate writes a keystore with a new longterm key and libp2p
+// identity, for the command name: keygen or keystore create. The private key
+// only leaves the keystore through keystore export.
+func keystoreCreate(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	f := newKeystoreFlags(fs)
+	fs.Parse(args)
+
+	scheme, err := crypto.ParseScheme(*f.scheme)
+	if err != nil {
+		fatal("Failed to parse scheme", err)
+	}
+
+	key, err := keystore.New(scheme)
+	if err != nil {
+		fatal("Failed to generate key", err)
+	}
+	f.save(key)
+}
+
+// keystoreImport writes a keystore with an existing longterm key, read in
+// hex from a file or stdin so that it stays out of the process list, and a
+// new libp2p identity.
+func keystoreImport(args []string) {
+	fs := flag.NewFlagSet("keystore import", flag.ExitOnError)
+	f := newKeystoreFlags(fs)
+	in := fs.String("in", "-", "File holding the private key in hex format, - for stdin")
+	fs.Parse(args)
+
+	scheme, err := crypto.ParseScheme(*f.scheme)
+	if err != nil {
+		fatal("Failed to parse scheme", err)
+	}
+
+	var data []byte
+	if *in == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		fatal("Failed to read private key", err)
+	}
+	privBytes, err := dkg.HexToBytes(strings.TrimSpace(string(data)))
+	if err != nil {
+		fatal("Failed to decode private key", err)
+	}
+	if len(privBytes) == 0 {
+		fatal("Failed to decode private key", errors.New("empty private key"))
+	}
+
+	longterm := scheme.KeyGroup.Scalar()
+	if err := longterm.UnmarshalBinary(privBytes); err != nil {
+		fatal("Failed to unmarshal private key", err)
+	}
+
+	key, err := keystore.Import(scheme, longterm)
+	if err != nil {
+		fatal("Failed to import key", err)
+	}
+	f.save(key)
+}
+
+// keystoreExport prints the keys of a keystore, including the private key.
+func keystoreExport(args []string) {
+	fs := flag.NewFlagSet("keystore export", flag.ExitOnError)
+	in := fs.String("in", "keystore.json", "Keystore file")
+	passwordFile := fs.String("password-file", "", "File holding the keystore password (defaults to $"+passwordEnv+")")
+	fs.Parse(args)
+
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		fatal("Failed to read password", err)
+	}
+
+	key, err := keystore.Load(*in, password)
+	if err != nil {
+		fatal("Failed to load keystore", err)
+	}
+
+	printKey(key, true)
+}
+
+// serveSigner serves the keys of a keystore on a Unix socket until SIGINT or
+// SIGTERM, for a node started with -signer. The share the node hands over is
+// sealed in its own file, so a restarted signer resumes with it.
+func serveSigner(args []string) {
+	fs := flag.NewFlagSet("signer", flag.ExitOnError)
+	socket := fs.String("socket", "signer.sock", "Unix socket to serve on, only reachable by its owner")
+	in := fs.String("keystore", "keystore.json", "Keystore file")
+	passwordFile := fs.String("password-file", "", "File holding the keystore password (defaults to $"+passwordEnv+")")
+	network := fs.String("network", crypto.DefaultNetwork, "Network name, part of the domain separation tag of every signature")
+	shareFile := fs.String("share", "signer-share.json", "File the share is sealed in under the keystore password, and loaded from at startup")
+	light := fs.Bool("light", false, "Seal the share with cheap scrypt parameters, for local networks only")
+	fs.Parse(args)
+
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		fatal("Failed to read password", err)
+	}
+	key, err := keystore.Load(*in, password)
+	if err != nil {
+		fatal("Failed to load keystore", err)
+	}
+
+	scheme, err := crypto.ParseSchemeWithDomain(key.Scheme.Name, crypto.Domain{Network: *network, Purpose: crypto.PurposeVRF})
+	if err != nil {
+		fatal("Failed to parse scheme", err)
+	}
+
+	params := keystore.DefaultParams
+	if *light {
+		params = keystore.LightParams
+	}
+	sgn, err := signer.NewStored(signer.NewLocal(scheme, key.Longterm), *shareFile, password, params)
+	if err != nil {
+		fatal("Failed to load share", err)
+	}
+
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		fatal("Failed to listen", err)
+	}
+	if err := os.Chmod(*socket, 0o600); err != nil {
+		l.Close()
+		fatal("Failed to restrict socket", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	slog.Info("Serving signer", "socket", *socket, "scheme", scheme.Name)
+	if err := signer.Serve(l, scheme, sgn); err != nil {
+		fatal("Failed to serve signer", err)
+	}
+	slog.Info("Stopped signer")
+}
+
+// printKey prints the public key and peer ID of key, and its private key if
+// private is set.
+func printKey(key *keystore.Key, private bool) {
+	pubBytes, err := key.Public().MarshalBinary()
+	if err != nil {
+		fatal("Failed to marshal public key", err)
+	}
+	id, err := peer.IDFromPrivateKey(key.Identity)
+	if err != nil {
+		fatal("Failed to derive peer ID", err)
+	}
+
+	if private {
+		privBytes, err := key.Longterm.MarshalBinary()
+		if err != nil {
+			fatal("Failed to marshal private key", err)
+		}
+		fmt.Printf("private %s\n", hex.EncodeToString(privBytes))
+	}
+	fmt.Printf("public  %s\npeer    %s\n", hex.EncodeToString(pubBytes), id)
+}
@@ -0,0 +1,322 @@
+// Package keystore stores the secrets of a validator, its longterm key and its
+// libp2p identity, in a password protected file, and the share of a signer
+// process in another. The password is stretched with scrypt and the secrets
+// are sealed with AES-256-GCM, authenticating the plaintext header along with
+// them.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"random-network-poc/crypto"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/util/random"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the keystore format this package reads and writes.
+const Version = 1
+
+const (
+	kdfScrypt    = "scrypt"
+	cipherAESGCM = "aes-256-gcm"
+
+	keySize  = 32
+	saltSize = 32
+	// maxN, maxR and maxP bound the scrypt parameters read from a file, and
+	// maxMemory the 128*N*R bytes they allocate, which would otherwise let a
+	// crafted keystore take any amount of memory or time to load.
+	maxN      = 1 << 22
+	maxR      = 32
+	maxP      = 16
+	maxMemory = 1 << 30
+)
+
+var (
+	ErrVersion  = errors.New("unsupported keystore version")
+	ErrPassword = errors.New("wrong keystore password")
+	ErrCorrupt  = errors.New("corrupt keystore")
+)
+
+// Params are the scrypt cost parameters.
+type Params struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var (
+	// DefaultParams take about a second and 256 MiB to derive a key.
+	DefaultParams = Params{N: 1 << 18, R: 8, P: 1}
+	// LightParams are cheap enough for tests and local networks.
+	LightParams = Params{N: 1 << 12, R: 8, P: 1}
+)
+
+// Key holds the secrets of a validator.
+type Key struct {
+	// Scheme is the scheme of Longterm.
+	Scheme   *crypto.Scheme
+	Longterm kyber.Scalar
+	// Identity is the libp2p identity of the validator.
+	Identity p2pcrypto.PrivKey
+}
+
+// New generates a longterm key on scheme and a libp2p identity.
+func New(scheme *crypto.Scheme) (*Key, error) {
+	return Import(scheme, scheme.KeyGroup.Scalar().Pick(random.New()))
+}
+
+// Import builds a key from an existing longterm key, with a new libp2p
+// identity.
+func Import(scheme *crypto.Scheme, longterm kyber.Scalar) (*Key, error) {
+	identity, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity: %w", err)
+	}
+
+	return &Key{
+		Scheme:   scheme,
+		Longterm: longterm,
+		Identity: identity,
+	}, nil
+}
+
+// Public returns the longterm public key, the committee entry of the
+// validator.
+func (k *Key) Public() kyber.Point {
+	return k.Scheme.KeyGroup.Point().Mul(k.Longterm, nil)
+}
+
+// file is the JSON layout of a keystore. Everything but Crypto is in the
+// clear, so that a keystore can be matched to a committee entry and a peer
+// without the password.
+type file struct {
+	Version int    `json:"version"`
+	Scheme  string `json:"scheme"`
+	Public  string `json:"public"`
+	PeerID  string `json:"peer_id"`
+	Crypto  sealed `json:"crypto"`
+}
+
+type sealed struct {
+	KDF        string    `json:"kdf"`
+	KDFParams  kdfParams `json:"kdfparams"`
+	Cipher     string    `json:"cipher"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+}
+
+type kdfParams struct {
+	Params
+	Salt string `json:"salt"`
+}
+
+// secrets is the plaintext sealed in a keystore.
+type secrets struct {
+	Longterm string `json:"longterm"`
+	Identity string `json:"identity"`
+}
+
+// additionalData binds the header of f to its ciphertext, so that the scheme,
+// public key or peer ID cannot be swapped without the password.
+func (f *file) additionalData() []byte {
+	return fmt.Appendf(nil, "%d\x00%s\x00%s\x00%s", f.Version, f.Scheme, f.Public, f.PeerID)
+}
+
+// Encrypt seals k under password.
+func Encrypt(k *Key, password []byte, params Params) ([]byte, error) {
+	longterm, err := k.Longterm.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal longterm key: %w", err)
+	}
+	identity, err := p2pcrypto.MarshalPrivateKey(k.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal identity: %w", err)
+	}
+	public, err := k.Public().MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	id, err := peer.IDFromPrivateKey(k.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+
+	plaintext, err := json.Marshal(&secrets{
+		Longterm: hex.EncodeToString(longterm),
+		Identity: hex.EncodeToString(identity),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{
+		Version: Version,
+		Scheme:  k.Scheme.Name,
+		Public:  hex.EncodeToString(public),
+		PeerID:  id.String(),
+	}
+	f.Crypto, err = seal(password, params, plaintext, f.additionalData())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// Decrypt opens a keystore sealed by Encrypt.
+func Decrypt(data, password []byte) (*Key, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, f.Version)
+	}
+	scheme, err := crypto.ParseScheme(f.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	plaintext, err := open(&f.Crypto, password, f.additionalData())
+	if err != nil {
+		return nil, err
+	}
+
+	var s secrets
+	if err := json.Unmarshal(plaintext, &s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	longterm, err := hex.DecodeString(s.Longterm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: longterm key: %w", ErrCorrupt, err)
+	}
+	identity, err := hex.DecodeString(s.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: identity: %w", ErrCorrupt, err)
+	}
+
+	k := &Key{Scheme: scheme, Longterm: scheme.KeyGroup.Scalar()}
+	if err := k.Longterm.UnmarshalBinary(longterm); err != nil {
+		return nil, fmt.Errorf("%w: longterm key: %w", ErrCorrupt, err)
+	}
+	k.Identity, err = p2pcrypto.UnmarshalPrivateKey(identity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: identity: %w", ErrCorrupt, err)
+	}
+
+	return k, nil
+}
+
+// Save writes k sealed under password to path, failing if the file exists.
+func Save(path string, k *Key, password []byte, params Params) error {
+	data, err := Encrypt(k, password, params)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the keystore at path and opens it with password.
+func Load(path string, password []byte) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, password)
+}
+
+// seal encrypts plaintext under password, authenticating additionalData
+// along with it.
+func seal(password []byte, params Params, plaintext, additionalData []byte) (sealed, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return sealed{}, err
+	}
+	aead, err := newAEAD(password, salt, params)
+	if err != nil {
+		return sealed{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return sealed{}, err
+	}
+
+	return sealed{
+		KDF:        kdfScrypt,
+		KDFParams:  kdfParams{Params: params, Salt: hex.EncodeToString(salt)},
+		Cipher:     cipherAESGCM,
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, plaintext, additionalData)),
+	}, nil
+}
+
+// open decrypts the plaintext of s sealed by seal.
+func open(s *sealed, password, additionalData []byte) ([]byte, error) {
+	if s.KDF != kdfScrypt || s.Cipher != cipherAESGCM {
+		return nil, fmt.Errorf("%w: unsupported kdf %q or cipher %q", ErrCorrupt, s.KDF, s.Cipher)
+	}
+
+	salt, err := hex.DecodeString(s.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: salt: %w", ErrCorrupt, err)
+	}
+	nonce, err := hex.DecodeString(s.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: nonce: %w", ErrCorrupt, err)
+	}
+	ciphertext, err := hex.DecodeString(s.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ciphertext: %w", ErrCorrupt, err)
+	}
+
+	aead, err := newAEAD(password, salt, s.KDFParams.Params)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: nonce of %d bytes", ErrCorrupt, len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrPassword
+	}
+	return plaintext, nil
+}
+
+func newAEAD(password, salt []byte, params Params) (cipher.AEAD, error) {
+	if params.N <= 1 || params.N > maxN || params.R <= 0 || params.R > maxR || params.P <= 0 || params.P > maxP ||
+		128*params.N*params.R > maxMemory {
+		return nil, fmt.Errorf("%w: scrypt parameters %+v", ErrCorrupt, params)
+	}
+
+	key, err := scrypt.Key(password, salt, params.N, params.R, params.P, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package keystore
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"random-network-poc/crypto"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	for _, name := range []string{"bn256-g1", "bls12381-g2"} {
+		t.Run(name, func(t *testing.T) {
+			scheme, err := crypto.ParseScheme(name)
+			require.NoError(t, err)
+
+			key, err := New(scheme)
+			require.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "key.json")
+			require.NoError(t, Save(path, key, []byte("password"), LightParams))
+			// an existing keystore is never overwritten
+			require.Error(t, Save(path, key, []byte("password"), LightParams))
+
+			loaded, err := Load(path, []byte("password"))
+			require.NoError(t, err)
+			require.Equal(t, scheme.Name, loaded.Scheme.Name)
+			require.True(t, key.Longterm.Equal(loaded.Longterm))
+			require.True(t, key.Public().Equal(loaded.Public()))
+			require.True(t, key.Identity.Equals(loaded.Identity))
+
+			_, err = Load(path, []byte("wrong"))
+			require.ErrorIs(t, err, ErrPassword)
+		})
+	}
+}
+
+func TestKeystoreTampered(t *testing.T) {
+	key, err := New(crypto.DefaultScheme())
+	require.NoError(t, err)
+	other, err := New(crypto.DefaultScheme())
+	require.NoError(t, err)
+
+	data, err := Encrypt(key, []byte("password"), LightParams)
+	require.NoError(t, err)
+	otherData, err := Encrypt(other, []byte("password"), LightParams)
+	require.NoError(t, err)
+
+	edit := func(f func(*file)) []byte {
+		var parsed file
+		require.NoError(t, json.Unmarshal(data, &parsed))
+		f(&parsed)
+		out, err := json.Marshal(&parsed)
+		require.NoError(t, err)
+		return out
+	}
+
+	var swap file
+	require.NoError(t, json.Unmarshal(otherData, &swap))
+
+	// the header is authenticated with the secrets
+	_, err = Decrypt(edit(func(f *file) { f.Public = swap.Public }), []byte("password"))
+	require.ErrorIs(t, err, ErrPassword)
+	_, err = Decrypt(edit(func(f *file) { f.PeerID = swap.PeerID }), []byte("password"))
+	require.ErrorIs(t, err, ErrPassword)
+
+	_, err = Decrypt(edit(func(f *file) { f.Version = 2 }), []byte("password"))
+	require.ErrorIs(t, err, ErrVersion)
+	for _, params := range []Params{
+		{N: 1 << 30, R: 8, P: 1},
+		{N: 1 << 12, R: 1 << 20, P: 1},
+		{N: 1 << 12, R: 8, P: 1 << 20},
+		// within each bound, not within the memory bound
+		{N: 1 << 22, R: 8, P: 1},
+	} {
+		_, err = Decrypt(edit(func(f *file) { f.Crypto.KDFParams.Params = params }), []byte("password"))
+		require.ErrorIs(t, err, ErrCorrupt, params)
+	}
+	_, err = Decrypt([]byte("{"), []byte("password"))
+	require.ErrorIs(t, err, ErrCorrupt)
+}
@@ -0,0 +1,97 @@
+package keystore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4/share"
+)
+
+// shareFile is the JSON layout of a sealed share, kept by a signer process
+// next to its keystore. The scheme and share index are in the clear.
+type shareFile struct {
+	Version int    `json:"version"`
+	Scheme  string `json:"scheme"`
+	Index   uint32 `json:"index"`
+	Crypto  sealed `json:"crypto"`
+}
+
+// additionalData binds the header of f to its ciphertext.
+func (f *shareFile) additionalData() []byte {
+	return fmt.Appendf(nil, "share\x00%d\x00%s\x00%d", f.Version, f.Scheme, f.Index)
+}
+
+// EncryptShare seals the share s of the group key on scheme under password.
+func EncryptShare(scheme *crypto.Scheme, s *share.PriShare, password []byte, params Params) ([]byte, error) {
+	v, err := s.V.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal share: %w", err)
+	}
+	defer clear(v)
+
+	f := &shareFile{
+		Version: Version,
+		Scheme:  scheme.Name,
+		Index:   s.I,
+	}
+	f.Crypto, err = seal(password, params, v, f.additionalData())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// DecryptShare opens a share of the group key on scheme sealed by
+// EncryptShare.
+func DecryptShare(scheme *crypto.Scheme, data, password []byte) (*share.PriShare, error) {
+	var f shareFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, f.Version)
+	}
+	if f.Scheme != scheme.Name {
+		return nil, fmt.Errorf("%w: share on scheme %s, not %s", ErrCorrupt, f.Scheme, scheme.Name)
+	}
+
+	v, err := open(&f.Crypto, password, f.additionalData())
+	if err != nil {
+		return nil, err
+	}
+	defer clear(v)
+
+	s := &share.PriShare{I: f.Index, V: scheme.KeyGroup.Scalar()}
+	if err := s.V.UnmarshalBinary(v); err != nil {
+		return nil, fmt.Errorf("%w: share: %w", ErrCorrupt, err)
+	}
+	return s, nil
+}
+
+// SaveShare writes s sealed under password to path, replacing the share
+// saved there before only once the new one is fully written.
+func SaveShare(path string, scheme *crypto.Scheme, s *share.PriShare, password []byte, params Params) error {
+	data, err := EncryptShare(scheme, s, password, params)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadShare reads the share at path and opens it with password.
+func LoadShare(path string, scheme *crypto.Scheme, password []byte) (*share.PriShare, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptShare(scheme, data, password)
+}
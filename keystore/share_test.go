@@ -0,0 +1,52 @@
+package keystore
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"random-network-poc/crypto"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestShare(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	s := &share.PriShare{I: 3, V: scheme.KeyGroup.Scalar().Pick(random.New())}
+
+	path := filepath.Join(t.TempDir(), "share.json")
+	require.NoError(t, SaveShare(path, scheme, s, []byte("password"), LightParams))
+
+	loaded, err := LoadShare(path, scheme, []byte("password"))
+	require.NoError(t, err)
+	require.Equal(t, s.I, loaded.I)
+	require.True(t, s.V.Equal(loaded.V))
+
+	// a refreshed share replaces the saved one
+	refreshed := &share.PriShare{I: 3, V: scheme.KeyGroup.Scalar().Pick(random.New())}
+	require.NoError(t, SaveShare(path, scheme, refreshed, []byte("password"), LightParams))
+	loaded, err = LoadShare(path, scheme, []byte("password"))
+	require.NoError(t, err)
+	require.True(t, refreshed.V.Equal(loaded.V))
+
+	_, err = LoadShare(path, scheme, []byte("wrong"))
+	require.ErrorIs(t, err, ErrPassword)
+
+	other, err := crypto.ParseScheme("bls12381-g2")
+	require.NoError(t, err)
+	_, err = LoadShare(path, other, []byte("password"))
+	require.ErrorIs(t, err, ErrCorrupt)
+
+	// the share index is authenticated with the share
+	data, err := EncryptShare(scheme, s, []byte("password"), LightParams)
+	require.NoError(t, err)
+	var f shareFile
+	require.NoError(t, json.Unmarshal(data, &f))
+	f.Index = 4
+	data, err = json.Marshal(&f)
+	require.NoError(t, err)
+	_, err = DecryptShare(scheme, data, []byte("password"))
+	require.ErrorIs(t, err, ErrPassword)
+}
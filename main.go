@@ -1,130 +1,165 @@
 package main
 
 import (
-	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
-	"log"
-	"time"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
 
+	"random-network-poc/crypto"
 	"random-network-poc/dkg"
-	"random-network-poc/p2p"
-
-	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
-)
-
-var (
-	index = flag.Uint("index", 0, "Node index")
-	pk    = flag.String("pk", "", "Private key in hex format")
-	nonce = flag.String("nonce", "", "Nonce in hex format")
+	"random-network-poc/timelock"
 )
 
-func main() {
-	flag.Parse()
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  random-network-poc keygen [-out file] [-scheme name] [-password-file file]
+  random-network-poc keystore create [-out file] [-scheme name] [-password-file file]
+  random-network-poc keystore import [-in file] [-out file] [-scheme name] [-password-file file]
+  random-network-poc keystore export [-in file] [-password-file file]
+  random-network-poc signer -keystore <file> [-socket path] [-share file] [-password-file file]
+  random-network-poc dkg -index <n> -keystore <file> -nonce <hex> [-share file] [node flags]
+  random-network-poc run -index <n> -keystore <file> -nonce <hex> [-share file] [node flags]
+  random-network-poc run -keystore <file> -groups <file> [node flags]
+  random-network-poc run -keystore <file> -nonce <hex> -epochs -admin <addr> -genesis <time> [node flags]
+  random-network-poc request -node <url> [-group id] [-input hex | -round n | -epochs -round n]
+  random-network-poc verify -pub <hex> [-in file | -input hex -sig hex] [-scheme name] [-network name]
+  random-network-poc status -node <url> [-group id | -epochs | -epoch n]
+  random-network-poc approve -keystore <file> -epoch <n> -first-round <n> -committee-file <file> -threshold <n>
+  random-network-poc announce -nodes <urls> -epoch <n> -first-round <n> -committee-file <file> -threshold <n> -approvals <file>
+
+Run a command with -h for its flags.
+`)
+	os.Exit(2)
+}
 
-	if *pk == "" {
-		log.Fatal("Private key is required")
-	}
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
 
-	// Convert hex private key to bytes
-	privKeyBytes, err := dkg.HexToBytes(*pk)
-	if err != nil {
-		log.Fatalf("Failed to decode private key: %v", err)
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
 	}
 
-	// Convert hex nonce to bytes
-	nonceBytes, err := dkg.HexToBytes(*nonce)
-	if err != nil {
-		log.Fatalf("Failed to decode nonce: %v", err)
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
 	}
+}
 
-	p2pNode, err := p2p.NewNode(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to create P2P node: %v", err)
-	}
-
-	log.Println("Node ID:", p2pNode.ID())
-
-	log.Println("Discovering peers...")
-	if err := p2pNode.DiscoverPeers(context.Background()); err != nil {
-		log.Fatalf("Failed to discover peers: %v", err)
+func main() {
+	if len(os.Args) < 2 {
+		usage()
 	}
 
-	board, err := dkg.NewBoardP2P(context.Background(), p2pNode.PubSub(), p2pNode.ID())
-	if err != nil {
-		log.Fatalf("Failed to create board: %v", err)
+	switch os.Args[1] {
+	case "keygen":
+		keystoreCreate("keygen", os.Args[2:])
+	case "keystore":
+		keys(os.Args[2:])
+	case "signer":
+		serveSigner(os.Args[2:])
+	case "dkg", "run":
+		runNode(os.Args[1], os.Args[2:])
+	case "request":
+		request(os.Args[2:])
+	case "verify":
+		verify(os.Args[2:])
+	case "status":
+		status(os.Args[2:])
+	case "approve":
+		approve(os.Args[2:])
+	case "announce":
+		announce(os.Args[2:])
+	default:
+		usage()
 	}
+}
 
-	// Create DKG node
-	node, err := dkg.NewNode(uint32(*index), privKeyBytes, nonceBytes, board, p2pNode.PubSub(), p2pNode.ID())
+// verify checks a round output against the group public key and prints its
+// randomness.
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	pub := fs.String("pub", "", "Group public key in hex format")
+	in := fs.String("in", "", "Output of the request command, - for stdin (replaces -input and -sig)")
+	inputHex := fs.String("input", "", "Signed input in hex format")
+	sigHex := fs.String("sig", "", "Threshold BLS signature in hex format")
+	schemeName := fs.String("scheme", crypto.DefaultSchemeName, "Cryptographic scheme of the network")
+	network := fs.String("network", crypto.DefaultNetwork, "Network name used in the domain separation tag")
+	fs.Parse(args)
+
+	scheme, err := crypto.ParseSchemeWithDomain(*schemeName, crypto.Domain{Network: *network, Purpose: crypto.PurposeVRF})
 	if err != nil {
-		log.Fatalf("Failed to create DKG node: %v", err)
+		fatal("Failed to parse scheme", err)
 	}
 
-	for len(p2pNode.PubSub().ListPeers(dkg.Topic)) != 2 {
+	if *pub == "" {
+		fatal("Group public key is required", errors.New("missing -pub"))
 	}
-
-	log.Println("All peers discovered!")
-
-	time.Sleep(1 * time.Second)
-
-	log.Println("Starting DKG protocol")
-	node.StartDKG()
-
-	log.Println("Waiting for DKG to finish")
-	result := <-node.Protocol.WaitEnd()
-
-	if result.Error != nil {
-		log.Fatalf("DKG failed: %v", result.Error)
+	pubBytes, err := dkg.HexToBytes(*pub)
+	if err != nil {
+		fatal("Failed to decode group public key", err)
 	}
-
-	node.Result = result.Result
-
-	pubBytes, err := node.Result.Key.Public().MarshalBinary()
+	public, err := scheme.PointFromBytes(pubBytes)
 	if err != nil {
-		log.Fatalf("Failed to marshal public point: %v", err)
+		fatal("Failed to unmarshal group public key", err)
 	}
 
-	log.Printf("Public: %v\n", hex.EncodeToString(pubBytes))
-
-	if *index == 0 {
-		time.Sleep(2 * time.Second)
-
-		prevBlockHash := "0x0000000000000000000000000000000000000000000000000000000000000000"
-		nextBlockNumber := "1"
-		seed := pedersen_dkg.GetNonce()
-		data := append([]byte(prevBlockHash), []byte(nextBlockNumber)...)
-		data = append(data, seed...)
-
-		hash := sha256.Sum256(data)
-		requestID := hex.EncodeToString(hash[:])
-
-		log.Println("Initiating VRF generation")
-
-		if err := node.StartRandomNumberGeneration(requestID, hash[:]); err != nil {
-			log.Fatalf("Failed to start random number generation: %v", err)
+	// resharing keeps the key: the epoch of an output only names the
+	// committee which signed it
+	var epoch uint64
+	if *in != "" {
+		var data []byte
+		if *in == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*in)
 		}
-
-		<-node.WaitRNGRound(requestID)
-
-		sig, err := node.RecoverBLSSignature(requestID, hash[:])
 		if err != nil {
-			log.Fatalf("Failed to recover signature: %v", err)
+			fatal("Failed to read output", err)
 		}
 
-		log.Printf("Threshold BLS signature: %v\n", hex.EncodeToString(sig))
-
-		if err := node.VerifyBLSSignature(hash[:], sig); err != nil {
-			log.Fatalf("Failed to verify signature: %v", err)
+		var out dkg.Output
+		if err := json.Unmarshal(data, &out); err != nil {
+			fatal("Failed to decode output", err)
 		}
+		*inputHex, *sigHex = out.Input, out.Signature
+		epoch = out.Epoch
+	}
 
-		log.Println("Signature is valid!")
+	input, err := dkg.HexToBytes(*inputHex)
+	if err != nil {
+		fatal("Failed to decode input", err)
+	}
+	sig, err := dkg.HexToBytes(*sigHex)
+	if err != nil {
+		fatal("Failed to decode signature", err)
+	}
 
-		randomNumber := node.GenerateRandomNumber(sig)
-		log.Printf("Random number: %v\n", randomNumber)
+	// beacon rounds are signed for their own purpose
+	if _, ok := timelock.ParseRound(input); ok {
+		if scheme, err = scheme.WithPurpose(crypto.PurposeRound); err != nil {
+			fatal("Failed to parse scheme", err)
+		}
+	}
+	if err := scheme.SigScheme.Verify(public, input, sig); err != nil {
+		fatal("Invalid signature", err)
 	}
 
-	// Keep the program running
-	select {}
+	fmt.Printf("valid\nrandomness %s\n", dkg.Randomness(sig))
+	if epoch != 0 {
+		fmt.Printf("epoch %d\n", epoch)
+	}
 }
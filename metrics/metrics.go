@@ -0,0 +1,257 @@
+// Package metrics exports Prometheus metrics of the DKG, the RNG protocol and
+// the pubsub topics they use. Every method is safe on a nil *Metrics, which
+// records nothing.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "rnn"
+
+// Kinds of DKG runs.
+const (
+	KindDKG      = "dkg"
+	KindRefresh  = "refresh"
+	KindHandover = "handover"
+)
+
+// Outcomes of DKG runs and RNG rounds.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFailure  = "failure"
+	OutcomeRejected = "rejected"
+)
+
+// Directions of board bundles.
+const (
+	Sent     = "sent"
+	Received = "received"
+)
+
+type Metrics struct {
+	dkgPhase    *prometheus.HistogramVec
+	dkgDuration *prometheus.HistogramVec
+	dkgRuns     *prometheus.CounterVec
+	bundles     *prometheus.CounterVec
+
+	partialsReceived prometheus.Counter
+	partialsVerified prometheus.Counter
+	partialsRejected *prometheus.CounterVec
+	dropped          *prometheus.CounterVec
+	rounds           *prometheus.CounterVec
+	roundDuration    prometheus.Histogram
+
+	topics *topicPeers
+}
+
+// New registers the metrics on reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		dkgPhase: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dkg",
+			Name:      "phase_duration_seconds",
+			Help:      "Duration of DKG and refresh phases.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 8),
+		}, []string{"kind", "phase"}),
+		dkgDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dkg",
+			Name:      "duration_seconds",
+			Help:      "Duration of DKG and refresh runs.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 8),
+		}, []string{"kind"}),
+		dkgRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dkg",
+			Name:      "runs_total",
+			Help:      "DKG and refresh runs by outcome.",
+		}, []string{"kind", "outcome"}),
+		bundles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "board",
+			Name:      "bundles_total",
+			Help:      "DKG bundles sent and received by type.",
+		}, []string{"direction", "type"}),
+		partialsReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "partials_received_total",
+			Help:      "Partial signatures received for rounds of this node.",
+		}),
+		partialsVerified: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "partials_verified_total",
+			Help:      "Partial signatures verified against the group polynomial.",
+		}),
+		partialsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "partials_rejected_total",
+			Help:      "Partial signatures rejected by reason.",
+		}, []string{"reason"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "messages_dropped_total",
+			Help:      "RNG messages dropped as replays or over the rate limits.",
+		}, []string{"reason"}),
+		rounds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "rounds_total",
+			Help:      "RNG rounds of this node by outcome.",
+		}, []string{"outcome"}),
+		roundDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rng",
+			Name:      "round_duration_seconds",
+			Help:      "Time from the start of a round to the recovery of its signature.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}),
+		topics: newTopicPeers(),
+	}
+
+	reg.MustRegister(
+		m.dkgPhase,
+		m.dkgDuration,
+		m.dkgRuns,
+		m.bundles,
+		m.partialsReceived,
+		m.partialsVerified,
+		m.partialsRejected,
+		m.dropped,
+		m.rounds,
+		m.roundDuration,
+		m.topics,
+	)
+
+	return m
+}
+
+// DKGPhase records the duration of a phase of a DKG or refresh run.
+func (m *Metrics) DKGPhase(kind, phase string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.dkgPhase.WithLabelValues(kind, phase).Observe(d.Seconds())
+}
+
+// DKGRun records the outcome and duration of a DKG or refresh run.
+func (m *Metrics) DKGRun(kind string, err error, d time.Duration) {
+	if m == nil {
+		return
+	}
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeFailure
+	}
+	m.dkgRuns.WithLabelValues(kind, outcome).Inc()
+	m.dkgDuration.WithLabelValues(kind).Observe(d.Seconds())
+}
+
+// Bundle counts a DKG bundle sent or received.
+func (m *Metrics) Bundle(direction, bundleType string) {
+	if m == nil {
+		return
+	}
+	m.bundles.WithLabelValues(direction, bundleType).Inc()
+}
+
+// PartialReceived counts a partial signature received.
+func (m *Metrics) PartialReceived() {
+	if m == nil {
+		return
+	}
+	m.partialsReceived.Inc()
+}
+
+// PartialVerified counts a partial signature verified.
+func (m *Metrics) PartialVerified() {
+	if m == nil {
+		return
+	}
+	m.partialsVerified.Inc()
+}
+
+// PartialRejected counts a partial signature rejected for reason.
+func (m *Metrics) PartialRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.partialsRejected.WithLabelValues(reason).Inc()
+}
+
+// Dropped counts an RNG message dropped for reason.
+func (m *Metrics) Dropped(reason string) {
+	if m == nil {
+		return
+	}
+	m.dropped.WithLabelValues(reason).Inc()
+}
+
+// Round records the outcome of a round and, once recovered, its latency.
+func (m *Metrics) Round(outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rounds.WithLabelValues(outcome).Inc()
+	if outcome == OutcomeSuccess {
+		m.roundDuration.Observe(d.Seconds())
+	}
+}
+
+// WatchTopics exports the number of peers of each topic joined on ps.
+func (m *Metrics) WatchTopics(ps *pubsub.PubSub, topics ...string) {
+	if m == nil {
+		return
+	}
+	m.topics.watch(ps, topics)
+}
+
+// topicPeers collects the peer counts of the watched topics on scrape.
+type topicPeers struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	topics map[string]*pubsub.PubSub
+}
+
+func newTopicPeers() *topicPeers {
+	return &topicPeers{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "pubsub", "peers"),
+			"Peers subscribed to a pubsub topic.",
+			[]string{"topic"}, nil,
+		),
+		topics: make(map[string]*pubsub.PubSub),
+	}
+}
+
+func (t *topicPeers) watch(ps *pubsub.PubSub, topics []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, topic := range topics {
+		t.topics[topic] = ps
+	}
+}
+
+func (t *topicPeers) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.desc
+}
+
+func (t *topicPeers) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for topic, ps := range t.topics {
+		ch <- prometheus.MustNewConstMetric(t.desc, prometheus.GaugeValue, float64(len(ps.ListPeers(topic))), topic)
+	}
+}
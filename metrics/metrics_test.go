@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+
+	m.DKGPhase(KindDKG, "deal", time.Second)
+	m.DKGRun(KindDKG, nil, 3*time.Second)
+	m.DKGRun(KindRefresh, errors.New("timeout"), time.Second)
+	m.Bundle(Sent, "deal")
+	m.Bundle(Received, "deal")
+	m.Bundle(Received, "deal")
+	m.PartialReceived()
+	m.PartialVerified()
+	m.PartialRejected("invalid")
+	m.Dropped("replay")
+	m.Round(OutcomeSuccess, 50*time.Millisecond)
+	m.Round(OutcomeRejected, 0)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.dkgRuns.WithLabelValues(KindDKG, OutcomeSuccess)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.dkgRuns.WithLabelValues(KindRefresh, OutcomeFailure)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.bundles.WithLabelValues(Sent, "deal")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.bundles.WithLabelValues(Received, "deal")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.partialsReceived))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.partialsVerified))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.partialsRejected.WithLabelValues("invalid")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.dropped.WithLabelValues("replay")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.rounds.WithLabelValues(OutcomeSuccess)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.rounds.WithLabelValues(OutcomeRejected)))
+
+	// only the recovered round is timed
+	require.Equal(t, 1, testutil.CollectAndCount(m.roundDuration))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		require.Contains(t, f.GetName(), namespace+"_")
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	require.NotPanics(t, func() {
+		m.DKGPhase(KindDKG, "deal", time.Second)
+		m.DKGRun(KindDKG, nil, time.Second)
+		m.Bundle(Sent, "deal")
+		m.PartialReceived()
+		m.PartialVerified()
+		m.PartialRejected("invalid")
+		m.Dropped("replay")
+		m.Round(OutcomeSuccess, time.Second)
+		m.WatchTopics(nil, "topic")
+	})
+}
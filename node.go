@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"random-network-poc/admin"
+	"random-network-poc/crypto"
+	"random-network-poc/dkg"
+	"random-network-poc/envelope"
+	"random-network-poc/epoch"
+	"random-network-poc/group"
+	"random-network-poc/keystore"
+	"random-network-poc/metrics"
+	"random-network-poc/p2p"
+	"random-network-poc/rng"
+	"random-network-poc/signer"
+	"random-network-poc/timelock"
+	"random-network-poc/wire"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.dedis.ch/kyber/v4"
+)
+
+// shutdownTimeout bounds the shutdown of the HTTP servers.
+const shutdownTimeout = 5 * time.Second
+
+// nodeFlags are the flags of the dkg and run commands.
+type nodeFlags struct {
+	index         *uint
+	pk            *string
+	keystore      *string
+	passwordFile  *string
+	signer        *string
+	nonce         *string
+	scheme        *string
+	committee     *string
+	committeeFile *string
+	groups        *string
+	network       *string
+	share         *string
+	refresh       *time.Duration
+	genesis       *string
+	period        *time.Duration
+	format        *string
+	peerRate      *float64
+	peerBurst     *int
+	peerQuota     *int
+	logLevel      *string
+	logFormat     *string
+	metricsAt     *string
+	adminAt       *string
+	epochs        *bool
+	groupKey      *string
+}
+
+func newNodeFlags(fs *flag.FlagSet) *nodeFlags {
+	return &nodeFlags{
+		index:         fs.Uint("index", 0, "Node index"),
+		pk:            fs.String("pk", "", "Private key in hex format, visible to other users of the host (prefer -keystore)"),
+		keystore:      fs.String("keystore", "", "Keystore holding the longterm key and libp2p identity, see the keystore command"),
+		passwordFile:  fs.String("password-file", "", "File holding the keystore password (defaults to $"+passwordEnv+")"),
+		signer:        fs.String("signer", "", "Unix socket of a signer process holding the share, see the signer command: the node then keeps and saves no copy of it, so it can neither refresh nor hand it over, which excludes -refresh and -epochs (empty signs in process)"),
+		nonce:         fs.String("nonce", "", "Nonce in hex format"),
+		scheme:        fs.String("scheme", crypto.DefaultSchemeName, "Cryptographic scheme: bn256-g1, bn254-g1, bls12381-g1 or bls12381-g2"),
+		committee:     fs.String("committee", "", "Comma separated committee public keys in hex format (defaults to the built-in BN256 committee)"),
+		committeeFile: fs.String("committee-file", "", "File of committee public keys in hex format, one per line in index order (replaces -committee)"),
+		groups:        fs.String("groups", "", "JSON file of the committee groups to run side by side, each with its id, index, nonce, committee or committee_file, threshold and share (replaces -index, -nonce, -committee, -committee-file and -share)"),
+		network:       fs.String("network", crypto.DefaultNetwork, "Network name, part of the domain separation tag of every signature"),
+		share:         fs.String("share", "share.json", "File the share is written to after the DKG and every refresh, and loaded from by run, without the share itself with -signer; -epochs saves its epochs and their shares next to it (empty keeps them in memory)"),
+		refresh:       fs.Duration("refresh", 0, "Interval of proactive share refresh, e.g. 1h (0 disables), which needs the share in process: not with -signer"),
+		genesis:       fs.String("genesis", "", "Time beacon round 0 is due, in RFC 3339 format, the same on every validator: no round is signed before it is due (empty signs no round)"),
+		period:        fs.Duration("period", 30*time.Second, "Interval between beacon rounds from -genesis"),
+		format:        fs.String("wire", "json", "Preferred wire format: json or protobuf (protobuf is used once every peer supports it)"),
+		peerRate:      fs.Float64("peer-rate", rng.DefaultPeerLimits.Rate, "Rounds per second each committee member may announce (0 disables)"),
+		peerBurst:     fs.Int("peer-burst", rng.DefaultPeerLimits.Burst, "Rounds each committee member may announce at once"),
+		peerQuota:     fs.Int("peer-quota", rng.DefaultPeerLimits.Quota, "Rounds each committee member may announce per hour (0 disables)"),
+		logLevel:      fs.String("log-level", "info", "Log level: debug, info, warn or error"),
+		logFormat:     fs.String("log-format", "text", "Log format: text or json"),
+		metricsAt:     fs.String("metrics", "", "Address to serve Prometheus metrics on at /metrics, e.g. :9100 (empty disables)"),
+		adminAt:       fs.String("admin", "", "Address to serve /healthz, /readyz, /status and /randomness on, e.g. :9101 (empty disables)"),
+		epochs:        fs.Bool("epochs", false, "Run the committee of the flags as epoch 0 and hand the key over to the committees announced on POST /epochs of -admin, see the announce command (-index is then that of the longterm key)"),
+		groupKey:      fs.String("group-key", "", "Group public key in hex format, which -epochs checks the commits of an announcement against when the validator joins a later epoch (required outside the committee of the flags)"),
+	}
+}
+
+// runNode runs the dkg command, which exits once the DKG is done and the share
+// written, or the run command, which loads the share or runs the DKG and then
+// serves rounds until SIGINT or SIGTERM.
+func runNode(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	f := newNodeFlags(fs)
+	fs.Parse(args)
+
+	logger, err := newLogger(*f.logLevel, *f.logFormat)
+	if err != nil {
+		fatal("Failed to create logger", err)
+	}
+	// libraries logging with the log package end up in the same stream
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, command == "run", f, logger); err != nil {
+		stop()
+		fatal("Node failed", err)
+	}
+}
+
+// loadKey returns the longterm key of -keystore or -pk, and the libp2p
+// identity of the keystore, nil for a random one.
+func loadKey(f *nodeFlags, scheme *crypto.Scheme) (kyber.Scalar, p2pcrypto.PrivKey, error) {
+	switch {
+	case *f.keystore != "" && *f.pk != "":
+		return nil, nil, errors.New("-keystore and -pk are mutually exclusive")
+	case *f.keystore != "":
+		password, err := readPassword(*f.passwordFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := keystore.Load(*f.keystore, password)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load keystore: %w", err)
+		}
+		if key.Scheme.Name != scheme.Name {
+			return nil, nil, fmt.Errorf("keystore holds a %s key, the network runs %s", key.Scheme.Name, scheme.Name)
+		}
+		return key.Longterm, key.Identity, nil
+	case *f.pk != "":
+		// Convert hex private key to bytes
+		privKeyBytes, err := dkg.HexToBytes(*f.pk)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode private key: %w", err)
+		}
+		return scheme.KeyGroup.Scalar().SetBytes(privKeyBytes), nil, nil
+	default:
+		return nil, nil, errors.New("-keystore or -pk is required")
+	}
+}
+
+// run runs the validator until ctx is done, then shuts it down: pending
+// rounds fail, the nodes and boards leave their topics, the HTTP servers stop
+// and the libp2p host is closed. With serve unset it returns once the DKG of
+// every group is done.
+func run(ctx context.Context, serve bool, f *nodeFlags, logger *slog.Logger) error {
+	cryptoScheme, err := crypto.ParseSchemeWithDomain(*f.scheme, crypto.Domain{Network: *f.network, Purpose: crypto.PurposeVRF})
+	if err != nil {
+		return fmt.Errorf("failed to parse scheme: %w", err)
+	}
+
+	longterm, identity, err := loadKey(f, cryptoScheme)
+	if err != nil {
+		return err
+	}
+
+	specs, err := readGroups(f, cryptoScheme)
+	if err != nil {
+		return err
+	}
+
+	var sgn signer.Signer
+	if *f.signer != "" {
+		// a signer process holds a single share
+		if len(specs) > 1 {
+			return errors.New("-signer holds the share of a single group, not of every group of -groups")
+		}
+		// the node forgets the share it hands over, it has none to deal from
+		if *f.refresh != 0 {
+			return errors.New("-refresh needs the share in process, not in the -signer process")
+		}
+		remote, err := signer.Dial(*f.signer)
+		if err != nil {
+			return err
+		}
+		defer remote.Close()
+		sgn = remote
+	}
+
+	// the validator may only join a later epoch
+	member := true
+	if *f.epochs {
+		switch {
+		case !serve:
+			return errors.New("-epochs runs with the run command only")
+		case *f.groups != "":
+			return errors.New("-epochs runs the committee of the flags, not those of -groups")
+		case sgn != nil:
+			return errors.New("-epochs hands the share over, which -signer keeps out of the node")
+		case *f.adminAt == "":
+			return errors.New("-epochs takes announcements on -admin, which is required")
+		}
+		specs[0].index, member = indexOf(specs[0].nodes, cryptoScheme.KeyGroup.Point().Mul(longterm, nil))
+	} else if *f.groupKey != "" {
+		return errors.New("-group-key checks the announcements of -epochs only")
+	}
+	groupKey, err := parseGroupKey(cryptoScheme, *f.groupKey)
+	if err != nil {
+		return err
+	}
+
+	// a restarted validator resumes the epochs it saved, without the node of
+	// epoch 0 once retired
+	var saved []epoch.Committee
+	if *f.epochs {
+		saved, err = loadEpochs(*f.share, cryptoScheme)
+		if err != nil {
+			return err
+		}
+		if len(saved) > 0 && saved[0].Epoch > 0 {
+			member = false
+		}
+	}
+
+	schedule, err := parseSchedule(*f.genesis, *f.period)
+	if err != nil {
+		return err
+	}
+	if *f.epochs && schedule == nil {
+		return errors.New("-epochs runs beacon rounds, which need -genesis")
+	}
+
+	wireFormat, err := wire.ParseFormat(*f.format)
+	if err != nil {
+		return fmt.Errorf("failed to parse wire format: %w", err)
+	}
+
+	// run resumes from the share of a previous DKG
+	if serve {
+		for _, spec := range specs {
+			if err := spec.load(cryptoScheme); err != nil {
+				return err
+			}
+			if spec.loadedHeld && sgn == nil {
+				return fmt.Errorf("%s holds no share, which a signer process keeps: -signer is required", spec.share)
+			}
+		}
+	}
+
+	// components add the node index and peer ID to the logger they are given,
+	// and the nodes of -groups and -epochs their group
+	base := logger
+	if *f.groups == "" && !*f.epochs {
+		logger = logger.With("node", *f.index)
+	}
+
+	p2pNode, err := p2p.NewNode(ctx, identity, base)
+	if err != nil {
+		return fmt.Errorf("failed to create P2P node: %w", err)
+	}
+	defer func() {
+		if err := p2pNode.Close(); err != nil {
+			logger.Warn("Failed to close P2P node", "err", err)
+		}
+		logger.Info("Stopped node")
+	}()
+
+	logger = logger.With("peer", p2pNode.ID())
+	logger.Info("Started node", "dst", string(cryptoScheme.DST))
+
+	logger.Info("Discovering peers")
+	if err := p2pNode.DiscoverPeers(ctx); err != nil {
+		return fmt.Errorf("failed to discover peers: %w", err)
+	}
+
+	negotiator := wire.NewNegotiator(p2pNode.Host, wireFormat)
+
+	// the metrics and admin endpoints share a server when given the same
+	// address
+	muxes := make(map[string]*http.ServeMux)
+	muxAt := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	var m *metrics.Metrics
+	if *f.metricsAt != "" {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		m = metrics.New(reg)
+
+		muxAt(*f.metricsAt).Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	}
+
+	groups := group.NewManager()
+	defer func() {
+		if err := groups.Close(); err != nil {
+			logger.Warn("Failed to close groups", "err", err)
+		}
+	}()
+
+	nodes := make([]*dkg.Node, len(specs))
+	var template dkg.Config
+	for i, spec := range specs {
+		codec := envelope.New(&envelope.Config{
+			Scheme:   cryptoScheme,
+			Index:    spec.index,
+			Longterm: longterm,
+			Nodes:    spec.nodes,
+			Signer:   sgn,
+		})
+
+		conf := &dkg.Config{
+			Index:       spec.index,
+			LongtermKey: longterm,
+			Nonce:       spec.nonce,
+			Scheme:      cryptoScheme,
+			Nodes:       spec.nodes,
+			Threshold:   spec.threshold,
+
+			RefreshInterval: *f.refresh,
+			Wire:            negotiator,
+			Envelope:        codec,
+			PeerLimits: &rng.Limits{
+				Rate:        *f.peerRate,
+				Burst:       *f.peerBurst,
+				Quota:       *f.peerQuota,
+				QuotaWindow: time.Hour,
+			},
+			Logger:      base,
+			Metrics:     m,
+			Signer:      sgn,
+			ForgetShare: sgn != nil,
+			SaveResult:  spec.save(),
+			Group:       spec.id,
+			Schedule:    schedule,
+		}
+		template = *conf
+		if !member {
+			break
+		}
+
+		if spec.id != "" {
+			nodes[i], err = groups.Join(ctx, conf, p2pNode.PubSub(), p2pNode.Host)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		board, err := dkg.NewBoardP2P(ctx, p2pNode.PubSub(), p2pNode.ID(), cryptoScheme, negotiator, codec, base, m)
+		if err != nil {
+			return fmt.Errorf("failed to create board: %w", err)
+		}
+		defer func() {
+			if err := board.Close(); err != nil {
+				logger.Warn("Failed to close board", "err", err)
+			}
+		}()
+
+		nodes[i], err = dkg.NewNode(ctx, conf, board, p2pNode.PubSub(), p2pNode.Host)
+		if err != nil {
+			return fmt.Errorf("failed to create DKG node: %w", err)
+		}
+		node := nodes[i]
+		defer func() {
+			if err := node.Close(); err != nil {
+				logger.Warn("Failed to close DKG node", "err", err)
+			}
+		}()
+	}
+
+	var epochs *epoch.Manager
+	if *f.epochs {
+		net := newEpochNet(ctx, cryptoScheme, longterm, p2pNode, template, *f.share, logger)
+		defer func() {
+			if err := net.Close(); err != nil {
+				logger.Warn("Failed to close epoch boards", "err", err)
+			}
+		}()
+
+		threshold := specs[0].threshold
+		if threshold == 0 {
+			threshold = dkg.Threshold
+		}
+		first := epoch.Committee{Nodes: specs[0].nodes, Threshold: threshold}
+		conf := net.config(specs[0].nonce)
+		conf.GroupKey = groupKey
+		if saved == nil {
+			epochs, err = epoch.New(ctx, conf, first, nodes[0])
+		} else {
+			epochs, err = net.restore(conf, saved, nodes[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create epoch manager: %w", err)
+		}
+		defer func() {
+			if err := epochs.Close(); err != nil {
+				logger.Warn("Failed to close epochs", "err", err)
+			}
+		}()
+	}
+
+	if *f.adminAt != "" {
+		switch {
+		case *f.epochs:
+			admin.RegisterEpochs(muxAt(*f.adminAt), cryptoScheme, epochs)
+		case *f.groups != "":
+			admin.RegisterGroups(muxAt(*f.adminAt), groups)
+		default:
+			admin.Register(muxAt(*f.adminAt), nodes[0])
+		}
+	}
+	for addr, mux := range muxes {
+		server := &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Failed to serve HTTP endpoints", "addr", addr, "err", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Warn("Failed to stop HTTP server", "addr", addr, "err", err)
+			}
+		}()
+		logger.Info("Serving HTTP endpoints", "addr", addr)
+	}
+
+	// the groups run their DKG side by side, each waiting for its own
+	// committee
+	var wg sync.WaitGroup
+	errs := make([]error, len(specs))
+	for i, spec := range specs {
+		if nodes[i] == nil {
+			if saved == nil {
+				logger.Info("Waiting for an epoch to join")
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = startGroup(ctx, spec, nodes[i], p2pNode, logger)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if !serve {
+		return nil
+	}
+
+	for _, node := range nodes {
+		if node != nil {
+			node.StartRefresh(ctx)
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// startGroup installs the loaded share of a group, or runs its DKG once every
+// member of its committee is discovered.
+func startGroup(ctx context.Context, spec *groupSpec, node *dkg.Node, p2pNode *p2p.NodeP2P, logger *slog.Logger) error {
+	if spec.id != "" {
+		logger = logger.With("group", spec.id)
+	}
+
+	result := spec.loaded
+	if result != nil {
+		if spec.loadedHeld {
+			node.SetHeldResult(result)
+		} else {
+			node.SetResult(result)
+		}
+		logger.Info("Loaded share", "file", spec.share, "held_by_signer", spec.loadedHeld)
+	} else {
+		topic := rng.GroupTopic(dkg.Topic, spec.id)
+		for len(p2pNode.PubSub().ListPeers(topic)) != len(spec.nodes)-1 {
+			if !sleep(ctx, 100*time.Millisecond) {
+				return nil
+			}
+		}
+
+		logger.Info("All peers discovered")
+
+		if !sleep(ctx, time.Second) {
+			return nil
+		}
+
+		logger.Info("Starting DKG protocol")
+		node.StartDKG()
+
+		logger.Info("Waiting for DKG to finish")
+		var err error
+		result, err = node.WaitDKG()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if spec.id != "" {
+				return fmt.Errorf("DKG of group %s failed: %w", spec.id, err)
+			}
+			return fmt.Errorf("DKG failed: %w", err)
+		}
+	}
+
+	pubBytes, err := result.Key.Public().MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal public point: %w", err)
+	}
+
+	logger.Info("DKG finished", "public", hex.EncodeToString(pubBytes), "share", result.Key.Share.I)
+
+	return nil
+}
+
+// parseGroupKey returns the group public key of -group-key, nil without it.
+func parseGroupKey(scheme *crypto.Scheme, key string) (kyber.Point, error) {
+	if key == "" {
+		return nil, nil
+	}
+	data, err := dkg.HexToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode -group-key: %w", err)
+	}
+	point, err := scheme.PointFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal -group-key: %w", err)
+	}
+	return point, nil
+}
+
+// parseSchedule returns the beacon round schedule of -genesis and -period,
+// nil without -genesis.
+func parseSchedule(genesis string, period time.Duration) (*timelock.Schedule, error) {
+	if genesis == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, genesis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse -genesis: %w", err)
+	}
+	if period <= 0 {
+		return nil, errors.New("-period must be positive")
+	}
+	return &timelock.Schedule{Genesis: t, Period: period}, nil
+}
+
+// sleep waits for d, returning false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
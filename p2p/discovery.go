@@ -2,7 +2,7 @@ package p2p
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -12,13 +12,15 @@ const DiscoveryServiceTag = "random-network-mdns"
 
 // discoveryNotifee gets notified when we find a new peer via mDNS discovery
 type discoveryNotifee struct {
-	h host.Host
+	ctx context.Context
+	h   host.Host
+	log *slog.Logger
 }
 
 func (d *discoveryNotifee) HandlePeerFound(info peer.AddrInfo) {
-	log.Printf("Discovered new peer %s\n", info.ID)
-	err := d.h.Connect(context.Background(), info)
+	d.log.Info("Discovered new peer", "peer", info.ID)
+	err := d.h.Connect(d.ctx, info)
 	if err != nil {
-		log.Printf("Error connecting to peer %s: %s\n", info.ID, err)
+		d.log.Warn("Failed to connect to peer", "peer", info.ID, "err", err)
 	}
 }
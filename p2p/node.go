@@ -2,10 +2,13 @@ package p2p
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
@@ -17,20 +20,33 @@ type NodeP2P struct {
 	ps      *pubsub.PubSub
 }
 
-func NewNode(ctx context.Context) (*NodeP2P, error) {
-	h, err := libp2p.New()
+// NewNode starts a libp2p host with gossipsub and mDNS discovery. Gossipsub
+// stops once ctx is done, the host once Close is called. A nil identity
+// starts the host with a random one, a nil logger logs to slog.Default.
+func NewNode(ctx context.Context, identity crypto.PrivKey, logger *slog.Logger) (*NodeP2P, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var opts []libp2p.Option
+	if identity != nil {
+		opts = append(opts, libp2p.Identity(identity))
+	}
+
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create host: %w", err)
 	}
 
 	ps, err := pubsub.NewGossipSub(ctx, h)
 	if err != nil {
+		h.Close()
 		return nil, fmt.Errorf("failed to create pubsub: %w", err)
 	}
 
 	return &NodeP2P{
 		Host:    h,
-		service: mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{h: h}),
+		service: mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{ctx: ctx, h: h, log: logger.With("peer", h.ID())}),
 		ps:      ps,
 	}, nil
 }
@@ -46,3 +62,8 @@ func (n *NodeP2P) PubSub() *pubsub.PubSub {
 func (n *NodeP2P) ID() peer.ID {
 	return n.Host.ID()
 }
+
+// Close stops mDNS discovery and closes the host, dropping every connection.
+func (n *NodeP2P) Close() error {
+	return errors.Join(n.service.Close(), n.Host.Close())
+}
@@ -0,0 +1,5 @@
+// Package pb holds the protobuf wire format of the network, generated from
+// wire.proto.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative wire.proto
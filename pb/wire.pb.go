@@ -0,0 +1,866 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: wire.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Deal struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShareIndex     uint32                 `protobuf:"varint,1,opt,name=share_index,json=shareIndex,proto3" json:"share_index,omitempty"`
+	EncryptedShare []byte                 `protobuf:"bytes,2,opt,name=encrypted_share,json=encryptedShare,proto3" json:"encrypted_share,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Deal) Reset() {
+	*x = Deal{}
+	mi := &file_wire_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Deal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Deal) ProtoMessage() {}
+
+func (x *Deal) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Deal.ProtoReflect.Descriptor instead.
+func (*Deal) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Deal) GetShareIndex() uint32 {
+	if x != nil {
+		return x.ShareIndex
+	}
+	return 0
+}
+
+func (x *Deal) GetEncryptedShare() []byte {
+	if x != nil {
+		return x.EncryptedShare
+	}
+	return nil
+}
+
+type DealBundle struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DealerIndex   uint32                 `protobuf:"varint,1,opt,name=dealer_index,json=dealerIndex,proto3" json:"dealer_index,omitempty"`
+	Deals         []*Deal                `protobuf:"bytes,2,rep,name=deals,proto3" json:"deals,omitempty"`
+	Public        [][]byte               `protobuf:"bytes,3,rep,name=public,proto3" json:"public,omitempty"`
+	SessionId     []byte                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DealBundle) Reset() {
+	*x = DealBundle{}
+	mi := &file_wire_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DealBundle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DealBundle) ProtoMessage() {}
+
+func (x *DealBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DealBundle.ProtoReflect.Descriptor instead.
+func (*DealBundle) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DealBundle) GetDealerIndex() uint32 {
+	if x != nil {
+		return x.DealerIndex
+	}
+	return 0
+}
+
+func (x *DealBundle) GetDeals() []*Deal {
+	if x != nil {
+		return x.Deals
+	}
+	return nil
+}
+
+func (x *DealBundle) GetPublic() [][]byte {
+	if x != nil {
+		return x.Public
+	}
+	return nil
+}
+
+func (x *DealBundle) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *DealBundle) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type Response struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DealerIndex   uint32                 `protobuf:"varint,1,opt,name=dealer_index,json=dealerIndex,proto3" json:"dealer_index,omitempty"`
+	Status        int32                  `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	mi := &file_wire_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Response) GetDealerIndex() uint32 {
+	if x != nil {
+		return x.DealerIndex
+	}
+	return 0
+}
+
+func (x *Response) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+type ResponseBundle struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShareIndex    uint32                 `protobuf:"varint,1,opt,name=share_index,json=shareIndex,proto3" json:"share_index,omitempty"`
+	Responses     []*Response            `protobuf:"bytes,2,rep,name=responses,proto3" json:"responses,omitempty"`
+	SessionId     []byte                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseBundle) Reset() {
+	*x = ResponseBundle{}
+	mi := &file_wire_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseBundle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseBundle) ProtoMessage() {}
+
+func (x *ResponseBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseBundle.ProtoReflect.Descriptor instead.
+func (*ResponseBundle) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ResponseBundle) GetShareIndex() uint32 {
+	if x != nil {
+		return x.ShareIndex
+	}
+	return 0
+}
+
+func (x *ResponseBundle) GetResponses() []*Response {
+	if x != nil {
+		return x.Responses
+	}
+	return nil
+}
+
+func (x *ResponseBundle) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *ResponseBundle) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type Justification struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShareIndex    uint32                 `protobuf:"varint,1,opt,name=share_index,json=shareIndex,proto3" json:"share_index,omitempty"`
+	Share         []byte                 `protobuf:"bytes,2,opt,name=share,proto3" json:"share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Justification) Reset() {
+	*x = Justification{}
+	mi := &file_wire_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Justification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Justification) ProtoMessage() {}
+
+func (x *Justification) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Justification.ProtoReflect.Descriptor instead.
+func (*Justification) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Justification) GetShareIndex() uint32 {
+	if x != nil {
+		return x.ShareIndex
+	}
+	return 0
+}
+
+func (x *Justification) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+type JustificationBundle struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	DealerIndex    uint32                 `protobuf:"varint,1,opt,name=dealer_index,json=dealerIndex,proto3" json:"dealer_index,omitempty"`
+	Justifications []*Justification       `protobuf:"bytes,2,rep,name=justifications,proto3" json:"justifications,omitempty"`
+	SessionId      []byte                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Signature      []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *JustificationBundle) Reset() {
+	*x = JustificationBundle{}
+	mi := &file_wire_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JustificationBundle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JustificationBundle) ProtoMessage() {}
+
+func (x *JustificationBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JustificationBundle.ProtoReflect.Descriptor instead.
+func (*JustificationBundle) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *JustificationBundle) GetDealerIndex() uint32 {
+	if x != nil {
+		return x.DealerIndex
+	}
+	return 0
+}
+
+func (x *JustificationBundle) GetJustifications() []*Justification {
+	if x != nil {
+		return x.Justifications
+	}
+	return nil
+}
+
+func (x *JustificationBundle) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *JustificationBundle) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type BoardMessage struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Types that are valid to be assigned to Bundle:
+	//
+	//	*BoardMessage_Deal
+	//	*BoardMessage_Response
+	//	*BoardMessage_Justification
+	Bundle        isBoardMessage_Bundle `protobuf_oneof:"bundle"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BoardMessage) Reset() {
+	*x = BoardMessage{}
+	mi := &file_wire_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BoardMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoardMessage) ProtoMessage() {}
+
+func (x *BoardMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoardMessage.ProtoReflect.Descriptor instead.
+func (*BoardMessage) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BoardMessage) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *BoardMessage) GetBundle() isBoardMessage_Bundle {
+	if x != nil {
+		return x.Bundle
+	}
+	return nil
+}
+
+func (x *BoardMessage) GetDeal() *DealBundle {
+	if x != nil {
+		if x, ok := x.Bundle.(*BoardMessage_Deal); ok {
+			return x.Deal
+		}
+	}
+	return nil
+}
+
+func (x *BoardMessage) GetResponse() *ResponseBundle {
+	if x != nil {
+		if x, ok := x.Bundle.(*BoardMessage_Response); ok {
+			return x.Response
+		}
+	}
+	return nil
+}
+
+func (x *BoardMessage) GetJustification() *JustificationBundle {
+	if x != nil {
+		if x, ok := x.Bundle.(*BoardMessage_Justification); ok {
+			return x.Justification
+		}
+	}
+	return nil
+}
+
+type isBoardMessage_Bundle interface {
+	isBoardMessage_Bundle()
+}
+
+type BoardMessage_Deal struct {
+	Deal *DealBundle `protobuf:"bytes,2,opt,name=deal,proto3,oneof"`
+}
+
+type BoardMessage_Response struct {
+	Response *ResponseBundle `protobuf:"bytes,3,opt,name=response,proto3,oneof"`
+}
+
+type BoardMessage_Justification struct {
+	Justification *JustificationBundle `protobuf:"bytes,4,opt,name=justification,proto3,oneof"`
+}
+
+func (*BoardMessage_Deal) isBoardMessage_Bundle() {}
+
+func (*BoardMessage_Response) isBoardMessage_Bundle() {}
+
+func (*BoardMessage_Justification) isBoardMessage_Bundle() {}
+
+type SignVRF struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Sender        []byte                 `protobuf:"bytes,3,opt,name=sender,proto3" json:"sender,omitempty"`
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignVRF) Reset() {
+	*x = SignVRF{}
+	mi := &file_wire_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignVRF) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignVRF) ProtoMessage() {}
+
+func (x *SignVRF) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignVRF.ProtoReflect.Descriptor instead.
+func (*SignVRF) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SignVRF) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *SignVRF) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SignVRF) GetSender() []byte {
+	if x != nil {
+		return x.Sender
+	}
+	return nil
+}
+
+func (x *SignVRF) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type Signature struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	Rejected      string                 `protobuf:"bytes,4,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Signature) Reset() {
+	*x = Signature{}
+	mi := &file_wire_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Signature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Signature) ProtoMessage() {}
+
+func (x *Signature) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Signature.ProtoReflect.Descriptor instead.
+func (*Signature) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Signature) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Signature) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *Signature) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *Signature) GetRejected() string {
+	if x != nil {
+		return x.Rejected
+	}
+	return ""
+}
+
+type Envelope struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Network       string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	Topic         string                 `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	SenderIndex   uint32                 `protobuf:"varint,4,opt,name=sender_index,json=senderIndex,proto3" json:"sender_index,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,5,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,7,opt,name=payload,proto3" json:"payload,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_wire_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Envelope) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Envelope) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Envelope) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Envelope) GetSenderIndex() uint32 {
+	if x != nil {
+		return x.SenderIndex
+	}
+	return 0
+}
+
+func (x *Envelope) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Envelope) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Envelope) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Envelope) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+var File_wire_proto protoreflect.FileDescriptor
+
+const file_wire_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"wire.proto\x12\vrnn.wire.v1\"P\n" +
+	"\x04Deal\x12\x1f\n" +
+	"\vshare_index\x18\x01 \x01(\rR\n" +
+	"shareIndex\x12'\n" +
+	"\x0fencrypted_share\x18\x02 \x01(\fR\x0eencryptedShare\"\xad\x01\n" +
+	"\n" +
+	"DealBundle\x12!\n" +
+	"\fdealer_index\x18\x01 \x01(\rR\vdealerIndex\x12'\n" +
+	"\x05deals\x18\x02 \x03(\v2\x11.rnn.wire.v1.DealR\x05deals\x12\x16\n" +
+	"\x06public\x18\x03 \x03(\fR\x06public\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\fR\tsessionId\x12\x1c\n" +
+	"\tsignature\x18\x05 \x01(\fR\tsignature\"E\n" +
+	"\bResponse\x12!\n" +
+	"\fdealer_index\x18\x01 \x01(\rR\vdealerIndex\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\x05R\x06status\"\xa3\x01\n" +
+	"\x0eResponseBundle\x12\x1f\n" +
+	"\vshare_index\x18\x01 \x01(\rR\n" +
+	"shareIndex\x123\n" +
+	"\tresponses\x18\x02 \x03(\v2\x15.rnn.wire.v1.ResponseR\tresponses\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\fR\tsessionId\x12\x1c\n" +
+	"\tsignature\x18\x04 \x01(\fR\tsignature\"F\n" +
+	"\rJustification\x12\x1f\n" +
+	"\vshare_index\x18\x01 \x01(\rR\n" +
+	"shareIndex\x12\x14\n" +
+	"\x05share\x18\x02 \x01(\fR\x05share\"\xb9\x01\n" +
+	"\x13JustificationBundle\x12!\n" +
+	"\fdealer_index\x18\x01 \x01(\rR\vdealerIndex\x12B\n" +
+	"\x0ejustifications\x18\x02 \x03(\v2\x1a.rnn.wire.v1.JustificationR\x0ejustifications\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\fR\tsessionId\x12\x1c\n" +
+	"\tsignature\x18\x04 \x01(\fR\tsignature\"\xe6\x01\n" +
+	"\fBoardMessage\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12-\n" +
+	"\x04deal\x18\x02 \x01(\v2\x17.rnn.wire.v1.DealBundleH\x00R\x04deal\x129\n" +
+	"\bresponse\x18\x03 \x01(\v2\x1b.rnn.wire.v1.ResponseBundleH\x00R\bresponse\x12H\n" +
+	"\rjustification\x18\x04 \x01(\v2 .rnn.wire.v1.JustificationBundleH\x00R\rjustificationB\b\n" +
+	"\x06bundle\"n\n" +
+	"\aSignVRF\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x16\n" +
+	"\x06sender\x18\x03 \x01(\fR\x06sender\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\"~\n" +
+	"\tSignature\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\x12\x1a\n" +
+	"\brejected\x18\x04 \x01(\tR\brejected\"\xe9\x01\n" +
+	"\bEnvelope\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x14\n" +
+	"\x05topic\x18\x03 \x01(\tR\x05topic\x12!\n" +
+	"\fsender_index\x18\x04 \x01(\rR\vsenderIndex\x12\x1a\n" +
+	"\bsequence\x18\x05 \x01(\x04R\bsequence\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\apayload\x18\a \x01(\fR\apayload\x12\x1c\n" +
+	"\tsignature\x18\b \x01(\fR\tsignatureB\x17Z\x15random-network-poc/pbb\x06proto3"
+
+var (
+	file_wire_proto_rawDescOnce sync.Once
+	file_wire_proto_rawDescData []byte
+)
+
+func file_wire_proto_rawDescGZIP() []byte {
+	file_wire_proto_rawDescOnce.Do(func() {
+		file_wire_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_wire_proto_rawDesc), len(file_wire_proto_rawDesc)))
+	})
+	return file_wire_proto_rawDescData
+}
+
+var file_wire_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_wire_proto_goTypes = []any{
+	(*Deal)(nil),                // 0: rnn.wire.v1.Deal
+	(*DealBundle)(nil),          // 1: rnn.wire.v1.DealBundle
+	(*Response)(nil),            // 2: rnn.wire.v1.Response
+	(*ResponseBundle)(nil),      // 3: rnn.wire.v1.ResponseBundle
+	(*Justification)(nil),       // 4: rnn.wire.v1.Justification
+	(*JustificationBundle)(nil), // 5: rnn.wire.v1.JustificationBundle
+	(*BoardMessage)(nil),        // 6: rnn.wire.v1.BoardMessage
+	(*SignVRF)(nil),             // 7: rnn.wire.v1.SignVRF
+	(*Signature)(nil),           // 8: rnn.wire.v1.Signature
+	(*Envelope)(nil),            // 9: rnn.wire.v1.Envelope
+}
+var file_wire_proto_depIdxs = []int32{
+	0, // 0: rnn.wire.v1.DealBundle.deals:type_name -> rnn.wire.v1.Deal
+	2, // 1: rnn.wire.v1.ResponseBundle.responses:type_name -> rnn.wire.v1.Response
+	4, // 2: rnn.wire.v1.JustificationBundle.justifications:type_name -> rnn.wire.v1.Justification
+	1, // 3: rnn.wire.v1.BoardMessage.deal:type_name -> rnn.wire.v1.DealBundle
+	3, // 4: rnn.wire.v1.BoardMessage.response:type_name -> rnn.wire.v1.ResponseBundle
+	5, // 5: rnn.wire.v1.BoardMessage.justification:type_name -> rnn.wire.v1.JustificationBundle
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_wire_proto_init() }
+func file_wire_proto_init() {
+	if File_wire_proto != nil {
+		return
+	}
+	file_wire_proto_msgTypes[6].OneofWrappers = []any{
+		(*BoardMessage_Deal)(nil),
+		(*BoardMessage_Response)(nil),
+		(*BoardMessage_Justification)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_wire_proto_rawDesc), len(file_wire_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_wire_proto_goTypes,
+		DependencyIndexes: file_wire_proto_depIdxs,
+		MessageInfos:      file_wire_proto_msgTypes,
+	}.Build()
+	File_wire_proto = out.File
+	file_wire_proto_goTypes = nil
+	file_wire_proto_depIdxs = nil
+}
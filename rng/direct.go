@@ -0,0 +1,66 @@
+package rng
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// PartialProtocol carries a partial signature straight to the aggregator of
+// the round, the node that announced it on SignVrfInput. Each stream carries a
+// single sealed Signature. Groups other than the default one use their own
+// protocol ID, see GroupTopic.
+const PartialProtocol = protocol.ID("/rnn/rng/partial/1")
+
+const (
+	// maxPartialSize bounds a sealed Signature read from a stream.
+	maxPartialSize = 64 << 10
+	// partialTimeout bounds opening, writing and reading a partial stream.
+	partialTimeout = 5 * time.Second
+)
+
+// sendPartial delivers a sealed Signature to the aggregator.
+func (p *Protocol) sendPartial(aggregator peer.ID, data []byte) error {
+	ctx, cancel := context.WithTimeout(p.ctx, partialTimeout)
+	defer cancel()
+
+	s, err := p.host.NewStream(ctx, aggregator, p.partial)
+	if err != nil {
+		return fmt.Errorf("failed to open stream to %s: %w", aggregator, err)
+	}
+
+	s.SetDeadline(time.Now().Add(partialTimeout))
+
+	if _, err := s.Write(data); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to write partial: %w", err)
+	}
+
+	return s.Close()
+}
+
+// handlePartialStream reads a sealed Signature sent by a validator.
+func (p *Protocol) handlePartialStream(s network.Stream) {
+	defer s.Close()
+
+	s.SetReadDeadline(time.Now().Add(partialTimeout))
+
+	data, err := io.ReadAll(io.LimitReader(s, maxPartialSize+1))
+	if err != nil {
+		p.log.Warn("Failed to read partial", "from", s.Conn().RemotePeer(), "err", err)
+		s.Reset()
+		return
+	}
+	if len(data) > maxPartialSize {
+		p.log.Warn("Partial too large", "from", s.Conn().RemotePeer(), "limit", maxPartialSize)
+		s.Reset()
+		return
+	}
+
+	p.receiveSignature(data)
+}
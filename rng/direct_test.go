@@ -0,0 +1,279 @@
+package rng
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"random-network-poc/crypto"
+	"random-network-poc/envelope"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+// messageCounter counts the messages sent on the wire: pubsub messages per
+// topic, one per receiving peer, and partial streams.
+type messageCounter struct {
+	topics  map[string]*atomic.Int64
+	streams atomic.Int64
+}
+
+func newMessageCounter() *messageCounter {
+	return &messageCounter{topics: map[string]*atomic.Int64{
+		SignVrfInput:  new(atomic.Int64),
+		SignVrfOutput: new(atomic.Int64),
+	}}
+}
+
+func (c *messageCounter) SendRPC(rpc *pubsub.RPC, p peer.ID) {
+	for _, msg := range rpc.GetPublish() {
+		if count, ok := c.topics[msg.GetTopic()]; ok {
+			count.Add(1)
+		}
+	}
+}
+
+func (c *messageCounter) AddPeer(peer.ID, protocol.ID)          {}
+func (c *messageCounter) RemovePeer(peer.ID)                    {}
+func (c *messageCounter) Join(string)                           {}
+func (c *messageCounter) Leave(string)                          {}
+func (c *messageCounter) Graft(peer.ID, string)                 {}
+func (c *messageCounter) Prune(peer.ID, string)                 {}
+func (c *messageCounter) ValidateMessage(*pubsub.Message)       {}
+func (c *messageCounter) DeliverMessage(*pubsub.Message)        {}
+func (c *messageCounter) RejectMessage(*pubsub.Message, string) {}
+func (c *messageCounter) DuplicateMessage(*pubsub.Message)      {}
+func (c *messageCounter) ThrottlePeer(peer.ID)                  {}
+func (c *messageCounter) RecvRPC(*pubsub.RPC)                   {}
+func (c *messageCounter) DropRPC(*pubsub.RPC, peer.ID)          {}
+func (c *messageCounter) UndeliverableMessage(*pubsub.Message)  {}
+
+// testNetwork runs n validators on an in-memory network. Validator 0 is the
+// aggregator and receives the partials of every round on partials. Announced
+// rounds are bounded by limits, nil for no limit.
+type testNetwork struct {
+	protocols []*Protocol
+	counter   *messageCounter
+	partials  chan Signature
+}
+
+func newTestNetwork(t testing.TB, n int, limits *Limits) *testNetwork {
+	if limits == nil {
+		limits = &Limits{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mn, err := mocknet.FullMeshLinked(n)
+	require.NoError(t, err)
+	t.Cleanup(func() { mn.Close() })
+
+	scheme := crypto.DefaultScheme()
+	var keys []kyber.Scalar
+	var nodes []pedersen_dkg.Node
+	for i := 0; i < n; i++ {
+		key := scheme.KeyGroup.Scalar().Pick(random.New())
+		keys = append(keys, key)
+		nodes = append(nodes, pedersen_dkg.Node{
+			Index:  uint32(i),
+			Public: scheme.KeyGroup.Point().Mul(key, nil),
+		})
+	}
+
+	net := &testNetwork{
+		counter:  newMessageCounter(),
+		partials: make(chan Signature, 4*n),
+	}
+
+	for i, h := range mn.Hosts() {
+		ps, err := pubsub.NewGossipSub(ctx, h, pubsub.WithRawTracer(net.counter))
+		require.NoError(t, err)
+
+		codec := envelope.New(&envelope.Config{Scheme: scheme, Index: uint32(i), Longterm: keys[i], Nodes: nodes})
+
+		signVRF := func(vrf SignVRF) (Signature, error) {
+			return Signature{RequestID: vrf.RequestID, Signature: hex.EncodeToString([]byte{byte(i)})}, nil
+		}
+		signature := func(sig Signature) error {
+			if i == 0 {
+				select {
+				case net.partials <- sig:
+				default:
+				}
+			}
+			return nil
+		}
+
+		p, err := NewProtocol(ctx, ps, h, &Config{Envelope: codec, Limits: limits}, signVRF, signature)
+		require.NoError(t, err)
+
+		// count partial streams on their way to the handler
+		h.SetStreamHandler(PartialProtocol, func(s network.Stream) {
+			net.counter.streams.Add(1)
+			p.handlePartialStream(s)
+		})
+
+		net.protocols = append(net.protocols, p)
+	}
+
+	require.NoError(t, mn.ConnectAllButSelf())
+	require.Eventually(t, func() bool {
+		for _, p := range net.protocols {
+			if len(p.input.ListPeers()) != n-1 || len(p.output.ListPeers()) != n-1 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// let gossipsub open its streams and build the mesh
+	time.Sleep(time.Second)
+
+	return net
+}
+
+// disableDirect makes the aggregator unreachable through PartialProtocol, so
+// validators fall back to broadcasting partials.
+func (net *testNetwork) disableDirect() {
+	net.protocols[0].host.RemoveStreamHandler(PartialProtocol)
+}
+
+// round announces a round from the aggregator and waits for want partials.
+func (net *testNetwork) round(t testing.TB, requestID string, want int) []Signature {
+	require.NoError(t, net.protocols[0].Start(requestID, []byte(requestID)))
+
+	var partials []Signature
+	timeout := time.After(10 * time.Second)
+	for len(partials) < want {
+		select {
+		case sig := <-net.partials:
+			// late partials of earlier rounds
+			if sig.RequestID != requestID {
+				continue
+			}
+			partials = append(partials, sig)
+		case <-timeout:
+			t.Fatalf("received %d partials of round %s", len(partials), requestID)
+		}
+	}
+	return partials
+}
+
+func TestPartialsSentToAggregator(t *testing.T) {
+	net := newTestNetwork(t, 3, nil)
+
+	partials := net.round(t, "round-1", 2)
+
+	var senders []uint32
+	for _, sig := range partials {
+		senders = append(senders, sig.SenderIndex)
+	}
+	require.ElementsMatch(t, []uint32{1, 2}, senders)
+	require.Equal(t, int64(2), net.counter.streams.Load())
+	require.Zero(t, net.counter.topics[SignVrfOutput].Load())
+}
+
+func TestPartialsFallBackToPubsub(t *testing.T) {
+	net := newTestNetwork(t, 3, nil)
+	net.disableDirect()
+
+	net.round(t, "round-1", 2)
+
+	require.Zero(t, net.counter.streams.Load())
+	require.NotZero(t, net.counter.topics[SignVrfOutput].Load())
+}
+
+// BenchmarkPartials compares the messages sent per round when partials go
+// straight to the aggregator and when they are broadcast on SignVrfOutput.
+// Rounds wait for a majority of the partials, since broadcasting 64 of them
+// overflows pubsub queues and some are dropped.
+func BenchmarkPartials(b *testing.B) {
+	for _, n := range []int{3, 16, 64} {
+		for _, mode := range []string{"direct", "broadcast"} {
+			b.Run(fmt.Sprintf("%s/%d", mode, n), func(b *testing.B) {
+				net := newTestNetwork(b, n, nil)
+				if mode == "broadcast" {
+					net.disableDirect()
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					net.round(b, fmt.Sprintf("round-%d", i), n/2+1)
+				}
+				b.StopTimer()
+
+				// let broadcasts still in flight reach the mesh
+				time.Sleep(200 * time.Millisecond)
+
+				input := net.counter.topics[SignVrfInput].Load()
+				output := net.counter.topics[SignVrfOutput].Load() + net.counter.streams.Load()
+				b.ReportMetric(float64(input)/float64(b.N), "announce-msgs/op")
+				b.ReportMetric(float64(output)/float64(b.N), "partial-msgs/op")
+			})
+		}
+	}
+}
+
+func TestReplaysDropped(t *testing.T) {
+	net := newTestNetwork(t, 3, nil)
+	aggregator, validator := net.protocols[0], net.protocols[1]
+
+	announcements, err := validator.input.Subscribe()
+	require.NoError(t, err)
+
+	net.round(t, "round-1", 2)
+
+	msg, err := announcements.Next(context.Background())
+	require.NoError(t, err)
+
+	// a replayed announcement is not signed again
+	require.NoError(t, aggregator.input.Publish(context.Background(), msg.Data))
+	require.Eventually(t, func() bool {
+		return net.protocols[1].Counters().DuplicateSignVRF == 1 && net.protocols[2].Counters().DuplicateSignVRF == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(2), net.counter.streams.Load())
+
+	// a replayed partial is handled once
+	partial := Signature{RequestID: "round-2", Signature: "01"}
+	require.NoError(t, validator.sendSignature(aggregator.host.ID(), partial))
+	require.NoError(t, validator.sendSignature(aggregator.host.ID(), partial))
+	require.Eventually(t, func() bool {
+		return aggregator.Counters().DuplicateSignature == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, net.partials, 1)
+
+	aggregator.Finish("round-1")
+	require.ErrorIs(t, aggregator.Start("round-1", nil), ErrRequestFinished)
+
+	require.NoError(t, validator.sendSignature(aggregator.host.ID(), Signature{RequestID: "round-1", Signature: "01"}))
+	require.Eventually(t, func() bool {
+		return aggregator.Counters().Finished == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAnnouncementsRateLimited(t *testing.T) {
+	net := newTestNetwork(t, 3, &Limits{Rate: 0.001, Burst: 1})
+
+	net.round(t, "round-1", 2)
+
+	// both validators refuse the second round and tell the aggregator
+	rejections := net.round(t, "round-2", 2)
+	for _, sig := range rejections {
+		require.Empty(t, sig.Signature)
+		require.Contains(t, sig.Rejected, ErrRateLimited.Error())
+	}
+	require.Equal(t, uint64(1), net.protocols[1].Counters().RateLimited)
+	require.Equal(t, uint64(2), net.protocols[0].Counters().Rejected)
+}
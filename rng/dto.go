@@ -1,14 +1,72 @@
 package rng
 
-import "github.com/libp2p/go-libp2p/core/peer"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Limits of the RNG messages decoded from the network.
+const (
+	// MaxDataSize bounds the input of a round, well above what a client
+	// can send through the admin endpoint.
+	MaxDataSize = 4 << 10
+	// MaxRequestIDLength bounds request IDs, which are the hex input of
+	// their round.
+	MaxRequestIDLength = 2 * MaxDataSize
+	// MaxSignatureSize bounds a partial signature once decoded.
+	MaxSignatureSize = 256
+	// MaxReasonLength bounds the reason of a rejection.
+	MaxReasonLength = 256
+)
+
+// ErrTooLarge is returned for an RNG message beyond the limits above.
+var ErrTooLarge = errors.New("message exceeds limits")
 
 type SignVRF struct {
 	RequestID string
 	Sender    peer.ID
 	Data      string
+
+	// SenderIndex is the committee index of the sender, authenticated by
+	// the envelope on receipt.
+	SenderIndex uint32 `json:"-"`
 }
 
 type Signature struct {
 	RequestID string
 	Signature string
+	// Rejected is the reason the signer refused the request, set instead of
+	// Signature.
+	Rejected string `json:",omitempty"`
+
+	// SenderIndex is the committee index of the sender, authenticated by
+	// the envelope on receipt.
+	SenderIndex uint32 `json:"-"`
+}
+
+// checkLength fails for a field longer than max.
+func checkLength(what, s string, max int) error {
+	if len(s) > max {
+		return fmt.Errorf("%w: %s of %d bytes", ErrTooLarge, what, len(s))
+	}
+	return nil
+}
+
+func (v SignVRF) validate() error {
+	if err := checkLength("request ID", v.RequestID, MaxRequestIDLength); err != nil {
+		return err
+	}
+	return checkLength("data", v.Data, 2*MaxDataSize)
+}
+
+func (s Signature) validate() error {
+	if err := checkLength("request ID", s.RequestID, MaxRequestIDLength); err != nil {
+		return err
+	}
+	if err := checkLength("signature", s.Signature, 2*MaxSignatureSize); err != nil {
+		return err
+	}
+	return checkLength("rejection reason", s.Rejected, MaxReasonLength)
 }
@@ -0,0 +1,70 @@
+package rng
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"random-network-poc/wire"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The decoders below read untrusted network input. Every target checks that
+// what decodes stays within the limits and re-encodes to the same message.
+
+func FuzzDecodeSignVRF(f *testing.F) {
+	signVRF := SignVRF{
+		RequestID: "request",
+		Sender:    testPeerID(f),
+		Data:      hex.EncodeToString([]byte("block input")),
+	}
+	for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+		data, err := EncodeSignVRF(format, signVRF)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := DecodeSignVRF(data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(v.RequestID), MaxRequestIDLength)
+		require.LessOrEqual(t, len(v.Data), 2*MaxDataSize)
+
+		again, err := EncodeSignVRF(wire.Detect(data), v)
+		require.NoError(t, err)
+		got, err := DecodeSignVRF(again)
+		require.NoError(t, err)
+		require.Equal(t, v, got)
+	})
+}
+
+func FuzzDecodeSignature(f *testing.F) {
+	for _, s := range []Signature{
+		{RequestID: "request", Signature: hex.EncodeToString(make([]byte, 66))},
+		{RequestID: "request", Rejected: "rate limited"},
+	} {
+		for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+			data, err := EncodeSignature(format, s)
+			require.NoError(f, err)
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s, err := DecodeSignature(data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(s.RequestID), MaxRequestIDLength)
+		require.LessOrEqual(t, len(s.Signature), 2*MaxSignatureSize)
+		require.LessOrEqual(t, len(s.Rejected), MaxReasonLength)
+
+		again, err := EncodeSignature(wire.Detect(data), s)
+		require.NoError(t, err)
+		got, err := DecodeSignature(again)
+		require.NoError(t, err)
+		require.Equal(t, s, got)
+	})
+}
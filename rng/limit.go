@@ -0,0 +1,145 @@
+package rng
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"random-network-poc/clock"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRateLimited   = errors.New("request rate limit exceeded")
+	ErrQuotaExceeded = errors.New("request quota exceeded")
+)
+
+// Limits bounds the requests accepted from a single peer or client.
+type Limits struct {
+	// Rate is the sustained number of requests per second and Burst the
+	// number accepted at once. A zero Rate disables rate limiting.
+	Rate  float64
+	Burst int
+	// Quota is the number of requests accepted per QuotaWindow. A zero Quota
+	// disables the quota.
+	Quota       int
+	QuotaWindow time.Duration
+}
+
+// DefaultPeerLimits bounds the rounds a committee member can announce.
+var DefaultPeerLimits = Limits{
+	Rate:        1,
+	Burst:       10,
+	Quota:       3600,
+	QuotaWindow: time.Hour,
+}
+
+// DefaultClientLimits bounds the requests of a client of a node.
+var DefaultClientLimits = Limits{
+	Rate:        0.2,
+	Burst:       5,
+	Quota:       600,
+	QuotaWindow: time.Hour,
+}
+
+// Limiter applies Limits to every key on its own, so one noisy peer or client
+// cannot use up the requests of the others.
+type Limiter[K comparable] struct {
+	limits Limits
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[K]*limitEntry
+	lastSweep time.Time
+}
+
+type limitEntry struct {
+	tokens      *rate.Limiter
+	windowStart time.Time
+	used        int
+	lastSeen    time.Time
+}
+
+func NewLimiter[K comparable](limits Limits) *Limiter[K] {
+	return &Limiter[K]{
+		limits:  limits,
+		now:     time.Now,
+		entries: make(map[K]*limitEntry),
+	}
+}
+
+// SetClock replaces the wall clock the limits are measured with. It is
+// meant to be called before the limiter is used.
+func (l *Limiter[K]) SetClock(c clock.Clock) {
+	l.now = c.Now
+}
+
+// Allow takes one request of key from its budget, or returns
+// ErrRateLimited or ErrQuotaExceeded.
+func (l *Limiter[K]) Allow(key K) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &limitEntry{windowStart: now}
+		if l.limits.Rate > 0 {
+			e.tokens = rate.NewLimiter(rate.Limit(l.limits.Rate), l.limits.Burst)
+		}
+		l.entries[key] = e
+	}
+	e.lastSeen = now
+
+	if l.limits.Quota > 0 {
+		if now.Sub(e.windowStart) >= l.limits.QuotaWindow {
+			e.windowStart = now
+			e.used = 0
+		}
+		if e.used >= l.limits.Quota {
+			return fmt.Errorf("%w: %d requests per %s", ErrQuotaExceeded, l.limits.Quota, l.limits.QuotaWindow)
+		}
+	}
+
+	if e.tokens != nil && !e.tokens.AllowN(now, 1) {
+		return fmt.Errorf("%w: %g requests per second", ErrRateLimited, l.limits.Rate)
+	}
+
+	e.used++
+	return nil
+}
+
+// idleTimeout is how long an entry is kept once its key stops sending.
+func (l *Limiter[K]) idleTimeout() time.Duration {
+	idle := time.Minute
+	if l.limits.QuotaWindow > idle {
+		idle = l.limits.QuotaWindow
+	}
+	if l.limits.Rate > 0 {
+		// time for the bucket to fill up again
+		if refill := time.Duration(float64(l.limits.Burst) / l.limits.Rate * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+	return idle
+}
+
+// sweep drops entries idle for long enough to be back to a full budget, at
+// most once per minute.
+func (l *Limiter[K]) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	idle := l.idleTimeout()
+	for k, e := range l.entries {
+		if now.Sub(e.lastSeen) >= idle {
+			delete(l.entries, k)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package rng
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter[string](Limits{Rate: 1, Burst: 2, Quota: 3, QuotaWindow: time.Hour})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Allow("noisy"))
+	require.NoError(t, l.Allow("noisy"))
+	require.ErrorIs(t, l.Allow("noisy"), ErrRateLimited)
+
+	// keys have their own budget
+	require.NoError(t, l.Allow("quiet"))
+
+	now = now.Add(time.Second)
+	require.NoError(t, l.Allow("noisy"))
+	now = now.Add(time.Second)
+	require.ErrorIs(t, l.Allow("noisy"), ErrQuotaExceeded)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, l.Allow("noisy"))
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := NewLimiter[uint32](Limits{})
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, l.Allow(1))
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter[string](Limits{Rate: 1, Burst: 10})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Allow("gone"))
+	now = now.Add(time.Minute)
+	require.NoError(t, l.Allow("active"))
+	require.Len(t, l.entries, 1)
+}
@@ -0,0 +1,148 @@
+package rng
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"random-network-poc/pb"
+	"random-network-poc/wire"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// SignVRFToProto converts a SignVRF to its protobuf form
+func SignVRFToProto(v SignVRF) (*pb.SignVRF, error) {
+	data, err := hex.DecodeString(v.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	return &pb.SignVRF{
+		Version:   wire.Version,
+		RequestId: v.RequestID,
+		Sender:    []byte(v.Sender),
+		Data:      data,
+	}, nil
+}
+
+// SignVRFFromProto converts a protobuf SignVRF to a SignVRF
+func SignVRFFromProto(p *pb.SignVRF) (SignVRF, error) {
+	if p.Version != wire.Version {
+		return SignVRF{}, fmt.Errorf("unsupported wire version %d", p.Version)
+	}
+
+	sender, err := peer.IDFromBytes(p.Sender)
+	if err != nil {
+		return SignVRF{}, fmt.Errorf("failed to decode sender: %w", err)
+	}
+
+	return SignVRF{
+		RequestID: p.RequestId,
+		Sender:    sender,
+		Data:      hex.EncodeToString(p.Data),
+	}, nil
+}
+
+// SignatureToProto converts a Signature to its protobuf form
+func SignatureToProto(s Signature) (*pb.Signature, error) {
+	sig, err := hex.DecodeString(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	return &pb.Signature{
+		Version:   wire.Version,
+		RequestId: s.RequestID,
+		Signature: sig,
+		Rejected:  s.Rejected,
+	}, nil
+}
+
+// SignatureFromProto converts a protobuf Signature to a Signature
+func SignatureFromProto(p *pb.Signature) (Signature, error) {
+	if p.Version != wire.Version {
+		return Signature{}, fmt.Errorf("unsupported wire version %d", p.Version)
+	}
+
+	return Signature{
+		RequestID: p.RequestId,
+		Signature: hex.EncodeToString(p.Signature),
+		Rejected:  p.Rejected,
+	}, nil
+}
+
+// EncodeSignVRF encodes a SignVRF in the given format
+func EncodeSignVRF(f wire.Format, v SignVRF) ([]byte, error) {
+	if f == wire.FormatJSON {
+		return json.Marshal(v)
+	}
+
+	p, err := SignVRFToProto(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(p)
+}
+
+// DecodeSignVRF decodes a SignVRF in either format
+func DecodeSignVRF(data []byte) (SignVRF, error) {
+	var v SignVRF
+	if wire.Detect(data) == wire.FormatJSON {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return SignVRF{}, err
+		}
+	} else {
+		p := new(pb.SignVRF)
+		if err := proto.Unmarshal(data, p); err != nil {
+			return SignVRF{}, err
+		}
+		var err error
+		if v, err = SignVRFFromProto(p); err != nil {
+			return SignVRF{}, err
+		}
+	}
+
+	if err := v.validate(); err != nil {
+		return SignVRF{}, err
+	}
+	return v, nil
+}
+
+// EncodeSignature encodes a Signature in the given format
+func EncodeSignature(f wire.Format, s Signature) ([]byte, error) {
+	if f == wire.FormatJSON {
+		return json.Marshal(s)
+	}
+
+	p, err := SignatureToProto(s)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(p)
+}
+
+// DecodeSignature decodes a Signature in either format
+func DecodeSignature(data []byte) (Signature, error) {
+	var s Signature
+	if wire.Detect(data) == wire.FormatJSON {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return Signature{}, err
+		}
+	} else {
+		p := new(pb.Signature)
+		if err := proto.Unmarshal(data, p); err != nil {
+			return Signature{}, err
+		}
+		var err error
+		if s, err = SignatureFromProto(p); err != nil {
+			return Signature{}, err
+		}
+	}
+
+	if err := s.validate(); err != nil {
+		return Signature{}, err
+	}
+	return s, nil
+}
@@ -0,0 +1,98 @@
+package rng
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"random-network-poc/wire"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func testPeerID(t testing.TB) peer.ID {
+	_, pub, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	id, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	return id
+}
+
+func TestMessagesRoundTrip(t *testing.T) {
+	signVRF := SignVRF{
+		RequestID: "request",
+		Sender:    testPeerID(t),
+		Data:      hex.EncodeToString([]byte("block input")),
+	}
+	signature := Signature{
+		RequestID: "request",
+		Signature: hex.EncodeToString(make([]byte, 66)),
+	}
+
+	for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+		t.Run(format.String(), func(t *testing.T) {
+			data, err := EncodeSignVRF(format, signVRF)
+			require.NoError(t, err)
+			require.Equal(t, format, wire.Detect(data))
+			gotVRF, err := DecodeSignVRF(data)
+			require.NoError(t, err)
+			require.Equal(t, signVRF, gotVRF)
+
+			data, err = EncodeSignature(format, signature)
+			require.NoError(t, err)
+			require.Equal(t, format, wire.Detect(data))
+			gotSig, err := DecodeSignature(data)
+			require.NoError(t, err)
+			require.Equal(t, signature, gotSig)
+		})
+	}
+}
+
+func TestMessagesSize(t *testing.T) {
+	signature := Signature{
+		RequestID: hex.EncodeToString(make([]byte, 32)),
+		Signature: hex.EncodeToString(make([]byte, 66)),
+	}
+
+	jsonData, err := EncodeSignature(wire.FormatJSON, signature)
+	require.NoError(t, err)
+	protoData, err := EncodeSignature(wire.FormatProtobuf, signature)
+	require.NoError(t, err)
+	require.Less(t, len(protoData), len(jsonData))
+}
+
+func TestDecodeRejectsOtherVersions(t *testing.T) {
+	_, err := DecodeSignature([]byte{0x08, 0x02})
+	require.Error(t, err)
+	_, err = DecodeSignVRF([]byte{0x08, 0x02})
+	require.Error(t, err)
+}
+
+func TestDecodeLimits(t *testing.T) {
+	large := strings.Repeat("a", 2*MaxDataSize+2)
+
+	for _, format := range []wire.Format{wire.FormatJSON, wire.FormatProtobuf} {
+		for _, v := range []SignVRF{
+			{RequestID: large, Sender: testPeerID(t)},
+			{RequestID: "request", Sender: testPeerID(t), Data: large},
+		} {
+			data, err := EncodeSignVRF(format, v)
+			require.NoError(t, err)
+			_, err = DecodeSignVRF(data)
+			require.ErrorIs(t, err, ErrTooLarge, "%s", format)
+		}
+
+		for _, s := range []Signature{
+			{RequestID: large},
+			{RequestID: "request", Signature: strings.Repeat("00", MaxSignatureSize+1)},
+			{RequestID: "request", Rejected: strings.Repeat("a", MaxReasonLength+1)},
+		} {
+			data, err := EncodeSignature(format, s)
+			require.NoError(t, err)
+			_, err = DecodeSignature(data)
+			require.ErrorIs(t, err, ErrTooLarge, "%s", format)
+		}
+	}
+}
@@ -3,12 +3,23 @@ package rng
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"random-network-poc/clock"
+	"random-network-poc/envelope"
+	"random-network-poc/metrics"
+	"random-network-poc/pb"
+	"random-network-poc/wire"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 const (
@@ -16,75 +27,237 @@ const (
 	SignVrfOutput = "sign_vrf_output"
 )
 
+// GroupTopic returns the name of a topic or protocol for the committee group:
+// name itself for the default group "", and name/group otherwise, so
+// committees sharing a host never see each other's messages.
+func GroupTopic(name, group string) string {
+	if group == "" {
+		return name
+	}
+	return name + "/" + group
+}
+
+var (
+	ErrRequestFinished = errors.New("request already finished")
+	ErrStaleEnvelope   = errors.New("envelope sealed too far from now")
+)
+
 type HandleSignVRF func(SignVRF) (Signature, error)
+
+// HandleSignature handles a partial signature, or the refusal of a signer
+// when Rejected is set.
 type HandleSignature func(Signature) error
 
+// Transport carries the rounds of a node: it announces them to the committee
+// and hands the signatures coming back to HandleSignature. Protocol is the
+// libp2p transport, simulations provide their own.
+type Transport interface {
+	// Start announces a round over data.
+	Start(requestID string, data []byte) error
+	// Finish drops the later messages of a request.
+	Finish(requestID string)
+	// Counters returns the number of messages dropped as replays so far.
+	Counters() Counters
+	Close() error
+}
+
+var _ Transport = (*Protocol)(nil)
+
 type Protocol struct {
-	self peer.ID
-	ctx  context.Context
-	ps   *pubsub.PubSub
+	host     host.Host
+	ctx      context.Context
+	cancel   context.CancelFunc
+	loops    sync.WaitGroup
+	ps       *pubsub.PubSub
+	wire     *wire.Negotiator
+	envelope *envelope.Codec
+	clock    clock.Clock
+	log      *slog.Logger
+	metrics  *metrics.Metrics
 
-	input  *pubsub.Topic
-	output *pubsub.Topic
+	input   *pubsub.Topic
+	output  *pubsub.Topic
+	partial protocol.ID
 
 	subIn  *pubsub.Subscription
 	subOut *pubsub.Subscription
 
 	handleSignVRF   HandleSignVRF
 	handleSignature HandleSignature
+
+	// signed holds the announcements signed by this node and received the
+	// partials received, both keyed by request ID and sender index.
+	signed   *seenCache[seenKey]
+	received *seenCache[seenKey]
+	finished *seenCache[string]
+
+	// limiter bounds the rounds announced by each committee member.
+	limiter *Limiter[uint32]
+
+	duplicateSignVRF   atomic.Uint64
+	duplicateSignature atomic.Uint64
+	finishedMessages   atomic.Uint64
+	rateLimited        atomic.Uint64
+	rejected           atomic.Uint64
+}
+
+// Counters counts the messages dropped as replays.
+type Counters struct {
+	// DuplicateSignVRF counts announcements already signed.
+	DuplicateSignVRF uint64
+	// DuplicateSignature counts partials already received.
+	DuplicateSignature uint64
+	// Finished counts messages of finished requests.
+	Finished uint64
+	// RateLimited counts announcements this node refused to sign.
+	RateLimited uint64
+	// Rejected counts refusals received for rounds of this node.
+	Rejected uint64
 }
 
-func NewProtocol(ctx context.Context, ps *pubsub.PubSub, self peer.ID, handleSignVRF HandleSignVRF, handleSignature HandleSignature) (*Protocol, error) {
-	input, err := ps.Join(SignVrfInput)
+// Config holds the settings of a Protocol.
+type Config struct {
+	// Envelope seals and opens every message.
+	Envelope *envelope.Codec
+	// Wire picks the format of sent messages, nil sends JSON.
+	Wire *wire.Negotiator
+	// Limits bounds the rounds announced by each committee member, defaults
+	// to DefaultPeerLimits.
+	Limits *Limits
+	// Logger defaults to slog.Default.
+	Logger *slog.Logger
+	// Metrics records received partials and dropped messages, nil records
+	// nothing.
+	Metrics *metrics.Metrics
+	// Clock ages envelopes and times the replay caches and Limits, defaults
+	// to the wall clock.
+	Clock clock.Clock
+	// Group names the committee of the protocol, see GroupTopic. Empty for
+	// the default group.
+	Group string
+}
+
+// NewProtocol joins the RNG topics and registers PartialProtocol on h, all of
+// the group of c. Rounds are announced on SignVrfInput and partial signatures
+// are sent straight to the announcing node, falling back to SignVrfOutput
+// when it cannot be reached. The protocol stops once ctx is done or Close is
+// called.
+func NewProtocol(ctx context.Context, ps *pubsub.PubSub, h host.Host, c *Config, handleSignVRF HandleSignVRF, handleSignature HandleSignature) (*Protocol, error) {
+	limits := c.Limits
+	if limits == nil {
+		limits = &DefaultPeerLimits
+	}
+
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	clk := c.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+
+	inputTopic := GroupTopic(SignVrfInput, c.Group)
+	outputTopic := GroupTopic(SignVrfOutput, c.Group)
+
+	input, err := ps.Join(inputTopic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join topic %s: %w", SignVrfInput, err)
+		return nil, fmt.Errorf("failed to join topic %s: %w", inputTopic, err)
 	}
 
-	output, err := ps.Join(SignVrfOutput)
+	output, err := ps.Join(outputTopic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join topic %s: %w", SignVrfOutput, err)
+		return nil, fmt.Errorf("failed to join topic %s: %w", outputTopic, err)
 	}
 
 	subIn, err := input.Subscribe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", SignVrfInput, err)
+		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", inputTopic, err)
 	}
 
 	subOut, err := output.Subscribe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", SignVrfOutput, err)
+		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", outputTopic, err)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
 	p := &Protocol{
 		ctx:             ctx,
+		cancel:          cancel,
 		ps:              ps,
-		self:            self,
+		host:            h,
+		wire:            c.Wire,
+		envelope:        c.Envelope,
+		clock:           clk,
+		log:             logger.With("peer", h.ID()),
+		metrics:         c.Metrics,
 		input:           input,
 		output:          output,
+		partial:         protocol.ID(GroupTopic(string(PartialProtocol), c.Group)),
 		subIn:           subIn,
 		subOut:          subOut,
 		handleSignVRF:   handleSignVRF,
 		handleSignature: handleSignature,
+		signed:          newSeenCache[seenKey](seenTTL, clk),
+		received:        newSeenCache[seenKey](seenTTL, clk),
+		finished:        newSeenCache[string](seenTTL, clk),
+		limiter:         NewLimiter[uint32](*limits),
 	}
+	p.limiter.SetClock(clk)
+
+	h.SetStreamHandler(p.partial, p.handlePartialStream)
+	c.Metrics.WatchTopics(ps, inputTopic, outputTopic)
 
+	p.loops.Add(2)
 	go p.readSubIn()
 	go p.readSubOut()
 
 	return p, nil
 }
 
+// Close stops the protocol: it unregisters PartialProtocol, waits for the
+// read loops to return and leaves the RNG topics. Leaving is skipped once the
+// pubsub itself is stopped.
+func (p *Protocol) Close() error {
+	p.host.RemoveStreamHandler(p.partial)
+	p.cancel()
+	p.loops.Wait()
+
+	p.subIn.Cancel()
+	p.subOut.Cancel()
+
+	var errs []error
+	for _, topic := range []*pubsub.Topic{p.input, p.output} {
+		if err := topic.Close(); err != nil && !errors.Is(err, context.Canceled) {
+			errs = append(errs, fmt.Errorf("failed to leave topic %s: %w", topic.String(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (p *Protocol) Start(requestID string, data []byte) error {
+	if p.finished.contains(requestID) {
+		return fmt.Errorf("%w: %s", ErrRequestFinished, requestID)
+	}
+
 	signVRF := SignVRF{
 		RequestID: requestID,
-		Sender:    p.self,
+		Sender:    p.host.ID(),
 		Data:      hex.EncodeToString(data),
 	}
 
-	data, err := json.Marshal(signVRF)
+	data, err := EncodeSignVRF(p.wire.Format(p.input.ListPeers()), signVRF)
 	if err != nil {
 		return fmt.Errorf("failed to marshal signVRF: %w", err)
 	}
 
+	data, err = p.envelope.Seal(p.input.String(), data)
+	if err != nil {
+		return fmt.Errorf("failed to seal signVRF: %w", err)
+	}
+
 	if err := p.input.Publish(p.ctx, data); err != nil {
 		return fmt.Errorf("failed to publish signVRF: %w", err)
 	}
@@ -93,65 +266,194 @@ func (p *Protocol) Start(requestID string, data []byte) error {
 }
 
 func (p *Protocol) readSubIn() {
+	defer p.loops.Done()
+
 	for {
 		msg, err := p.subIn.Next(p.ctx)
 		if err != nil {
-			log.Printf("Error reading message: %s\n", err)
+			if p.ctx.Err() == nil {
+				p.log.Error("Failed to read message", "topic", p.input.String(), "err", err)
+			}
 			return
 		}
 
-		if msg.ReceivedFrom == p.self {
+		if msg.ReceivedFrom == p.host.ID() {
 			continue
 		}
 
-		var signVRF SignVRF
-
-		if err := json.Unmarshal(msg.Data, &signVRF); err != nil {
-			log.Printf("Error unmarshalling message: %s\n", err)
+		e, err := p.open(p.input.String(), msg.Data)
+		if err != nil {
+			p.log.Warn("Failed to open envelope", "topic", p.input.String(), "from", msg.ReceivedFrom, "err", err)
 			continue
 		}
 
-		signature, err := p.handleSignVRF(signVRF)
+		signVRF, err := DecodeSignVRF(e.Payload)
 		if err != nil {
-			log.Printf("Error handling signVRF: %s\n", err)
+			p.log.Warn("Failed to decode signVRF", "sender", e.SenderIndex, "err", err)
+			continue
+		}
+		signVRF.SenderIndex = e.SenderIndex
+
+		// the aggregator is the origin of the announcement, which pubsub
+		// authenticates
+		if signVRF.Sender != msg.GetFrom() {
+			p.log.Warn("SignVRF sender is not its publisher", "request", signVRF.RequestID, "sender", signVRF.Sender, "publisher", msg.GetFrom())
+			continue
+		}
+
+		if p.finished.contains(signVRF.RequestID) {
+			p.finishedMessages.Add(1)
+			p.metrics.Dropped("finished")
+			continue
+		}
+		if !p.signed.add(seenKey{requestID: signVRF.RequestID, index: signVRF.SenderIndex}) {
+			p.duplicateSignVRF.Add(1)
+			p.metrics.Dropped("duplicate_signvrf")
+			continue
+		}
+
+		if err := p.limiter.Allow(signVRF.SenderIndex); err != nil {
+			p.rateLimited.Add(1)
+			p.metrics.Dropped("rate_limited")
+			rejection := Signature{RequestID: signVRF.RequestID, Rejected: err.Error()}
+			if err := p.sendSignature(signVRF.Sender, rejection); err != nil {
+				p.log.Error("Failed to send rejection", "request", signVRF.RequestID, "err", err)
+			}
 			continue
 		}
 
-		send, err := json.Marshal(&signature)
+		signature, err := p.handleSignVRF(signVRF)
 		if err != nil {
-			log.Printf("Error marshalling signature: %s\n", err)
+			p.log.Error("Failed to sign", "request", signVRF.RequestID, "sender", signVRF.SenderIndex, "err", err)
 			continue
 		}
 
-		if err := p.output.Publish(p.ctx, send); err != nil {
-			log.Printf("Error publishing message: %s\n", err)
+		if err := p.sendSignature(signVRF.Sender, signature); err != nil {
+			p.log.Error("Failed to send signature", "request", signVRF.RequestID, "err", err)
 			continue
 		}
 	}
 }
 
+// sendSignature sends a partial signature to the aggregator of the round, or
+// publishes it on SignVrfOutput when the aggregator cannot be reached
+// directly.
+func (p *Protocol) sendSignature(aggregator peer.ID, signature Signature) error {
+	data, err := EncodeSignature(p.wire.Format([]peer.ID{aggregator}), signature)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature: %w", err)
+	}
+
+	data, err = p.envelope.Seal(p.output.String(), data)
+	if err != nil {
+		return fmt.Errorf("failed to seal signature: %w", err)
+	}
+
+	err = p.sendPartial(aggregator, data)
+	if err == nil {
+		return nil
+	}
+	p.log.Warn("Failed to send partial directly, publishing it", "request", signature.RequestID, "aggregator", aggregator, "err", err)
+
+	if err := p.output.Publish(p.ctx, data); err != nil {
+		return fmt.Errorf("failed to publish signature: %w", err)
+	}
+
+	return nil
+}
+
 func (p *Protocol) readSubOut() {
+	defer p.loops.Done()
+
 	for {
 		msg, err := p.subOut.Next(p.ctx)
 		if err != nil {
-			log.Printf("Error reading message: %s\n", err)
+			if p.ctx.Err() == nil {
+				p.log.Error("Failed to read message", "topic", p.output.String(), "err", err)
+			}
 			return
 		}
 
-		if msg.ReceivedFrom == p.self {
+		if msg.ReceivedFrom == p.host.ID() {
 			continue
 		}
 
-		var signature Signature
+		p.receiveSignature(msg.Data)
+	}
+}
 
-		if err := json.Unmarshal(msg.Data, &signature); err != nil {
-			log.Printf("Error unmarshalling message: %s\n", err)
-			continue
-		}
+// receiveSignature handles a sealed partial signature, received directly or
+// on SignVrfOutput.
+func (p *Protocol) receiveSignature(data []byte) {
+	e, err := p.open(p.output.String(), data)
+	if err != nil {
+		p.log.Warn("Failed to open envelope", "topic", p.output.String(), "err", err)
+		return
+	}
 
-		if err := p.handleSignature(signature); err != nil {
-			log.Printf("Error handling signature: %s\n", err)
-			continue
-		}
+	signature, err := DecodeSignature(e.Payload)
+	if err != nil {
+		p.log.Warn("Failed to decode signature", "sender", e.SenderIndex, "err", err)
+		return
+	}
+	signature.SenderIndex = e.SenderIndex
+	p.metrics.PartialReceived()
+
+	if p.finished.contains(signature.RequestID) {
+		p.finishedMessages.Add(1)
+		p.metrics.Dropped("finished")
+		return
+	}
+	if !p.received.add(seenKey{requestID: signature.RequestID, index: signature.SenderIndex}) {
+		p.duplicateSignature.Add(1)
+		p.metrics.Dropped("duplicate_signature")
+		return
+	}
+
+	if signature.Rejected != "" {
+		p.rejected.Add(1)
+		p.log.Warn("Request rejected", "request", signature.RequestID, "signer", signature.SenderIndex, "reason", signature.Rejected)
+	}
+
+	if err := p.handleSignature(signature); err != nil {
+		p.log.Warn("Failed to handle signature", "request", signature.RequestID, "signer", signature.SenderIndex, "err", err)
+	}
+}
+
+// open opens an envelope and rejects it if it was sealed more than seenTTL
+// away from now, since the seen caches no longer remember it.
+func (p *Protocol) open(topic string, data []byte) (*pb.Envelope, error) {
+	e, err := p.envelope.Open(topic, data)
+	if errors.Is(err, envelope.ErrStale) {
+		p.metrics.Dropped("stale")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the codec may be configured with a longer window than the caches
+	sealed := time.Unix(0, e.Timestamp)
+	if age := p.clock.Now().Sub(sealed); age >= seenTTL || age <= -seenTTL {
+		p.metrics.Dropped("stale")
+		return nil, fmt.Errorf("%w: %s", ErrStaleEnvelope, sealed)
+	}
+
+	return e, nil
+}
+
+// Finish marks a request as finished. Its messages are dropped and its ID
+// cannot be started again until seenTTL has passed.
+func (p *Protocol) Finish(requestID string) {
+	p.finished.add(requestID)
+}
+
+// Counters returns the number of messages dropped as replays so far.
+func (p *Protocol) Counters() Counters {
+	return Counters{
+		DuplicateSignVRF:   p.duplicateSignVRF.Load(),
+		DuplicateSignature: p.duplicateSignature.Load(),
+		Finished:           p.finishedMessages.Load(),
+		RateLimited:        p.rateLimited.Load(),
+		Rejected:           p.rejected.Load(),
 	}
 }
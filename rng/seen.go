@@ -0,0 +1,74 @@
+package rng
+
+import (
+	"sync"
+	"time"
+
+	"random-network-poc/clock"
+)
+
+// seenTTL is how long request IDs and partials are remembered. Envelopes
+// older than seenTTL are rejected, so a replay cannot outlive its entry.
+const seenTTL = 10 * time.Minute
+
+// seenKey identifies a message of a round by the committee index of its
+// signer.
+type seenKey struct {
+	requestID string
+	index     uint32
+}
+
+// seenCache remembers keys for a bounded time.
+type seenCache[K comparable] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	entries   map[K]time.Time
+	lastSweep time.Time
+}
+
+func newSeenCache[K comparable](ttl time.Duration, c clock.Clock) *seenCache[K] {
+	return &seenCache[K]{
+		ttl:     ttl,
+		now:     c.Now,
+		entries: make(map[K]time.Time),
+	}
+}
+
+// add records k and reports whether it was not seen within the TTL.
+func (c *seenCache[K]) add(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	if expiry, ok := c.entries[k]; ok && now.Before(expiry) {
+		return false
+	}
+	c.entries[k] = now.Add(c.ttl)
+	return true
+}
+
+// contains reports whether k was seen within the TTL.
+func (c *seenCache[K]) contains(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiry, ok := c.entries[k]
+	return ok && c.now().Before(expiry)
+}
+
+// sweep drops expired entries, at most once per TTL.
+func (c *seenCache[K]) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+
+	for k, expiry := range c.entries {
+		if !now.Before(expiry) {
+			delete(c.entries, k)
+		}
+	}
+}
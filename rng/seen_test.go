@@ -0,0 +1,28 @@
+package rng
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeenCache(t *testing.T) {
+	v := clock.NewVirtual(time.Unix(0, 0))
+	c := newSeenCache[seenKey](time.Minute, v)
+
+	k := seenKey{requestID: "round-1", index: 1}
+	require.True(t, c.add(k))
+	require.False(t, c.add(k))
+	require.True(t, c.contains(k))
+	require.True(t, c.add(seenKey{requestID: "round-1", index: 2}))
+
+	v.Advance(time.Minute)
+	require.False(t, c.contains(k))
+	require.True(t, c.add(k))
+
+	// the sweep dropped the other expired entry
+	require.Len(t, c.entries, 1)
+}
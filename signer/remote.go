@@ -0,0 +1,133 @@
+package signer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4/share"
+)
+
+// serviceName is the net/rpc name of the signer service.
+const serviceName = "Signer"
+
+// service exposes a Signer over net/rpc.
+type service struct {
+	scheme *crypto.Scheme
+	signer Signer
+}
+
+// PartialRequest is the argument of SignPartial over net/rpc.
+type PartialRequest struct {
+	Purpose string
+	Msg     []byte
+}
+
+func (s *service) SignPartial(req PartialRequest, sig *[]byte) error {
+	var err error
+	*sig, err = s.signer.SignPartial(req.Purpose, req.Msg)
+	return err
+}
+
+func (s *service) SignLongterm(msg []byte, sig *[]byte) error {
+	var err error
+	*sig, err = s.signer.SignLongterm(msg)
+	return err
+}
+
+func (s *service) SetShare(data []byte, _ *bool) error {
+	if len(data) < 4 {
+		return errors.New("share too short")
+	}
+	v := s.scheme.KeyGroup.Scalar()
+	if err := v.UnmarshalBinary(data[4:]); err != nil {
+		return fmt.Errorf("failed to unmarshal share: %w", err)
+	}
+	defer v.Zero()
+
+	return s.signer.SetShare(&share.PriShare{I: binary.BigEndian.Uint32(data), V: v})
+}
+
+// Serve serves s to the connections accepted on l until l is closed. Anyone
+// able to connect can sign, so l should be a Unix socket only the validator
+// can reach.
+func Serve(l net.Listener, scheme *crypto.Scheme, s Signer) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName, &service{scheme: scheme, signer: s}); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// Remote signs with a signer served on a Unix socket. A signer serving a
+// Stored signer keeps its share across restarts.
+type Remote struct {
+	client *rpc.Client
+}
+
+// Dial connects to the signer served on the Unix socket at path.
+func Dial(path string) (*Remote, error) {
+	client, err := rpc.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signer: %w", err)
+	}
+	return &Remote{client: client}, nil
+}
+
+func (r *Remote) SignPartial(purpose string, msg []byte) ([]byte, error) {
+	return r.sign(serviceName+".SignPartial", PartialRequest{Purpose: purpose, Msg: msg})
+}
+
+func (r *Remote) SignLongterm(msg []byte) ([]byte, error) {
+	return r.sign(serviceName+".SignLongterm", msg)
+}
+
+func (r *Remote) SetShare(s *share.PriShare) error {
+	v, err := s.V.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal share: %w", err)
+	}
+	data := binary.BigEndian.AppendUint32(nil, s.I)
+	data = append(data, v...)
+	defer clear(data)
+
+	var ok bool
+	return remoteError(r.client.Call(serviceName+".SetShare", data, &ok))
+}
+
+// Close closes the connection to the signer.
+func (r *Remote) Close() error {
+	return r.client.Close()
+}
+
+func (r *Remote) sign(method string, args any) ([]byte, error) {
+	var sig []byte
+	if err := r.client.Call(method, args, &sig); err != nil {
+		return nil, remoteError(err)
+	}
+	return sig, nil
+}
+
+// remoteError restores the sentinel errors of the package, which net/rpc
+// turns into strings.
+func remoteError(err error) error {
+	var se rpc.ServerError
+	if errors.As(err, &se) && string(se) == ErrNoShare.Error() {
+		return ErrNoShare
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+// Package signer isolates the secrets of a validator behind the Signer
+// interface: the share of the group key, which signs partial signatures, and
+// the longterm key, which signs DKG bundles and message envelopes. Local keeps
+// them in process, Stored also seals the share on disk, and Remote reaches a
+// signer serving them on a Unix socket from a separate process.
+package signer
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sync"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/sign"
+)
+
+// ErrNoShare is returned for partial signatures before a share was set.
+var ErrNoShare = errors.New("signer has no share")
+
+// Signer signs with the share and longterm key of a validator.
+type Signer interface {
+	// SignPartial signs msg with the share, under the threshold scheme of
+	// the purpose, see crypto.Domain.
+	SignPartial(purpose string, msg []byte) ([]byte, error)
+	// SignLongterm signs msg with the longterm key, under the auth scheme.
+	SignLongterm(msg []byte) ([]byte, error)
+	// SetShare replaces the share after the DKG or a refresh. The signer
+	// keeps its own copy, the caller may zero s afterwards.
+	SetShare(s *share.PriShare) error
+}
+
+// Local signs in process.
+type Local struct {
+	scheme   *crypto.Scheme
+	longterm kyber.Scalar
+
+	mu    sync.Mutex
+	share *share.PriShare
+	// schemes of the purposes signed so far, under mu
+	schemes map[string]*crypto.Scheme
+}
+
+// NewLocal returns a signer holding longterm, signing under scheme and the
+// schemes of other purposes on the same network.
+func NewLocal(scheme *crypto.Scheme, longterm kyber.Scalar) *Local {
+	return &Local{
+		scheme:   scheme,
+		longterm: longterm,
+		schemes:  map[string]*crypto.Scheme{scheme.Domain.Purpose: scheme},
+	}
+}
+
+func (l *Local) SignPartial(purpose string, msg []byte) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.share == nil {
+		return nil, ErrNoShare
+	}
+
+	scheme, ok := l.schemes[purpose]
+	if !ok {
+		var err error
+		if scheme, err = l.scheme.WithPurpose(purpose); err != nil {
+			return nil, err
+		}
+		l.schemes[purpose] = scheme
+	}
+	return scheme.ThresholdScheme.Sign(l.share, msg)
+}
+
+func (l *Local) SignLongterm(msg []byte) ([]byte, error) {
+	return l.scheme.AuthScheme.Sign(l.longterm, msg)
+}
+
+// SetShare copies s and zeroes the share it replaces.
+func (l *Local) SetShare(s *share.PriShare) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.share != nil {
+		l.share.V.Zero()
+	}
+	l.share = &share.PriShare{I: s.I, V: s.V.Clone()}
+	return nil
+}
+
+// authScheme signs with the longterm key of a Signer whatever private key it
+// is given, and verifies with inner.
+type authScheme struct {
+	signer Signer
+	inner  sign.Scheme
+}
+
+// AuthScheme returns a sign.Scheme whose Sign ignores the private key and
+// signs with the longterm key of s, for protocols such as the pedersen DKG
+// that take a scheme rather than a signing function. Verify and NewKeyPair
+// use inner.
+func AuthScheme(s Signer, inner sign.Scheme) sign.Scheme {
+	return &authScheme{signer: s, inner: inner}
+}
+
+func (a *authScheme) NewKeyPair(random cipher.Stream) (kyber.Scalar, kyber.Point) {
+	return a.inner.NewKeyPair(random)
+}
+
+func (a *authScheme) Sign(_ kyber.Scalar, msg []byte) ([]byte, error) {
+	return a.signer.SignLongterm(msg)
+}
+
+func (a *authScheme) Verify(public kyber.Point, msg, sig []byte) error {
+	return a.inner.Verify(public, msg, sig)
+}
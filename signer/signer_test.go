@@ -0,0 +1,119 @@
+package signer
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"random-network-poc/crypto"
+	"random-network-poc/keystore"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func testSigner(t *testing.T, scheme *crypto.Scheme, s Signer) {
+	t.Helper()
+
+	msg := []byte("round")
+
+	_, err := s.SignPartial(crypto.PurposeVRF, msg)
+	require.ErrorIs(t, err, ErrNoShare)
+
+	// a 2-of-3 sharing, the signer holding the second share
+	secret := scheme.KeyGroup.Scalar().Pick(random.New())
+	poly := share.NewPriPoly(scheme.KeyGroup, 2, secret, random.New())
+	pub := poly.Commit(nil)
+	shares := poly.Shares(3)
+
+	require.NoError(t, s.SetShare(shares[1]))
+	// the signer keeps its own copy
+	shares[1].V.Zero()
+
+	partial, err := s.SignPartial(crypto.PurposeVRF, msg)
+	require.NoError(t, err)
+	require.NoError(t, scheme.ThresholdScheme.VerifyPartial(pub, msg, partial))
+	index, err := scheme.ThresholdScheme.IndexOf(partial)
+	require.NoError(t, err)
+	require.Equal(t, 1, index)
+
+	other, err := scheme.ThresholdScheme.Sign(shares[2], msg)
+	require.NoError(t, err)
+	sig, err := scheme.ThresholdScheme.Recover(pub, msg, [][]byte{partial, other}, 2, 3)
+	require.NoError(t, err)
+	require.NoError(t, scheme.SigScheme.Verify(pub.Commit(), msg, sig))
+
+	// other purposes sign under their own domain
+	round, err := scheme.WithPurpose(crypto.PurposeRound)
+	require.NoError(t, err)
+	partial, err = s.SignPartial(crypto.PurposeRound, msg)
+	require.NoError(t, err)
+	require.NoError(t, round.ThresholdScheme.VerifyPartial(pub, msg, partial))
+	require.Error(t, scheme.ThresholdScheme.VerifyPartial(pub, msg, partial))
+}
+
+func TestLocal(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm, public := scheme.AuthScheme.NewKeyPair(random.New())
+	s := NewLocal(scheme, longterm)
+
+	testSigner(t, scheme, s)
+
+	sig, err := s.SignLongterm([]byte("bundle"))
+	require.NoError(t, err)
+	require.NoError(t, scheme.AuthScheme.Verify(public, []byte("bundle"), sig))
+
+	// the DKG signs through the auth scheme, whatever key it holds
+	auth := AuthScheme(s, scheme.AuthScheme)
+	sig, err = auth.Sign(scheme.KeyGroup.Scalar().One(), []byte("bundle"))
+	require.NoError(t, err)
+	require.NoError(t, auth.Verify(public, []byte("bundle"), sig))
+}
+
+// A stored signer resumes with its share once restarted.
+func TestStored(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm, _ := scheme.AuthScheme.NewKeyPair(random.New())
+	path := filepath.Join(t.TempDir(), "share.json")
+
+	s, err := NewStored(NewLocal(scheme, longterm), path, []byte("password"), keystore.LightParams)
+	require.NoError(t, err)
+	testSigner(t, scheme, s)
+	partial, err := s.SignPartial(crypto.PurposeVRF, []byte("round"))
+	require.NoError(t, err)
+
+	restarted, err := NewStored(NewLocal(scheme, longterm), path, []byte("password"), keystore.LightParams)
+	require.NoError(t, err)
+	again, err := restarted.SignPartial(crypto.PurposeVRF, []byte("round"))
+	require.NoError(t, err)
+	require.Equal(t, partial, again)
+
+	_, err = NewStored(NewLocal(scheme, longterm), path, []byte("wrong"), keystore.LightParams)
+	require.ErrorIs(t, err, keystore.ErrPassword)
+}
+
+func TestRemote(t *testing.T) {
+	scheme := crypto.DefaultScheme()
+	longterm, public := scheme.AuthScheme.NewKeyPair(random.New())
+
+	path := filepath.Join(t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- Serve(l, scheme, NewLocal(scheme, longterm)) }()
+
+	remote, err := Dial(path)
+	require.NoError(t, err)
+
+	testSigner(t, scheme, remote)
+
+	sig, err := remote.SignLongterm([]byte("bundle"))
+	require.NoError(t, err)
+	require.NoError(t, scheme.AuthScheme.Verify(public, []byte("bundle"), sig))
+
+	require.NoError(t, remote.Close())
+	require.NoError(t, l.Close())
+	require.NoError(t, <-served)
+}
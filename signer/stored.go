@@ -0,0 +1,47 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"random-network-poc/keystore"
+
+	"go.dedis.ch/kyber/v4/share"
+)
+
+// Stored is a Local signer keeping its share sealed in a keystore share file,
+// so that a restarted signer process resumes with it.
+type Stored struct {
+	*Local
+	path     string
+	password []byte
+	params   keystore.Params
+}
+
+// NewStored returns l storing its share at path, sealed under password, and
+// hands l the share stored there, if any.
+func NewStored(l *Local, path string, password []byte, params keystore.Params) (*Stored, error) {
+	s, err := keystore.LoadShare(path, l.scheme, password)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load share: %w", err)
+	default:
+		defer s.V.Zero()
+		if err := l.SetShare(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Stored{Local: l, path: path, password: password, params: params}, nil
+}
+
+// SetShare seals s at path before replacing the share it signs with, so the
+// stored share is never older than the one in use.
+func (s *Stored) SetShare(sh *share.PriShare) error {
+	if err := keystore.SaveShare(s.path, s.scheme, sh, s.password, s.params); err != nil {
+		return fmt.Errorf("failed to save share: %w", err)
+	}
+	return s.Local.SetShare(sh)
+}
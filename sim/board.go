@@ -0,0 +1,79 @@
+package sim
+
+import (
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+var _ pedersen_dkg.Board = (*Board)(nil)
+
+// Board is the DKG board of a node, broadcasting its bundles over the
+// network.
+type Board struct {
+	net   *Network
+	index int
+
+	deals chan pedersen_dkg.DealBundle
+	resps chan pedersen_dkg.ResponseBundle
+	justs chan pedersen_dkg.JustificationBundle
+}
+
+func newBoard(n *Network, index int) *Board {
+	// a phase sees at most a bundle, sometimes two, of every node
+	size := 4 * n.size
+	return &Board{
+		net:   n,
+		index: index,
+		deals: make(chan pedersen_dkg.DealBundle, size),
+		resps: make(chan pedersen_dkg.ResponseBundle, size),
+		justs: make(chan pedersen_dkg.JustificationBundle, size),
+	}
+}
+
+// Board returns the board of a node.
+func (n *Network) Board(index int) *Board {
+	return n.boards[index]
+}
+
+// push hands a bundle to the protocol of the node. Deliveries run on the
+// goroutine moving the clock, which must not wait for a protocol that may
+// have ended.
+func push[T any](n *Network, ch chan<- T, bundle T) {
+	select {
+	case ch <- bundle:
+	default:
+		n.overflow()
+	}
+}
+
+func (b *Board) PushDeals(bundle *pedersen_dkg.DealBundle) {
+	push(b.net, b.deals, *bundle)
+	b.net.broadcast(b.index, func(to int) {
+		push(b.net, b.net.boards[to].deals, *bundle)
+	})
+}
+
+func (b *Board) IncomingDeal() <-chan pedersen_dkg.DealBundle {
+	return b.deals
+}
+
+func (b *Board) PushResponses(bundle *pedersen_dkg.ResponseBundle) {
+	push(b.net, b.resps, *bundle)
+	b.net.broadcast(b.index, func(to int) {
+		push(b.net, b.net.boards[to].resps, *bundle)
+	})
+}
+
+func (b *Board) IncomingResponse() <-chan pedersen_dkg.ResponseBundle {
+	return b.resps
+}
+
+func (b *Board) PushJustifications(bundle *pedersen_dkg.JustificationBundle) {
+	push(b.net, b.justs, *bundle)
+	b.net.broadcast(b.index, func(to int) {
+		push(b.net, b.net.boards[to].justs, *bundle)
+	})
+}
+
+func (b *Board) IncomingJustification() <-chan pedersen_dkg.JustificationBundle {
+	return b.justs
+}
@@ -0,0 +1,171 @@
+// Package sim runs a committee over a simulated network on a clock.Virtual.
+// Every link has its own latency, jitter and loss, drawn from a seeded source,
+// and the network can be partitioned. A run only depends on its seed and the
+// calls made to the network, so a timing-dependent failure replays from its
+// seed.
+package sim
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"random-network-poc/clock"
+)
+
+// Link describes the messages sent from one node to another.
+type Link struct {
+	// Latency is the minimum delay of a message.
+	Latency time.Duration
+	// Jitter is the maximum delay added to Latency, uniformly.
+	Jitter time.Duration
+	// Loss is the probability a message is dropped.
+	Loss float64
+}
+
+// Stats counts the messages of a network.
+type Stats struct {
+	Sent      int
+	Delivered int
+	// Lost counts messages dropped by the Loss of their link.
+	Lost int
+	// Cut counts messages dropped by a partition.
+	Cut int
+	// Overflow counts bundles dropped by a board holding too many, e.g.
+	// once its protocol ended.
+	Overflow int
+}
+
+// Network connects size nodes, indexed from 0, as their committee indices.
+type Network struct {
+	clock *clock.Virtual
+	size  int
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	link  Link
+	links map[[2]int]Link
+	// group of each node while partitioned, nil otherwise
+	groups     map[int]int
+	boards     []*Board
+	transports []*transport
+	stats      Stats
+}
+
+// New returns a network of size nodes on c, whose links all default to link.
+func New(c *clock.Virtual, size int, seed uint64, link Link) *Network {
+	n := &Network{
+		clock:      c,
+		size:       size,
+		rand:       rand.New(rand.NewPCG(seed, 0)),
+		link:       link,
+		links:      make(map[[2]int]Link),
+		transports: make([]*transport, size),
+	}
+	for i := 0; i < size; i++ {
+		n.boards = append(n.boards, newBoard(n, i))
+	}
+	return n
+}
+
+// Clock returns the clock of the network.
+func (n *Network) Clock() *clock.Virtual {
+	return n.clock
+}
+
+// SetLink replaces the link from one node to another.
+func (n *Network) SetLink(from, to int, l Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.links[[2]int{from, to}] = l
+}
+
+// Partition splits the network into groups, which only reach their own
+// members. Nodes in no group reach no one.
+func (n *Network) Partition(groups ...[]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = make(map[int]int)
+	for g, nodes := range groups {
+		for _, i := range nodes {
+			n.groups[i] = g
+		}
+	}
+}
+
+// Heal ends the partition. Messages already dropped are not resent.
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = nil
+}
+
+// Run moves the clock by d, delivering the messages and running the phases
+// and deadlines due meanwhile.
+func (n *Network) Run(d time.Duration) {
+	n.clock.Advance(d)
+}
+
+// Stats returns the message counts so far.
+func (n *Network) Stats() Stats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.stats
+}
+
+// send schedules deliver after the delay of the link from one node to
+// another, unless the message is lost or cut.
+func (n *Network) send(from, to int, deliver func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.stats.Sent++
+	if n.groups != nil {
+		gFrom, okFrom := n.groups[from]
+		gTo, okTo := n.groups[to]
+		if !okFrom || !okTo || gFrom != gTo {
+			n.stats.Cut++
+			return
+		}
+	}
+
+	l, ok := n.links[[2]int{from, to}]
+	if !ok {
+		l = n.link
+	}
+	// every message draws the same numbers, so that changing a link does
+	// not shift the draws of the others
+	lost := n.rand.Float64() < l.Loss
+	jitter := n.rand.Int64N(int64(l.Jitter) + 1)
+	if lost {
+		n.stats.Lost++
+		return
+	}
+
+	n.clock.AfterFunc(l.Latency+time.Duration(jitter), func() {
+		n.mu.Lock()
+		n.stats.Delivered++
+		n.mu.Unlock()
+		deliver()
+	})
+}
+
+// broadcast sends to every other node.
+func (n *Network) broadcast(from int, deliver func(to int)) {
+	for to := 0; to < n.size; to++ {
+		if to != from {
+			n.send(from, to, func() { deliver(to) })
+		}
+	}
+}
+
+func (n *Network) overflow() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.stats.Overflow++
+}
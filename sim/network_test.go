@@ -0,0 +1,88 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/clock"
+
+	"github.com/stretchr/testify/require"
+	pedersen_dkg "go.dedis.ch/kyber/v4/share/dkg/pedersen"
+)
+
+// arrivals returns the dealer of each deal on the board of a node, in order.
+func arrivals(b *Board) []uint32 {
+	var dealers []uint32
+	for {
+		select {
+		case bundle := <-b.IncomingDeal():
+			dealers = append(dealers, bundle.DealerIndex)
+		default:
+			return dealers
+		}
+	}
+}
+
+func TestNetworkLinks(t *testing.T) {
+	net := New(clock.NewVirtual(time.Unix(0, 0)), 3, 1, Link{Latency: 100 * time.Millisecond})
+	net.SetLink(2, 0, Link{Latency: 50 * time.Millisecond})
+
+	net.Board(1).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: 1})
+	net.Board(2).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: 2})
+	// a board hands its own bundles to its protocol at once
+	require.Equal(t, []uint32{1}, arrivals(net.Board(1)))
+
+	net.Run(99 * time.Millisecond)
+	require.Equal(t, []uint32{2}, arrivals(net.Board(0)))
+	require.Empty(t, arrivals(net.Board(1)))
+
+	net.Run(time.Millisecond)
+	require.Equal(t, []uint32{1}, arrivals(net.Board(0)))
+	require.Equal(t, []uint32{2}, arrivals(net.Board(1)))
+	require.Equal(t, Stats{Sent: 4, Delivered: 4}, net.Stats())
+}
+
+func TestNetworkPartition(t *testing.T) {
+	net := New(clock.NewVirtual(time.Unix(0, 0)), 3, 1, Link{Latency: time.Millisecond})
+
+	net.Partition([]int{0, 1})
+	net.Board(0).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: 0})
+	net.Board(2).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: 2})
+	net.Run(time.Second)
+	require.Equal(t, []uint32{0}, arrivals(net.Board(1)))
+	require.Equal(t, []uint32{2}, arrivals(net.Board(2)))
+
+	net.Heal()
+	net.Board(2).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: 2})
+	net.Run(time.Second)
+	require.Equal(t, []uint32{0, 2}, arrivals(net.Board(0)))
+	require.Equal(t, 3, net.Stats().Cut)
+}
+
+func TestNetworkSeed(t *testing.T) {
+	run := func(seed uint64) ([][]uint32, Stats) {
+		net := New(clock.NewVirtual(time.Unix(0, 0)), 8, seed, Link{Latency: time.Millisecond, Jitter: time.Second, Loss: 0.3})
+		for i := 0; i < 8; i++ {
+			net.Board(i).PushDeals(&pedersen_dkg.DealBundle{DealerIndex: uint32(i)})
+		}
+		net.Run(2 * time.Second)
+
+		var boards [][]uint32
+		for i := 0; i < 8; i++ {
+			boards = append(boards, arrivals(net.Board(i)))
+		}
+		return boards, net.Stats()
+	}
+
+	boards, stats := run(7)
+	require.NotZero(t, stats.Lost)
+	require.Equal(t, stats.Sent, stats.Lost+stats.Delivered)
+
+	// the same seed delivers the same bundles in the same order
+	again, againStats := run(7)
+	require.Equal(t, boards, again)
+	require.Equal(t, stats, againStats)
+
+	other, _ := run(8)
+	require.NotEqual(t, boards, other)
+}
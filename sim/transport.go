@@ -0,0 +1,148 @@
+package sim
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"random-network-poc/rng"
+)
+
+// transport carries the RNG rounds of a node over the network: the
+// announcement goes to every other node, and each signature straight back to
+// the announcing node. Envelopes, replays and rate limits are left to
+// rng.Protocol.
+type transport struct {
+	ctx             context.Context
+	net             *Network
+	index           int
+	handleSignVRF   rng.HandleSignVRF
+	handleSignature rng.HandleSignature
+
+	mu       sync.Mutex
+	finished map[string]bool
+	counters rng.Counters
+}
+
+var _ rng.Transport = (*transport)(nil)
+
+// Transport returns the RNG transport of a node, for dkg.Config.Transport.
+func (n *Network) Transport(index int) func(context.Context, rng.HandleSignVRF, rng.HandleSignature) (rng.Transport, error) {
+	return func(ctx context.Context, handleSignVRF rng.HandleSignVRF, handleSignature rng.HandleSignature) (rng.Transport, error) {
+		if index < 0 || index >= n.size {
+			return nil, fmt.Errorf("node %d out of a network of %d", index, n.size)
+		}
+
+		t := &transport{
+			ctx:             ctx,
+			net:             n,
+			index:           index,
+			handleSignVRF:   handleSignVRF,
+			handleSignature: handleSignature,
+			finished:        make(map[string]bool),
+		}
+
+		n.mu.Lock()
+		n.transports[index] = t
+		n.mu.Unlock()
+
+		return t, nil
+	}
+}
+
+func (t *transport) Start(requestID string, data []byte) error {
+	if t.isFinished(requestID) {
+		return fmt.Errorf("%w: %s", rng.ErrRequestFinished, requestID)
+	}
+
+	signVRF := rng.SignVRF{
+		RequestID:   requestID,
+		Data:        hex.EncodeToString(data),
+		SenderIndex: uint32(t.index),
+	}
+	t.net.broadcast(t.index, func(to int) {
+		if peer := t.net.transport(to); peer != nil {
+			peer.receiveSignVRF(t.index, signVRF)
+		}
+	})
+
+	return nil
+}
+
+// receiveSignVRF signs an announcement and sends the signature back.
+func (t *transport) receiveSignVRF(from int, signVRF rng.SignVRF) {
+	if t.ctx.Err() != nil {
+		return
+	}
+
+	signature, err := t.handleSignVRF(signVRF)
+	if err != nil {
+		return
+	}
+	signature.SenderIndex = uint32(t.index)
+
+	t.net.send(t.index, from, func() {
+		if peer := t.net.transport(from); peer != nil {
+			peer.receiveSignature(signature)
+		}
+	})
+}
+
+func (t *transport) receiveSignature(signature rng.Signature) {
+	if t.ctx.Err() != nil {
+		return
+	}
+	if t.isFinished(signature.RequestID) {
+		t.mu.Lock()
+		t.counters.Finished++
+		t.mu.Unlock()
+		return
+	}
+	if signature.Rejected != "" {
+		t.mu.Lock()
+		t.counters.Rejected++
+		t.mu.Unlock()
+	}
+
+	_ = t.handleSignature(signature)
+}
+
+func (t *transport) isFinished(requestID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.finished[requestID]
+}
+
+func (t *transport) Finish(requestID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.finished[requestID] = true
+}
+
+func (t *transport) Counters() rng.Counters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.counters
+}
+
+func (t *transport) Close() error {
+	t.net.mu.Lock()
+	defer t.net.mu.Unlock()
+
+	if t.net.transports[t.index] == t {
+		t.net.transports[t.index] = nil
+	}
+	return nil
+}
+
+// transport returns the transport of a node, nil once closed.
+func (n *Network) transport(index int) *transport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.transports[index]
+}
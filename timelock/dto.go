@@ -0,0 +1,95 @@
+package timelock
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"random-network-poc/crypto"
+)
+
+// CiphertextDTO is a Data Transfer Object for Ciphertext
+// with JSON tags for serialization
+type CiphertextDTO struct {
+	Round uint64 `json:"round"`
+	U     string `json:"u"`
+	V     string `json:"v"`
+	W     string `json:"w"`
+	Nonce string `json:"nonce"`
+	Data  string `json:"data"`
+}
+
+// MarshalCiphertext converts a Ciphertext to a CiphertextDTO
+func MarshalCiphertext(ct *Ciphertext) (*CiphertextDTO, error) {
+	u, err := ct.U.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal U: %w", err)
+	}
+
+	return &CiphertextDTO{
+		Round: ct.Round,
+		U:     hex.EncodeToString(u),
+		V:     hex.EncodeToString(ct.V),
+		W:     hex.EncodeToString(ct.W),
+		Nonce: hex.EncodeToString(ct.Nonce),
+		Data:  hex.EncodeToString(ct.Data),
+	}, nil
+}
+
+// UnmarshalCiphertext converts a CiphertextDTO to a Ciphertext
+func UnmarshalCiphertext(s *crypto.Scheme, dto *CiphertextDTO) (*Ciphertext, error) {
+	u, err := hex.DecodeString(dto.U)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode U: %w", err)
+	}
+
+	point, err := s.PointFromBytes(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal U: %w", err)
+	}
+
+	ct := &Ciphertext{
+		Round: dto.Round,
+		U:     point,
+	}
+
+	fields := []struct {
+		name string
+		src  string
+		dst  *[]byte
+	}{
+		{"V", dto.V, &ct.V},
+		{"W", dto.W, &ct.W},
+		{"nonce", dto.Nonce, &ct.Nonce},
+		{"data", dto.Data, &ct.Data},
+	}
+
+	for _, f := range fields {
+		b, err := hex.DecodeString(f.src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", f.name, err)
+		}
+		*f.dst = b
+	}
+
+	return ct, nil
+}
+
+// CiphertextToJSON converts a Ciphertext to JSON bytes
+func CiphertextToJSON(ct *Ciphertext) ([]byte, error) {
+	dto, err := MarshalCiphertext(ct)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(dto)
+}
+
+// CiphertextFromJSON converts JSON bytes to a Ciphertext
+func CiphertextFromJSON(s *crypto.Scheme, data []byte) (*Ciphertext, error) {
+	var dto CiphertextDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return nil, err
+	}
+
+	return UnmarshalCiphertext(s, &dto)
+}
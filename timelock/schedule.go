@@ -0,0 +1,46 @@
+package timelock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrTooEarly is returned for a round signed before it is due.
+var ErrTooEarly = errors.New("round not due yet")
+
+// Schedule times the beacon rounds: round 0 is due at Genesis and every
+// later round a Period after the one before it. Validators sign no round
+// before it is due, so a ciphertext locked to a round opens no earlier.
+type Schedule struct {
+	Genesis time.Time
+	Period  time.Duration
+}
+
+// Time returns when round is due. Rounds beyond the range of time.Duration
+// are due at the end of it, see Check.
+func (s *Schedule) Time(round uint64) time.Time {
+	if s.beyond(round) {
+		return s.Genesis.Add(math.MaxInt64)
+	}
+	return s.Genesis.Add(time.Duration(round) * s.Period)
+}
+
+// Check fails with ErrTooEarly for a round not due at now, and for the rounds
+// beyond the range of time.Duration, which are never.
+func (s *Schedule) Check(round uint64, now time.Time) error {
+	if s.beyond(round) {
+		return fmt.Errorf("%w: round %d is beyond the schedule", ErrTooEarly, round)
+	}
+	if due := s.Time(round); now.Before(due) {
+		return fmt.Errorf("%w: round %d is due at %s", ErrTooEarly, round, due.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// beyond reports whether round is due later than time.Duration counts from
+// Genesis.
+func (s *Schedule) beyond(round uint64) bool {
+	return s.Period > 0 && round > uint64(math.MaxInt64/s.Period)
+}
@@ -0,0 +1,171 @@
+package timelock
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"random-network-poc/crypto"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/encrypt/ibe"
+)
+
+// keySize is the size of the symmetric key sealed with IBE. It has to fit
+// into the suite hash output, which bounds the IBE plaintext.
+const keySize = 32
+
+// roundTag starts the input of every round, see RoundMessage.
+const roundTag = "RNN-ROUND:"
+
+var ErrInvalidRoundSignature = errors.New("invalid round signature")
+
+// Ciphertext is a message locked to a future beacon round. The symmetric key
+// is encrypted with Boneh-Franklin IBE towards the round identity and the
+// message itself with AES-GCM under that key.
+type Ciphertext struct {
+	Round uint64
+	U     kyber.Point
+	V     []byte
+	W     []byte
+	Nonce []byte
+	Data  []byte
+}
+
+// RoundMessage returns the beacon input for the given round. The network
+// signature over this input, for crypto.PurposeRound, is the decryption key of
+// the round.
+func RoundMessage(round uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(roundTag), round)
+}
+
+// ParseRound returns the round of a RoundMessage, false for any other input.
+func ParseRound(msg []byte) (uint64, bool) {
+	if len(msg) != len(roundTag)+8 || !bytes.HasPrefix(msg, []byte(roundTag)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(msg[len(roundTag):]), true
+}
+
+// Encrypt locks msg to the given round under the group public key, for the
+// round purpose of the network of s. Round identities are hashed to the
+// signature group of the scheme, so the master key and the U point of the
+// ciphertext live on its key group.
+func Encrypt(s *crypto.Scheme, public kyber.Point, round uint64, msg []byte) (*Ciphertext, error) {
+	s, err := s.WithPurpose(crypto.PurposeRound)
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	encrypt := ibe.EncryptCCAonG1
+	if s.SigOnG1 {
+		encrypt = ibe.EncryptCCAonG2
+	}
+
+	sealed, err := encrypt(s.Pairing, public, s.DomainInput(RoundMessage(round)), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	ct := &Ciphertext{
+		Round: round,
+		U:     sealed.U,
+		V:     sealed.V,
+		W:     sealed.W,
+		Nonce: nonce,
+	}
+	ct.Data = aead.Seal(nil, nonce, msg, ct.additionalData())
+
+	return ct, nil
+}
+
+// Decrypt opens a ciphertext with the recovered threshold signature of its
+// round. The signature is verified against the group public key first, so a
+// wrong or early signature, or one made for another purpose than
+// crypto.PurposeRound, fails with ErrInvalidRoundSignature.
+func Decrypt(s *crypto.Scheme, public kyber.Point, signature []byte, ct *Ciphertext) ([]byte, error) {
+	s, err := s.WithPurpose(crypto.PurposeRound)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.SigScheme.Verify(public, RoundMessage(ct.Round), signature); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRoundSignature, err)
+	}
+
+	private, err := s.SignatureFromBytes(signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signature: %w", err)
+	}
+
+	decrypt := ibe.DecryptCCAonG1
+	if s.SigOnG1 {
+		decrypt = ibe.DecryptCCAonG2
+	}
+
+	// points of BLS12-381 compare equal within the domain they were
+	// decoded for only, U may come from the scheme of another purpose
+	u, err := ct.U.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal U: %w", err)
+	}
+	U, err := s.PointFromBytes(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal U: %w", err)
+	}
+
+	key, err := decrypt(s.Pairing, private, &ibe.Ciphertext{U: U, V: ct.V, W: ct.W})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := aead.Open(nil, ct.Nonce, ct.Data, ct.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// additionalData binds the round to the symmetric ciphertext.
+func (c *Ciphertext) additionalData() []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], c.Round)
+	return buf[:]
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aead: %w", err)
+	}
+	return aead, nil
+}
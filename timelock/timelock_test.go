@@ -0,0 +1,144 @@
+package timelock
+
+import (
+	"testing"
+	"time"
+
+	"random-network-poc/crypto"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+var schemes = []string{"bn256-g1", "bn254-g1", "bls12381-g1", "bls12381-g2"}
+
+// roundSigner emulates the network by signing round messages with a 2-of-3
+// sharing of a random group secret.
+type roundSigner struct {
+	scheme *crypto.Scheme
+	// round signs for crypto.PurposeRound on the network of scheme
+	round *crypto.Scheme
+	pri   *share.PriPoly
+	pub   *share.PubPoly
+}
+
+func newRoundSigner(t *testing.T, name string) *roundSigner {
+	s, err := crypto.ParseScheme(name)
+	require.NoError(t, err)
+
+	round, err := s.WithPurpose(crypto.PurposeRound)
+	require.NoError(t, err)
+
+	pri := share.NewPriPoly(s.KeyGroup, 2, nil, random.New())
+	return &roundSigner{
+		scheme: s,
+		round:  round,
+		pri:    pri,
+		pub:    pri.Commit(s.KeyGroup.Point().Base()),
+	}
+}
+
+func (s *roundSigner) sign(t *testing.T, round uint64) []byte {
+	return s.signAs(t, s.round, round)
+}
+
+// signAs signs the message of round under scheme.
+func (s *roundSigner) signAs(t *testing.T, scheme *crypto.Scheme, round uint64) []byte {
+	msg := RoundMessage(round)
+
+	var partials [][]byte
+	for _, sh := range s.pri.Shares(3)[:2] {
+		sig, err := scheme.ThresholdScheme.Sign(sh, msg)
+		require.NoError(t, err)
+		partials = append(partials, sig)
+	}
+
+	sig, err := scheme.ThresholdScheme.Recover(s.pub, msg, partials, 2, 3)
+	require.NoError(t, err)
+
+	return sig
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, name := range schemes {
+		t.Run(name, func(t *testing.T) {
+			signer := newRoundSigner(t, name)
+			msg := []byte("sealed bid: 42")
+
+			ct, err := Encrypt(signer.scheme, signer.pub.Commit(), 7, msg)
+			require.NoError(t, err)
+
+			data, err := CiphertextToJSON(ct)
+			require.NoError(t, err)
+
+			ct, err = CiphertextFromJSON(signer.scheme, data)
+			require.NoError(t, err)
+
+			got, err := Decrypt(signer.scheme, signer.pub.Commit(), signer.sign(t, 7), ct)
+			require.NoError(t, err)
+			require.Equal(t, msg, got)
+		})
+	}
+}
+
+func TestDecryptWrongRound(t *testing.T) {
+	signer := newRoundSigner(t, crypto.DefaultSchemeName)
+
+	ct, err := Encrypt(signer.scheme, signer.pub.Commit(), 7, []byte("too early"))
+	require.NoError(t, err)
+
+	_, err = Decrypt(signer.scheme, signer.pub.Commit(), signer.sign(t, 6), ct)
+	require.ErrorIs(t, err, ErrInvalidRoundSignature)
+}
+
+func TestDecryptTampered(t *testing.T) {
+	signer := newRoundSigner(t, crypto.DefaultSchemeName)
+
+	ct, err := Encrypt(signer.scheme, signer.pub.Commit(), 7, []byte("fair reveal"))
+	require.NoError(t, err)
+
+	ct.Data[0] ^= 0xff
+
+	_, err = Decrypt(signer.scheme, signer.pub.Commit(), signer.sign(t, 7), ct)
+	require.Error(t, err)
+}
+
+func TestDecryptOtherPurpose(t *testing.T) {
+	signer := newRoundSigner(t, crypto.DefaultSchemeName)
+
+	ct, err := Encrypt(signer.scheme, signer.pub.Commit(), 7, []byte("sealed bid"))
+	require.NoError(t, err)
+
+	// the round input signed as the input of a client
+	_, err = Decrypt(signer.scheme, signer.pub.Commit(), signer.signAs(t, signer.scheme, 7), ct)
+	require.ErrorIs(t, err, ErrInvalidRoundSignature)
+}
+
+func TestParseRound(t *testing.T) {
+	for _, round := range []uint64{0, 7, 1<<64 - 1} {
+		got, ok := ParseRound(RoundMessage(round))
+		require.True(t, ok)
+		require.Equal(t, round, got)
+	}
+
+	for _, msg := range [][]byte{nil, []byte(roundTag), append(RoundMessage(7), 0), RoundMessage(7)[1:]} {
+		_, ok := ParseRound(msg)
+		require.False(t, ok, msg)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	genesis := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Schedule{Genesis: genesis, Period: 30 * time.Second}
+
+	require.Equal(t, genesis, s.Time(0))
+	require.Equal(t, genesis.Add(time.Minute), s.Time(2))
+	require.True(t, s.Time(1<<64-1).After(s.Time(1<<20)))
+
+	require.ErrorIs(t, s.Check(0, genesis.Add(-time.Nanosecond)), ErrTooEarly)
+	require.NoError(t, s.Check(0, genesis))
+	require.ErrorIs(t, s.Check(2, genesis.Add(time.Minute-time.Nanosecond)), ErrTooEarly)
+	require.NoError(t, s.Check(2, genesis.Add(time.Minute)))
+	require.ErrorIs(t, s.Check(1<<64-1, genesis.AddDate(1000, 0, 0)), ErrTooEarly)
+}
@@ -0,0 +1,94 @@
+// Package wire selects the encoding of messages published by a node. JSON and
+// protobuf (see pb/wire.proto) coexist while a network upgrades: every node
+// decodes both, and a node publishes protobuf only once every peer of the
+// topic advertises support for it.
+package wire
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Version is the version of the protobuf schema, carried by every top level
+// message.
+const Version = 1
+
+// ProtobufProtocol is registered by nodes able to decode protobuf messages,
+// so peers learn about it through libp2p identify.
+const ProtobufProtocol = protocol.ID("/rnn/wire/protobuf/1")
+
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatProtobuf
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatProtobuf:
+		return "protobuf"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// ParseFormat parses "json" or "protobuf".
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "json":
+		return FormatJSON, nil
+	case "protobuf":
+		return FormatProtobuf, nil
+	default:
+		return 0, fmt.Errorf("unknown wire format %q", s)
+	}
+}
+
+// Detect returns the format of an encoded message. JSON messages are objects
+// and start with '{', which is never the first byte of a protobuf message of
+// this schema since those start with the version field tag.
+func Detect(data []byte) Format {
+	if len(data) > 0 && data[0] == '{' {
+		return FormatJSON
+	}
+	return FormatProtobuf
+}
+
+// Negotiator picks the format a node publishes with.
+type Negotiator struct {
+	host      host.Host
+	preferred Format
+}
+
+// NewNegotiator advertises protobuf support on h. With preferred set to
+// FormatJSON the node decodes protobuf but keeps publishing JSON.
+func NewNegotiator(h host.Host, preferred Format) *Negotiator {
+	h.SetStreamHandler(ProtobufProtocol, func(s network.Stream) {
+		s.Reset()
+	})
+	return &Negotiator{host: h, preferred: preferred}
+}
+
+// Format returns the format to publish with to peers. A nil negotiator always
+// picks JSON.
+func (n *Negotiator) Format(peers []peer.ID) Format {
+	if n == nil || n.preferred == FormatJSON {
+		return FormatJSON
+	}
+
+	for _, p := range peers {
+		supported, err := n.host.Peerstore().SupportsProtocols(p, ProtobufProtocol)
+		if err != nil || len(supported) == 0 {
+			return FormatJSON
+		}
+	}
+
+	return FormatProtobuf
+}
@@ -0,0 +1,62 @@
+package wire
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	require.Equal(t, FormatJSON, Detect([]byte(`{"type":1}`)))
+	require.Equal(t, FormatProtobuf, Detect([]byte{0x08, 0x01}))
+	require.Equal(t, FormatProtobuf, Detect(nil))
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatProtobuf} {
+		got, err := ParseFormat(f.String())
+		require.NoError(t, err)
+		require.Equal(t, f, got)
+	}
+	_, err := ParseFormat("xml")
+	require.Error(t, err)
+}
+
+func newHost(t *testing.T) host.Host {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func connect(t *testing.T, a, b host.Host) {
+	require.NoError(t, a.Connect(context.Background(), peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}))
+}
+
+func TestNegotiator(t *testing.T) {
+	a, b, legacy := newHost(t), newHost(t), newHost(t)
+
+	negotiator := NewNegotiator(a, FormatProtobuf)
+	NewNegotiator(b, FormatJSON)
+
+	connect(t, a, b)
+	connect(t, a, legacy)
+
+	require.Eventually(t, func() bool {
+		return negotiator.Format([]peer.ID{b.ID()}) == FormatProtobuf
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a single peer without protobuf support keeps the topic on JSON
+	require.Equal(t, FormatJSON, negotiator.Format([]peer.ID{b.ID(), legacy.ID()}))
+
+	// a node preferring JSON never switches
+	require.Equal(t, FormatJSON, NewNegotiator(legacy, FormatJSON).Format([]peer.ID{a.ID()}))
+
+	var none *Negotiator
+	require.Equal(t, FormatJSON, none.Format(nil))
+}